@@ -51,7 +51,7 @@ type Config struct {
 	// ViewName is the name of the Viewer PLugin defined in the Config
 	ViewName string
 
-	// TLSConfig when listening for encrypted connections (gRPC, DNS-over-TLS).
+	// TLSConfig when listening for encrypted connections (gRPC, DNS-over-TLS, DNS-over-QUIC).
 	TLSConfig *tls.Config
 
 	// Timeouts for TCP, TLS and HTTPS servers.
@@ -62,6 +62,10 @@ type Config struct {
 	// TSIG secrets, [name]key.
 	TsigSecret map[string]string
 
+	// Updates is set by plugins that handle dynamic updates (RFC 2136). Without it, updates are answered
+	// with NOTIMP.
+	Updates bool
+
 	// Plugin stack.
 	Plugin []plugin.Plugin
 
@@ -0,0 +1,66 @@
+package dnsserver
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// DoQWriter is a dns.ResponseWriter that writes the response to a single DNS-over-QUIC stream.
+type DoQWriter struct {
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	stream     quic.Stream
+	Msg        *dns.Msg
+}
+
+// Write writes b, prefixed with its 2-octet length, to the stream.
+func (w *DoQWriter) Write(b []byte) (int, error) {
+	if _, err := w.stream.Write(AddPrefix(b)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// WriteMsg packs m, writes it to the stream and closes the stream; RFC 9250 allows only a single
+// response per stream.
+func (w *DoQWriter) WriteMsg(m *dns.Msg) error {
+	buf, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	w.Msg = m
+
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// Close sends the STREAM FIN, signaling that no more data will be sent on this stream.
+func (w *DoQWriter) Close() error { return w.stream.Close() }
+
+// TsigStatus implements dns.ResponseWriter.
+func (w *DoQWriter) TsigStatus() error { return nil }
+
+// TsigTimersOnly implements dns.ResponseWriter.
+func (w *DoQWriter) TsigTimersOnly(bool) {}
+
+// Hijack implements dns.ResponseWriter.
+func (w *DoQWriter) Hijack() {}
+
+// LocalAddr returns the local address.
+func (w *DoQWriter) LocalAddr() net.Addr { return w.localAddr }
+
+// RemoteAddr returns the remote address.
+func (w *DoQWriter) RemoteAddr() net.Addr { return w.remoteAddr }
+
+// AddPrefix adds the 2-octet length prefix used by DNS-over-QUIC (and DNS over TCP) to b.
+func AddPrefix(b []byte) []byte {
+	m := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(m, uint16(len(b)))
+	copy(m[2:], b)
+	return m
+}
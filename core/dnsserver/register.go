@@ -86,6 +86,8 @@ func (h *dnsContext) InspectServerBlocks(sourceFile string, serverBlocks []caddy
 					port = transport.GRPCPort
 				case transport.HTTPS:
 					port = transport.HTTPSPort
+				case transport.QUIC:
+					port = transport.QUICPort
 				}
 			}
 
@@ -148,6 +150,7 @@ func (h *dnsContext) MakeServers() ([]caddy.Server, error) {
 		c.WriteTimeout = c.firstConfigInBlock.WriteTimeout
 		c.IdleTimeout = c.firstConfigInBlock.IdleTimeout
 		c.TsigSecret = c.firstConfigInBlock.TsigSecret
+		c.Updates = c.firstConfigInBlock.Updates
 	}
 
 	// we must map (group) each config to a bind address
@@ -187,6 +190,13 @@ func (h *dnsContext) MakeServers() ([]caddy.Server, error) {
 				return nil, err
 			}
 			servers = append(servers, s)
+
+		case transport.QUIC:
+			s, err := NewServerQUIC(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
 		}
 	}
 
@@ -44,6 +44,7 @@ type Server struct {
 	debug        bool                 // disable recover()
 	stacktrace   bool                 // enable stacktrace in recover error log
 	classChaos   bool                 // allow non-INET class queries
+	updates      bool                 // allow dynamic updates (RFC 2136)
 	idleTimeout  time.Duration        // Idle timeout for TCP
 	readTimeout  time.Duration        // Read timeout for TCP
 	writeTimeout time.Duration        // Write timeout for TCP
@@ -103,6 +104,10 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 			s.tsigSecret[key] = secret
 		}
 
+		if site.Updates {
+			s.updates = true
+		}
+
 		// compile custom plugin for everything
 		var stack plugin.Handler
 		for i := len(site.Plugin) - 1; i >= 0; i-- {
@@ -151,6 +156,7 @@ func (s *Server) Serve(l net.Listener) error {
 	s.server[tcp] = &dns.Server{Listener: l,
 		Net:           "tcp",
 		TsigSecret:    s.tsigSecret,
+		MsgAcceptFunc: s.msgAcceptFunc(),
 		MaxTCPQueries: tcpMaxQueries,
 		ReadTimeout:   s.readTimeout,
 		WriteTimeout:  s.writeTimeout,
@@ -176,7 +182,7 @@ func (s *Server) ServePacket(p net.PacketConn) error {
 		ctx := context.WithValue(context.Background(), Key{}, s)
 		ctx = context.WithValue(ctx, LoopKey{}, 0)
 		s.ServeDNS(ctx, w, r)
-	}), TsigSecret: s.tsigSecret}
+	}), TsigSecret: s.tsigSecret, MsgAcceptFunc: s.msgAcceptFunc()}
 	s.m.Unlock()
 
 	return s.server[udp].ActivateAndServe()
@@ -312,6 +318,10 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 						// if there was a view defined for this Config, set the view name in the context
 						ctx = context.WithValue(ctx, ViewKey{}, h.ViewName)
 					}
+					if r.Opcode == dns.OpcodeUpdate && !h.Updates {
+						errorAndMetricsFunc(s.Addr, w, r, dns.RcodeNotImplemented)
+						return
+					}
 					if r.Question[0].Qtype != dns.TypeDS {
 						rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
 						if !plugin.ClientWrite(rcode) {
@@ -361,6 +371,10 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 					// if there was a view defined for this Config, set the view name in the context
 					ctx = context.WithValue(ctx, ViewKey{}, h.ViewName)
 				}
+				if r.Opcode == dns.OpcodeUpdate && !h.Updates {
+					errorAndMetricsFunc(s.Addr, w, r, dns.RcodeNotImplemented)
+					return
+				}
 				rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
 				if !plugin.ClientWrite(rcode) {
 					errorFunc(s.Addr, w, r, rcode)
@@ -374,6 +388,24 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	errorAndMetricsFunc(s.Addr, w, r, dns.RcodeRefused)
 }
 
+// msgAcceptFunc returns the function that decides which messages are handled. On top of what the default
+// accepts, it accepts dynamic updates if a plugin handles them.
+func (s *Server) msgAcceptFunc() dns.MsgAcceptFunc {
+	if !s.updates {
+		return dns.DefaultMsgAcceptFunc
+	}
+	return func(dh dns.Header) dns.MsgAcceptAction {
+		const qr = 1 << 15
+		if opcode := int(dh.Bits>>11) & 0xF; opcode == dns.OpcodeUpdate && dh.Bits&qr == 0 {
+			if dh.Qdcount != 1 {
+				return dns.MsgReject
+			}
+			return dns.MsgAccept
+		}
+		return dns.DefaultMsgAcceptFunc(dh)
+	}
+}
+
 // passAllFilterFuncs returns true if all filter funcs evaluate to true for the given request
 func passAllFilterFuncs(ctx context.Context, filterFuncs []FilterFunc, req *request.Request) bool {
 	for _, ff := range filterFuncs {
@@ -0,0 +1,267 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/metrics/vars"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/reuseport"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// DoQ error codes, see RFC 9250, Section 8.4.
+const (
+	// DoQCodeNoError is used when the connection or stream needs to be closed, but there is no error to signal.
+	DoQCodeNoError quic.ApplicationErrorCode = 0
+	// DoQCodeInternalError signals that the DoQ implementation encountered an internal error and is incapable of
+	// pursuing the transaction or the connection.
+	DoQCodeInternalError quic.ApplicationErrorCode = 1
+	// DoQCodeProtocolError signals that the DoQ implementation encountered a protocol error and is forcibly aborting
+	// the connection.
+	DoQCodeProtocolError quic.ApplicationErrorCode = 2
+	// DoQCodeRequestCancelled signals that a client canceled an outstanding transaction.
+	DoQCodeRequestCancelled quic.ApplicationErrorCode = 3
+)
+
+// ServerQUIC represents an instance of a DNS-over-QUIC server.
+type ServerQUIC struct {
+	*Server
+	tlsConfig    *tls.Config
+	quicConfig   *quic.Config
+	quicListener *quic.Listener
+}
+
+// NewServerQUIC returns a new CoreDNS QUIC server and compiles all plugin in to it.
+func NewServerQUIC(addr string, group []*Config) (*ServerQUIC, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	// The *tls* plugin must make sure that multiple conflicting
+	// TLS configuration returns an error: it can only be specified once.
+	var tlsConfig *tls.Config
+	for _, z := range s.zones {
+		for _, conf := range z {
+			// Should we error if some configs *don't* have TLS?
+			tlsConfig = conf.TLSConfig
+		}
+	}
+	// QUIC can't be used without TLS.
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("no TLS configuration for %s, use the tls plugin to configure it", addr)
+	}
+
+	// The ALPN token "doq" identifies DNS-over-QUIC, see RFC 9250, Section 4.1.1.
+	tlsConfig.NextProtos = []string{"doq"}
+
+	quicConfig := &quic.Config{
+		MaxIdleTimeout:        s.idleTimeout,
+		MaxIncomingStreams:    doqMaxStreams,
+		MaxIncomingUniStreams: -1, // DoQ only uses bidirectional streams.
+		// 0-RTT data can be replayed, and nothing here tells queries from UPDATE or NOTIFY messages that must
+		// not be served from it, so 0-RTT stays disabled. See RFC 9250, Section 4.5.
+		Allow0RTT: false,
+	}
+
+	return &ServerQUIC{Server: s, tlsConfig: tlsConfig, quicConfig: quicConfig}, nil
+}
+
+// Compile-time check to ensure ServerQUIC implements the caddy.GracefulServer interface
+var _ caddy.GracefulServer = &ServerQUIC{}
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerQUIC) ServePacket(p net.PacketConn) error {
+	s.m.Lock()
+	l, err := quic.Listen(p, s.tlsConfig, s.quicConfig)
+	if err != nil {
+		s.m.Unlock()
+		return err
+	}
+	s.quicListener = l
+	s.m.Unlock()
+
+	for {
+		conn, err := l.Accept(context.Background())
+		if err != nil {
+			if errors.Is(err, quic.ErrServerClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveQUICConnection(conn)
+	}
+}
+
+// serveQUICConnection accepts the streams of a single connection, every stream carries exactly one query.
+func (s *ServerQUIC) serveQUICConnection(conn quic.Connection) {
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			// The connection is gone or was closed by the client.
+			s.closeQUICConn(conn, DoQCodeNoError)
+			return
+		}
+		go s.serveQUICStream(stream, conn)
+	}
+}
+
+func (s *ServerQUIC) serveQUICStream(stream quic.Stream, conn quic.Connection) {
+	buf, err := readDoQMessage(stream, s.readTimeout)
+	if err != nil {
+		// Malformed length prefix or truncated message.
+		s.closeQUICConn(conn, DoQCodeProtocolError)
+		return
+	}
+
+	req := &dns.Msg{}
+	if err := req.Unpack(buf); err != nil {
+		clog.Debugf("unpacking DoQ message from %s: %s", conn.RemoteAddr(), err)
+		s.closeQUICConn(conn, DoQCodeProtocolError)
+		return
+	}
+
+	if !validDoQRequest(req) {
+		// If a peer encounters such an error condition, it is considered a fatal error. It SHOULD forcibly abort the
+		// connection using QUIC's CONNECTION_CLOSE mechanism and SHOULD use the DoQ error code DOQ_PROTOCOL_ERROR.
+		// See RFC 9250, Section 4.2.
+		s.closeQUICConn(conn, DoQCodeProtocolError)
+		return
+	}
+
+	w := &DoQWriter{
+		localAddr:  toTCPAddr(conn.LocalAddr()),
+		remoteAddr: toTCPAddr(conn.RemoteAddr()),
+		stream:     stream,
+	}
+
+	stream.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+
+	ctx := context.WithValue(context.Background(), Key{}, s.Server)
+	ctx = context.WithValue(ctx, LoopKey{}, 0)
+	s.ServeDNS(ctx, w, req)
+	s.countResponse(w.Msg)
+
+	if w.Msg == nil {
+		// Nothing was written, e.g. the query was dropped, so reset the stream instead of leaving it dangling.
+		stream.CancelWrite(quic.StreamErrorCode(DoQCodeInternalError))
+	}
+}
+
+// readDoQMessage reads a single length-prefixed DNS message from stream.
+func readDoQMessage(stream quic.Stream, timeout time.Duration) ([]byte, error) {
+	stream.SetReadDeadline(time.Now().Add(timeout))
+
+	var length uint16
+	if err := binary.Read(stream, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// validDoQRequest checks the request for the restrictions RFC 9250 puts on the message: the message
+// ID must be 0 and the edns-tcp-keepalive option must not be used.
+func validDoQRequest(req *dns.Msg) bool {
+	// See RFC 9250, Section 4.2.1.
+	if req.Id != 0 {
+		return false
+	}
+
+	// See RFC 9250, Section 5.5.2.
+	if opt := req.IsEdns0(); opt != nil {
+		for _, option := range opt.Option {
+			if option.Option() == dns.EDNS0TCPKEEPALIVE {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// closeQUICConn closes the QUIC connection with the given DoQ error code.
+func (s *ServerQUIC) closeQUICConn(conn quic.Connection, code quic.ApplicationErrorCode) {
+	if err := conn.CloseWithError(code, ""); err != nil {
+		clog.Debugf("closing DoQ connection with %s: %s", conn.RemoteAddr(), err)
+	}
+}
+
+// countResponse counts the DoQ responses written, by rcode.
+func (s *ServerQUIC) countResponse(m *dns.Msg) {
+	rc := vars.Dropped
+	if m != nil {
+		rc = dns.RcodeToString[m.Rcode]
+	}
+	vars.QUICResponsesCount.WithLabelValues(s.Addr, rc).Inc()
+}
+
+// toTCPAddr returns addr as a *net.TCPAddr. DoQ is a stream transport without the size
+// limitations of UDP, handing the plugins a TCP address makes sure responses aren't truncated.
+func toTCPAddr(addr net.Addr) net.Addr {
+	if u, ok := addr.(*net.UDPAddr); ok {
+		return &net.TCPAddr{IP: u.IP, Port: u.Port, Zone: u.Zone}
+	}
+	return addr
+}
+
+// Serve implements caddy.TCPServer interface.
+func (s *ServerQUIC) Serve(l net.Listener) error { return nil }
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerQUIC) Listen() (net.Listener, error) { return nil, nil }
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerQUIC) ListenPacket() (net.PacketConn, error) {
+	p, err := reuseport.ListenPacket("udp", s.Addr[len(transport.QUIC+"://"):])
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// OnStartupComplete lists the sites served by this server
+// and any relevant information, assuming Quiet is false.
+func (s *ServerQUIC) OnStartupComplete() {
+	if Quiet {
+		return
+	}
+
+	out := startUpZones(transport.QUIC+"://", s.Addr, s.zones)
+	if out != "" {
+		fmt.Print(out)
+	}
+}
+
+// Stop stops the server. It blocks until the server is totally stopped.
+func (s *ServerQUIC) Stop() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.quicListener != nil {
+		return s.quicListener.Close()
+	}
+	return nil
+}
+
+// Shutdown stops the server (non gracefully).
+func (s *ServerQUIC) Shutdown() error {
+	return s.Stop()
+}
+
+const (
+	// doqMaxStreams is the maximum number of concurrent queries a single client connection may have in flight.
+	doqMaxStreams = 256
+)
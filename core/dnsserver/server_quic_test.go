@@ -0,0 +1,25 @@
+package dnsserver
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestNewServerQUIC(t *testing.T) {
+	c := testConfig("quic", testPlugin{})
+	if _, err := NewServerQUIC("127.0.0.1:853", []*Config{c}); err == nil {
+		t.Error("Expected error for NewServerQUIC without TLS configuration, got none")
+	}
+
+	c.TLSConfig = &tls.Config{}
+	s, err := NewServerQUIC("127.0.0.1:853", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerQUIC, got %s", err)
+	}
+	if s.quicConfig.Allow0RTT {
+		t.Error("Expected 0-RTT to be disabled, replayed UPDATE or NOTIFY messages would be served")
+	}
+	if len(s.tlsConfig.NextProtos) != 1 || s.tlsConfig.NextProtos[0] != "doq" {
+		t.Errorf("Expected ALPN %q, got %v", "doq", s.tlsConfig.NextProtos)
+	}
+}
@@ -32,7 +32,11 @@ var Directives = []string{
 	"rewrite",
 	"local",
 	"dns64",
+	"rrl",
+	"ratelimit",
 	"acl",
+	"rpz",
+	"blocklist",
 	"any",
 	"chaos",
 	"loadbalance",
@@ -3,11 +3,14 @@ package acl
 import (
 	"context"
 	"net"
+	"regexp"
 	"strings"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
 	"github.com/coredns/coredns/plugin/metrics"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/request"
 
 	"github.com/infobloxopen/go-trees/iptree"
@@ -33,11 +36,26 @@ type action int
 
 // policy defines the ACL policy for DNS queries.
 // A policy performs the specified action (block/allow) on all DNS queries
-// matched by source IP or QTYPE.
+// matched by source IP, QTYPE, query name, EDNS options, transport and
+// metadata. All sections of a policy must match.
 type policy struct {
 	action action
 	qtypes map[uint16]struct{}
 	filter *iptree.Tree
+
+	// The optional sections below match all queries when they are empty.
+	names      []string         // query name suffixes, see matchName
+	regexes    []*regexp.Regexp // query name patterns
+	ecs        *iptree.Tree     // EDNS0 client subnet addresses
+	edns       map[uint16]struct{}
+	transports map[string]struct{}
+	metadata   []metadataMatch
+}
+
+// metadataMatch matches the value of a metadata label.
+type metadataMatch struct {
+	label  string
+	values map[string]struct{}
 }
 
 const (
@@ -67,7 +85,7 @@ RulesCheckLoop:
 			continue
 		}
 
-		action := matchWithPolicies(rule.policies, w, r)
+		action := matchWithPolicies(ctx, rule.policies, w, r)
 		switch action {
 		case actionDrop:
 			{
@@ -109,7 +127,7 @@ RulesCheckLoop:
 
 // matchWithPolicies matches the DNS query with a list of ACL polices and returns suitable
 // action against the query.
-func matchWithPolicies(policies []policy, w dns.ResponseWriter, r *dns.Msg) action {
+func matchWithPolicies(ctx context.Context, policies []policy, w dns.ResponseWriter, r *dns.Msg) action {
 	state := request.Request{W: w, Req: r}
 
 	var ip net.IP
@@ -139,12 +157,129 @@ func matchWithPolicies(policies []policy, w dns.ResponseWriter, r *dns.Msg) acti
 			continue
 		}
 
+		if !policy.matchQuery(ctx, state) {
+			continue
+		}
+
 		// matched.
 		return policy.action
 	}
 	return actionNone
 }
 
+// matchQuery returns true if the optional sections of policy match the query.
+func (p policy) matchQuery(ctx context.Context, state request.Request) bool {
+	if len(p.names) > 0 && !matchName(p.names, state.Name()) {
+		return false
+	}
+	if len(p.regexes) > 0 && !matchRegex(p.regexes, state.Name()) {
+		return false
+	}
+	if p.ecs != nil && !matchECS(p.ecs, state.Req) {
+		return false
+	}
+	if len(p.edns) > 0 && !matchEDNS(p.edns, state.Req) {
+		return false
+	}
+	if len(p.transports) > 0 && !matchTransport(p.transports, ctx, state) {
+		return false
+	}
+	for _, m := range p.metadata {
+		f := metadata.ValueFunc(ctx, m.label)
+		if f == nil {
+			return false
+		}
+		if !m.match(f()) {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns true if value is one of the values of m. The value "*" matches
+// any value that is not empty.
+func (m metadataMatch) match(value string) bool {
+	if _, ok := m.values[value]; ok {
+		return true
+	}
+	_, wildcard := m.values["*"]
+	return wildcard && value != ""
+}
+
+// matchName returns true if qname is equal to or below one of the names. A name
+// starting with "*." only matches the names below it.
+func matchName(names []string, qname string) bool {
+	for _, name := range names {
+		if strings.HasPrefix(name, "*.") {
+			if name = name[2:]; qname != name && dns.IsSubDomain(name, qname) {
+				return true
+			}
+			continue
+		}
+		if dns.IsSubDomain(name, qname) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRegex returns true if qname matches one of the regular expressions.
+func matchRegex(regexes []*regexp.Regexp, qname string) bool {
+	for _, re := range regexes {
+		if re.MatchString(qname) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchECS returns true if the query carries an EDNS0 client subnet with an
+// address in tree.
+func matchECS(tree *iptree.Tree, r *dns.Msg) bool {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return false
+	}
+	for _, o := range opt.Option {
+		if e, ok := o.(*dns.EDNS0_SUBNET); ok {
+			_, contained := tree.GetByIP(e.Address)
+			return contained
+		}
+	}
+	return false
+}
+
+// matchEDNS returns true if the query carries one of the EDNS0 options.
+func matchEDNS(options map[uint16]struct{}, r *dns.Msg) bool {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return false
+	}
+	for _, o := range opt.Option {
+		if _, ok := options[o.Option()]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTransport returns true if the query was received over one of the
+// transports. Plain DNS is matched as "dns", and as "udp" or "tcp".
+func matchTransport(transports map[string]struct{}, ctx context.Context, state request.Request) bool {
+	tr := transport.DNS
+	if scheme, _, ok := strings.Cut(metrics.WithServer(ctx), "://"); ok {
+		tr = scheme
+	}
+	if _, ok := transports[tr]; ok {
+		return true
+	}
+	if tr != transport.DNS {
+		return false
+	}
+	_, ok := transports[state.Proto()]
+	return ok
+}
+
 // Name implements the plugin.Handler interface.
 func (a ACL) Name() string {
 	return "acl"
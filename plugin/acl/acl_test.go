@@ -2,9 +2,12 @@ package acl
 
 import (
 	"context"
+	"net"
 	"testing"
 
 	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/metadata"
 	"github.com/coredns/coredns/plugin/test"
 
 	"github.com/miekg/dns"
@@ -597,3 +600,102 @@ func TestACLServeDNS(t *testing.T) {
 		})
 	}
 }
+
+func TestACLServeDNSQueryMatch(t *testing.T) {
+	const config = `acl . {
+		block type AAAA name internal transport udp net 10.0.0.0/8
+		block name *.corp.example regex ^ads\.
+		filter ecs 192.0.2.0/24
+		drop edns cookie
+		block transport tls https
+		block metadata geoip/country/code XX YY
+	}`
+
+	type args struct {
+		domain    string
+		sourceIP  string
+		qtype     uint16
+		tcp       bool
+		server    string
+		ecs       string
+		cookie    bool
+		metadata  string
+		wantRcode int
+		wantEDE   uint16
+	}
+	tests := []struct {
+		name string
+		args
+	}{
+		{"AAAA below name over UDP blocked", args{domain: "host.internal.", sourceIP: "10.1.2.3", qtype: dns.TypeAAAA, wantRcode: dns.RcodeRefused}},
+		{"AAAA name itself over UDP blocked", args{domain: "internal.", sourceIP: "10.1.2.3", qtype: dns.TypeAAAA, wantRcode: dns.RcodeRefused}},
+		{"AAAA over TCP allowed", args{domain: "host.internal.", sourceIP: "10.1.2.3", qtype: dns.TypeAAAA, tcp: true}},
+		{"A allowed", args{domain: "host.internal.", sourceIP: "10.1.2.3", qtype: dns.TypeA}},
+		{"other source allowed", args{domain: "host.internal.", sourceIP: "192.168.1.1", qtype: dns.TypeAAAA}},
+		{"other name allowed", args{domain: "host.internalx.", sourceIP: "10.1.2.3", qtype: dns.TypeAAAA}},
+		{"wildcard and regex blocked", args{domain: "ads.x.corp.example.", sourceIP: "10.1.2.3", qtype: dns.TypeA, wantRcode: dns.RcodeRefused}},
+		{"wildcard without regex allowed", args{domain: "www.corp.example.", sourceIP: "10.1.2.3", qtype: dns.TypeA}},
+		{"wildcard apex allowed", args{domain: "corp.example.", sourceIP: "10.1.2.3", qtype: dns.TypeA}},
+		{"ECS filtered", args{domain: "example.org.", sourceIP: "10.1.2.3", qtype: dns.TypeA, ecs: "192.0.2.0", wantEDE: dns.ExtendedErrorCodeFiltered}},
+		{"other ECS allowed", args{domain: "example.org.", sourceIP: "10.1.2.3", qtype: dns.TypeA, ecs: "198.51.100.0"}},
+		{"cookie dropped", args{domain: "example.org.", sourceIP: "10.1.2.3", qtype: dns.TypeA, cookie: true, wantRcode: -1}},
+		{"DoT blocked", args{domain: "example.org.", sourceIP: "10.1.2.3", qtype: dns.TypeA, server: "tls://.:853", wantRcode: dns.RcodeRefused}},
+		{"DoH blocked", args{domain: "example.org.", sourceIP: "10.1.2.3", qtype: dns.TypeA, server: "https://.:443", wantRcode: dns.RcodeRefused}},
+		{"gRPC not matched as UDP", args{domain: "host.internal.", sourceIP: "10.1.2.3", qtype: dns.TypeAAAA, server: "grpc://.:443"}},
+		{"metadata blocked", args{domain: "example.org.", sourceIP: "10.1.2.3", qtype: dns.TypeA, metadata: "YY", wantRcode: dns.RcodeRefused}},
+		{"other metadata allowed", args{domain: "example.org.", sourceIP: "10.1.2.3", qtype: dns.TypeA, metadata: "ZZ"}},
+	}
+
+	a, err := parse(caddy.NewTestController("dns", config))
+	if err != nil {
+		t.Fatalf("Cannot parse acl from config: %v", err)
+	}
+	a.Next = test.NextHandler(dns.RcodeSuccess, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.server != "" {
+				ctx = context.WithValue(ctx, dnsserver.Key{}, &dnsserver.Server{Addr: tt.server})
+			}
+			if tt.metadata != "" {
+				ctx = metadata.ContextWithMetadata(ctx)
+				metadata.SetValueFunc(ctx, "geoip/country/code", func() string { return tt.metadata })
+			}
+
+			w := &testResponseWriter{}
+			w.setRemoteIP(tt.sourceIP)
+			w.TCP = tt.tcp
+			m := new(dns.Msg)
+			m.SetQuestion(tt.domain, tt.qtype)
+			if tt.ecs != "" || tt.cookie {
+				m.SetEdns0(4096, false)
+				opt := m.IsEdns0()
+				if tt.ecs != "" {
+					opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP(tt.ecs)})
+				}
+				if tt.cookie {
+					opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0123456789abcdef"})
+				}
+			}
+
+			if _, err := a.ServeDNS(ctx, w, m); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if tt.wantRcode == -1 {
+				if w.Msg != nil {
+					t.Error("Expected no response")
+				}
+				return
+			}
+			if w.Rcode != tt.wantRcode {
+				t.Errorf("Expected rcode %s, got %s", dns.RcodeToString[tt.wantRcode], dns.RcodeToString[w.Rcode])
+			}
+			if tt.wantEDE != 0 {
+				if opt := w.Msg.IsEdns0(); opt == nil || len(opt.Option) == 0 || opt.Option[0].(*dns.EDNS0_EDE).InfoCode != tt.wantEDE {
+					t.Errorf("Expected Extended DNS Error %d", tt.wantEDE)
+				}
+			}
+		})
+	}
+}
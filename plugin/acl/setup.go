@@ -2,11 +2,14 @@ package acl
 
 import (
 	"net"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 
 	"github.com/infobloxopen/go-trees/iptree"
 	"github.com/miekg/dns"
@@ -71,13 +74,13 @@ func parse(c *caddy.Controller) (ACL, error) {
 			remainingTokens := c.RemainingArgs()
 			for len(remainingTokens) > 0 {
 				if !isPreservedIdentifier(remainingTokens[0]) {
-					return a, c.Errf("unexpected token %q; expect 'type | net'", remainingTokens[0])
+					return a, c.Errf("unexpected token %q; expect %s", remainingTokens[0], sectionsHelp)
 				}
 				section := strings.ToLower(remainingTokens[0])
 
 				i := 1
 				var tokens []string
-				for ; i < len(remainingTokens) && !isPreservedIdentifier(remainingTokens[i]); i++ {
+				for ; i < len(remainingTokens) && !endsSection(section, remainingTokens[i]); i++ {
 					tokens = append(tokens, remainingTokens[i])
 				}
 				remainingTokens = remainingTokens[i:]
@@ -114,8 +117,79 @@ func parse(c *caddy.Controller) (ACL, error) {
 						}
 						p.filter.InplaceInsertNet(source, struct{}{})
 					}
+				case "name":
+					for _, token := range tokens {
+						wildcard := strings.HasPrefix(token, "*.")
+						name := plugin.Host(strings.TrimPrefix(token, "*.")).NormalizeExact()
+						if len(name) == 0 {
+							return a, c.Errf("illegal name %q", token)
+						}
+						if wildcard {
+							name[0] = "*." + name[0]
+						}
+						p.names = append(p.names, name[0])
+					}
+				case "regex":
+					for _, token := range tokens {
+						re, err := regexp.Compile(token)
+						if err != nil {
+							return a, c.Errf("illegal regular expression %q: %v", token, err)
+						}
+						p.regexes = append(p.regexes, re)
+					}
+				case "ecs":
+					if p.ecs == nil {
+						p.ecs = iptree.NewTree()
+					}
+					for _, token := range tokens {
+						if token == "*" {
+							p.ecs = newDefaultFilter()
+							break
+						}
+						token = normalize(token)
+						_, source, err := net.ParseCIDR(token)
+						if err != nil {
+							return a, c.Errf("illegal CIDR notation %q", token)
+						}
+						p.ecs.InplaceInsertNet(source, struct{}{})
+					}
+				case "edns":
+					if p.edns == nil {
+						p.edns = make(map[uint16]struct{})
+					}
+					for _, token := range tokens {
+						code, ok := ednsOptions[strings.ToLower(token)]
+						if !ok {
+							n, err := strconv.ParseUint(token, 10, 16)
+							if err != nil {
+								return a, c.Errf("unexpected token %q; expect EDNS0 option name or code", token)
+							}
+							code = uint16(n)
+						}
+						p.edns[code] = struct{}{}
+					}
+				case "transport":
+					if p.transports == nil {
+						p.transports = make(map[string]struct{})
+					}
+					for _, token := range tokens {
+						token = strings.ToLower(token)
+						if _, ok := transports[token]; !ok {
+							return a, c.Errf("unexpected token %q; expect 'udp | tcp | dns | tls | https | grpc | quic'", token)
+						}
+						p.transports[token] = struct{}{}
+					}
+				case "metadata":
+					if len(tokens) < 2 {
+						return a, c.Errf("expect a label and at least one value in %q section", section)
+					}
+					m := metadataMatch{label: tokens[0], values: make(map[string]struct{})}
+					for _, token := range tokens[1:] {
+						m.values[token] = struct{}{}
+					}
+					p.metadata = append(p.metadata, m)
 				default:
-					return a, c.Errf("unexpected token %q; expect 'type | net'", section)
+					return a, c.Errf("unexpected token %q; expect %s", section, sectionsHelp)
 				}
 			}
 
@@ -136,9 +210,56 @@ func parse(c *caddy.Controller) (ACL, error) {
 	return a, nil
 }
 
+// sections are the sections of a policy.
+var sections = map[string]struct{}{
+	"type":      {},
+	"net":       {},
+	"name":      {},
+	"regex":     {},
+	"ecs":       {},
+	"edns":      {},
+	"transport": {},
+	"metadata":  {},
+}
+
+const sectionsHelp = "'type | net | name | regex | ecs | edns | transport | metadata'"
+
+// ednsOptions maps the names accepted in the edns section to their option codes.
+var ednsOptions = map[string]uint16{
+	"nsid":      dns.EDNS0NSID,
+	"ecs":       dns.EDNS0SUBNET,
+	"expire":    dns.EDNS0EXPIRE,
+	"cookie":    dns.EDNS0COOKIE,
+	"keepalive": dns.EDNS0TCPKEEPALIVE,
+	"padding":   dns.EDNS0PADDING,
+	"ede":       dns.EDNS0EDE,
+}
+
+// transports are the transports accepted in the transport section.
+var transports = map[string]struct{}{
+	"udp":           {},
+	"tcp":           {},
+	transport.DNS:   {},
+	transport.TLS:   {},
+	transport.HTTPS: {},
+	transport.GRPC:  {},
+	transport.QUIC:  {},
+}
+
 func isPreservedIdentifier(token string) bool {
-	identifier := strings.ToLower(token)
-	return identifier == "type" || identifier == "net"
+	_, ok := sections[strings.ToLower(token)]
+	return ok
+}
+
+// endsSection returns true if token starts a new section, and so ends section. In the edns section the
+// option names are not section keywords, "edns ecs" matches the ECS option.
+func endsSection(section, token string) bool {
+	if section == "edns" {
+		if _, ok := ednsOptions[strings.ToLower(token)]; ok {
+			return false
+		}
+	}
+	return isPreservedIdentifier(token)
 }
 
 // normalize appends '/32' for any single IPv4 address and '/128' for IPv6.
@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/coredns/caddy"
+
+	"github.com/miekg/dns"
 )
 
 func TestSetup(t *testing.T) {
@@ -227,6 +229,80 @@ func TestSetup(t *testing.T) {
 			}`,
 			true,
 		},
+		// Query matching tests.
+		{
+			"Name and transport",
+			`acl {
+				block type AAAA name internal *.corp.example transport udp net 10.0.0.0/8
+			}`,
+			false,
+		},
+		{
+			"Regex, ECS and EDNS",
+			`acl {
+				block regex ^ads\. ecs 192.0.2.0/24 2001:db8::/32
+				drop edns cookie 65001
+			}`,
+			false,
+		},
+		{
+			"EDNS ECS option",
+			`acl {
+				block edns ecs
+				drop edns nsid ecs type A
+			}`,
+			false,
+		},
+		{
+			"Metadata",
+			`acl {
+				allow metadata geoip/country/code NL BE
+				block metadata geoip/country/code *
+			}`,
+			false,
+		},
+		{
+			"Illegal regex",
+			`acl {
+				block regex (ads
+			}`,
+			true,
+		},
+		{
+			"Illegal ECS",
+			`acl {
+				block ecs 192.0.2.0/33
+			}`,
+			true,
+		},
+		{
+			"Illegal EDNS option",
+			`acl {
+				block edns magic
+			}`,
+			true,
+		},
+		{
+			"Illegal transport",
+			`acl {
+				block transport carrier-pigeon
+			}`,
+			true,
+		},
+		{
+			"Metadata without value",
+			`acl {
+				block metadata geoip/country/code
+			}`,
+			true,
+		},
+		{
+			"Empty name section",
+			`acl {
+				block name type A
+			}`,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -238,6 +314,23 @@ func TestSetup(t *testing.T) {
 	}
 }
 
+func TestSetupEDNSECS(t *testing.T) {
+	ctr := caddy.NewTestController("dns", `acl {
+		block edns ecs type A
+	}`)
+	a, err := parse(ctr)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	p := a.Rules[0].policies[0]
+	if _, ok := p.edns[dns.EDNS0SUBNET]; !ok || len(p.edns) != 1 {
+		t.Errorf("Expected the ECS option in the edns section, got %v", p.edns)
+	}
+	if _, ok := p.qtypes[dns.TypeA]; !ok {
+		t.Errorf("Expected the type section after the edns section, got %v", p.qtypes)
+	}
+}
+
 func TestNormalize(t *testing.T) {
 	type args struct {
 		rawNet string
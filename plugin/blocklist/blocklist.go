@@ -0,0 +1,181 @@
+// Package blocklist implements a plugin that blocks names from (large) lists of domains.
+package blocklist
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// action is how a blocked query is answered.
+type action int
+
+const (
+	// actionNXDOMAIN answers with NXDOMAIN.
+	actionNXDOMAIN action = iota
+	// actionSinkhole answers with the sinkhole addresses.
+	actionSinkhole
+	// actionRefuse answers with REFUSED and the Blocked extended error code.
+	actionRefuse
+)
+
+// Blocklist blocks the names in its lists.
+type Blocklist struct {
+	Next  plugin.Handler
+	Zones []string
+
+	sources []*source
+	reload  time.Duration
+
+	action    action
+	sinkhole4 net.IP
+	sinkhole6 net.IP
+	ttl       uint32
+
+	lists atomic.Pointer[lists]
+
+	mu sync.Mutex // serializes loading
+
+	stopMu sync.Mutex // protects stop
+	stop   chan struct{}
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (b *Blocklist) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	qname := state.Name()
+
+	zone := plugin.Zones(b.Zones).Matches(qname)
+	if zone == "" {
+		return plugin.NextOrFailure(b.Name(), b.Next, ctx, w, r)
+	}
+	l := b.lists.Load()
+	if l == nil || !l.blocked(qname) {
+		return plugin.NextOrFailure(b.Name(), b.Next, ctx, w, r)
+	}
+
+	blockedCount.WithLabelValues(metrics.WithServer(ctx), zone, metrics.WithView(ctx)).Inc()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.RecursionAvailable = true
+
+	switch b.action {
+	case actionNXDOMAIN:
+		m.Rcode = dns.RcodeNameError
+	case actionRefuse:
+		m.Rcode = dns.RcodeRefused
+		// The extended error can only be given to clients that use EDNS0.
+		if opt := r.IsEdns0(); opt != nil {
+			m.SetEdns0(opt.UDPSize(), opt.Do())
+			ede := dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeBlocked}
+			m.IsEdns0().Option = append(m.IsEdns0().Option, &ede)
+		}
+	case actionSinkhole:
+		hdr := dns.RR_Header{Name: state.QName(), Rrtype: state.QType(), Class: dns.ClassINET, Ttl: b.ttl}
+		switch {
+		case state.QType() == dns.TypeA && b.sinkhole4 != nil:
+			m.Answer = []dns.RR{&dns.A{Hdr: hdr, A: b.sinkhole4}}
+		case state.QType() == dns.TypeAAAA && b.sinkhole6 != nil:
+			m.Answer = []dns.RR{&dns.AAAA{Hdr: hdr, AAAA: b.sinkhole6}}
+		}
+	}
+
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// Name implements the plugin.Handler interface.
+func (b *Blocklist) Name() string { return "blocklist" }
+
+// load updates the sources that changed, and swaps in their new tries when any of them did. A source that
+// fails to update keeps its previous entries.
+func (b *Blocklist) load(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changed := false
+	for _, s := range b.sources {
+		ok, err := s.update(ctx)
+		if err != nil {
+			log.Warningf("Failed to read blocklist %s: %s", s.location, err)
+			loadFailures.WithLabelValues(s.location).Inc()
+			continue
+		}
+		if ok {
+			log.Debugf("Read %d names from blocklist %s", s.trie.len, s.location)
+			blocklistEntries.WithLabelValues(s.location).Set(float64(s.trie.len))
+			blocklistReloadTime.WithLabelValues(s.location).Set(float64(time.Now().UnixNano()) / 1e9)
+			changed = true
+		}
+	}
+	if !changed && b.lists.Load() != nil {
+		return
+	}
+
+	l := make(lists, 0, len(b.sources))
+	n := 0
+	for _, s := range b.sources {
+		if s.trie != nil {
+			l = append(l, s.trie)
+			n += s.trie.len
+		}
+	}
+
+	b.lists.Store(&l)
+	log.Infof("Loaded blocklist with %d names", n)
+}
+
+// OnStartup loads the lists and starts reloading them every b.reload.
+func (b *Blocklist) OnStartup() error {
+	b.load(context.Background())
+
+	b.stopMu.Lock()
+	defer b.stopMu.Unlock()
+	if b.reload == 0 || b.stop != nil {
+		return nil
+	}
+	stop := make(chan struct{})
+	b.stop = stop
+
+	go func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-stop
+			cancel()
+		}()
+
+		tick := time.NewTicker(b.reload)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				b.load(ctx)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// OnShutdown stops reloading the lists.
+func (b *Blocklist) OnShutdown() error {
+	b.stopMu.Lock()
+	stop := b.stop
+	b.stop = nil
+	b.stopMu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	return nil
+}
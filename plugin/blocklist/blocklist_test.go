@@ -0,0 +1,164 @@
+package blocklist
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestBlocklist(t *testing.T, data string) *Blocklist {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "list")
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := &Blocklist{
+		Next:    test.NextHandler(dns.RcodeSuccess, nil),
+		Zones:   []string{"."},
+		sources: []*source{newSource(name)},
+		ttl:     3600,
+	}
+	b.load(context.Background())
+	return b
+}
+
+func TestBlocklist(t *testing.T) {
+	b := newTestBlocklist(t, "ads.example.com\n||tracker.example^\n@@||ok.tracker.example^\n")
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		rcode int
+	}{
+		{"ads.example.com.", dns.TypeA, dns.RcodeNameError},
+		{"ADS.example.com.", dns.TypeAAAA, dns.RcodeNameError},
+		{"www.ads.example.com.", dns.TypeA, dns.RcodeSuccess},
+		{"tracker.example.", dns.TypeA, dns.RcodeNameError},
+		{"x.tracker.example.", dns.TypeTXT, dns.RcodeNameError},
+		{"ok.tracker.example.", dns.TypeA, dns.RcodeSuccess},
+		{"example.com.", dns.TypeA, dns.RcodeSuccess},
+	}
+
+	for _, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := b.ServeDNS(context.Background(), rec, m); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		if rec.Rcode != tc.rcode {
+			t.Errorf("Query %s: expected rcode %s, got %s", tc.qname, dns.RcodeToString[tc.rcode], dns.RcodeToString[rec.Rcode])
+		}
+	}
+}
+
+func TestBlocklistZones(t *testing.T) {
+	b := newTestBlocklist(t, "ads.example.com\nads.example.org\n")
+	b.Zones = []string{"example.org."}
+
+	for qname, rcode := range map[string]int{"ads.example.com.": dns.RcodeSuccess, "ads.example.org.": dns.RcodeNameError} {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		b.ServeDNS(context.Background(), rec, m)
+		if rec.Rcode != rcode {
+			t.Errorf("Query %s: expected rcode %s, got %s", qname, dns.RcodeToString[rcode], dns.RcodeToString[rec.Rcode])
+		}
+	}
+}
+
+func TestBlocklistSinkhole(t *testing.T) {
+	b := newTestBlocklist(t, "ads.example.com\n")
+	b.action = actionSinkhole
+	b.sinkhole4 = net.ParseIP("0.0.0.0").To4()
+	b.sinkhole6 = net.ParseIP("::")
+	b.ttl = 60
+
+	tests := []struct {
+		qtype  uint16
+		answer []dns.RR
+	}{
+		{dns.TypeA, []dns.RR{test.A("ads.example.com. 60 IN A 0.0.0.0")}},
+		{dns.TypeAAAA, []dns.RR{test.AAAA("ads.example.com. 60 IN AAAA ::")}},
+		{dns.TypeMX, nil},
+	}
+	for _, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("ads.example.com.", tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		b.ServeDNS(context.Background(), rec, m)
+		if err := test.SortAndCheck(rec.Msg, test.Case{Qname: "ads.example.com.", Qtype: tc.qtype, Answer: tc.answer}); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestBlocklistRefuse(t *testing.T) {
+	b := newTestBlocklist(t, "ads.example.com\n")
+	b.action = actionRefuse
+
+	m := new(dns.Msg)
+	m.SetQuestion("ads.example.com.", dns.TypeA)
+	m.SetEdns0(1232, false)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	b.ServeDNS(context.Background(), rec, m)
+	if rec.Rcode != dns.RcodeRefused {
+		t.Fatalf("Expected REFUSED, got %s", dns.RcodeToString[rec.Rcode])
+	}
+	opt := rec.Msg.IsEdns0()
+	if opt == nil || len(opt.Option) != 1 {
+		t.Fatal("Expected an extended DNS error")
+	}
+	if ede, ok := opt.Option[0].(*dns.EDNS0_EDE); !ok || ede.InfoCode != dns.ExtendedErrorCodeBlocked {
+		t.Errorf("Expected Blocked extended error, got %v", opt.Option[0])
+	}
+
+	// Without EDNS0 in the query, there is none in the reply.
+	m = new(dns.Msg)
+	m.SetQuestion("ads.example.com.", dns.TypeA)
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	b.ServeDNS(context.Background(), rec, m)
+	if rec.Rcode != dns.RcodeRefused {
+		t.Fatalf("Expected REFUSED, got %s", dns.RcodeToString[rec.Rcode])
+	}
+	if rec.Msg.IsEdns0() != nil {
+		t.Errorf("Expected no OPT record in the reply, got %v", rec.Msg.IsEdns0())
+	}
+}
+
+func TestBlocklistReload(t *testing.T) {
+	b := newTestBlocklist(t, "ads.example.com\n")
+	first := b.lists.Load()
+
+	b.load(context.Background())
+	if b.lists.Load() != first {
+		t.Error("Expected lists not to be rebuilt when they did not change")
+	}
+
+	name := b.sources[0].location
+	if err := os.WriteFile(name, []byte("ads.example.com\nmore.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b.load(context.Background())
+	if tr := (*b.lists.Load())[0]; tr.len != 2 {
+		t.Errorf("Expected 2 names after reload, got %d", tr.len)
+	}
+	if x := testutil.ToFloat64(blocklistEntries.WithLabelValues(name)); x != 2 {
+		t.Errorf("Expected 2 entries for %s, got %f", name, x)
+	}
+
+	// A list that can't be read keeps its previous entries.
+	os.Remove(name)
+	b.load(context.Background())
+	if l := b.lists.Load(); (*l)[0].len != 2 || !l.blocked("more.example.com.") {
+		t.Errorf("Expected previous names to be kept, got %d", (*l)[0].len)
+	}
+}
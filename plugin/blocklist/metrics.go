@@ -0,0 +1,39 @@
+package blocklist
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// blockedCount is the number of queries that were blocked.
+	blockedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "blocklist",
+		Name:      "blocked_requests_total",
+		Help:      "Counter of DNS requests being blocked.",
+	}, []string{"server", "zone", "view"})
+	// blocklistEntries is the number of names in a list.
+	blocklistEntries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "blocklist",
+		Name:      "entries",
+		Help:      "The number of names in a list.",
+	}, []string{"source"})
+	// blocklistReloadTime is the timestamp of the last time a list was read.
+	blocklistReloadTime = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "blocklist",
+		Name:      "reload_timestamp_seconds",
+		Help:      "The timestamp of the last reload of a list.",
+	}, []string{"source"})
+	// loadFailures is the number of times a list failed to load.
+	loadFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "blocklist",
+		Name:      "load_failures_total",
+		Help:      "Counter of failures to read a list.",
+	}, []string{"source"})
+)
@@ -0,0 +1,130 @@
+package blocklist
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+)
+
+var log = clog.NewWithPlugin("blocklist")
+
+func init() { plugin.Register("blocklist", setup) }
+
+func setup(c *caddy.Controller) error {
+	b, err := blocklistParse(c)
+	if err != nil {
+		return plugin.Error("blocklist", err)
+	}
+
+	c.OnStartup(b.OnStartup)
+	c.OnShutdown(b.OnShutdown)
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		b.Next = next
+		return b
+	})
+
+	return nil
+}
+
+func blocklistParse(c *caddy.Controller) (*Blocklist, error) {
+	config := dnsserver.GetConfig(c)
+
+	b := &Blocklist{reload: 5 * time.Minute, ttl: 3600}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		b.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "list":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					s := newSource(a)
+					if !s.isURL && !filepath.IsAbs(a) && config.Root != "" {
+						s.location = filepath.Join(config.Root, a)
+					}
+					b.sources = append(b.sources, s)
+				}
+			case "response":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case "nxdomain", "refuse":
+					if len(args) != 1 {
+						return nil, c.ArgErr()
+					}
+					b.action = actionNXDOMAIN
+					if args[0] == "refuse" {
+						b.action = actionRefuse
+					}
+				case "sinkhole":
+					if len(args) < 2 || len(args) > 3 {
+						return nil, c.ArgErr()
+					}
+					b.action = actionSinkhole
+					for _, a := range args[1:] {
+						ip := net.ParseIP(a)
+						switch {
+						case ip == nil:
+							return nil, c.Errf("invalid sinkhole address '%s'", a)
+						case ip.To4() != nil:
+							b.sinkhole4 = ip.To4()
+						default:
+							b.sinkhole6 = ip
+						}
+					}
+				default:
+					return nil, c.Errf("unknown response '%s'", args[0])
+				}
+			case "ttl":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				ttl, err := strconv.Atoi(args[0])
+				if err != nil || ttl < 0 || ttl > 65535 {
+					return nil, c.Errf("invalid ttl '%s'", args[0])
+				}
+				b.ttl = uint32(ttl)
+			case "reload":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, c.Errf("invalid duration for reload '%s'", args[0])
+				}
+				if d < 0 {
+					return nil, c.Errf("invalid negative duration for reload '%s'", args[0])
+				}
+				b.reload = d
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	if len(b.sources) == 0 {
+		return nil, c.Err("at least one list is required")
+	}
+	return b, nil
+}
@@ -0,0 +1,64 @@
+package blocklist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input          string
+		shouldErr      bool
+		expectedAction action
+		expectedReload time.Duration
+		expectedTTL    uint32
+		expectedLists  int
+	}{
+		{"blocklist {\n list /etc/blocklist\n}", false, actionNXDOMAIN, 5 * time.Minute, 3600, 1},
+		{"blocklist example.org {\n list /etc/blocklist https://example.net/hosts\n list /etc/other\n reload 1h\n}", false, actionNXDOMAIN, time.Hour, 3600, 3},
+		{"blocklist {\n list /etc/blocklist\n response refuse\n reload 0\n}", false, actionRefuse, 0, 3600, 1},
+		{"blocklist {\n list /etc/blocklist\n response sinkhole 0.0.0.0 ::\n ttl 60\n}", false, actionSinkhole, 5 * time.Minute, 60, 1},
+		// fails
+		{"blocklist", true, 0, 0, 0, 0},
+		{"blocklist {\n list\n}", true, 0, 0, 0, 0},
+		{"blocklist {\n list /etc/blocklist\n response\n}", true, 0, 0, 0, 0},
+		{"blocklist {\n list /etc/blocklist\n response nxdomain 1.2.3.4\n}", true, 0, 0, 0, 0},
+		{"blocklist {\n list /etc/blocklist\n response sinkhole\n}", true, 0, 0, 0, 0},
+		{"blocklist {\n list /etc/blocklist\n response sinkhole example.org\n}", true, 0, 0, 0, 0},
+		{"blocklist {\n list /etc/blocklist\n response drop\n}", true, 0, 0, 0, 0},
+		{"blocklist {\n list /etc/blocklist\n ttl -1\n}", true, 0, 0, 0, 0},
+		{"blocklist {\n list /etc/blocklist\n reload fast\n}", true, 0, 0, 0, 0},
+		{"blocklist {\n list /etc/blocklist\n reload -1s\n}", true, 0, 0, 0, 0},
+		{"blocklist {\n list /etc/blocklist\n allow example.org\n}", true, 0, 0, 0, 0},
+		{"blocklist {\n list /etc/blocklist\n}\nblocklist {\n list /etc/blocklist\n}", true, 0, 0, 0, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		b, err := blocklistParse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, tc.input, err)
+			continue
+		}
+		if b.action != tc.expectedAction {
+			t.Errorf("Test %d: expected action %d, got %d", i, tc.expectedAction, b.action)
+		}
+		if b.reload != tc.expectedReload {
+			t.Errorf("Test %d: expected reload %s, got %s", i, tc.expectedReload, b.reload)
+		}
+		if b.ttl != tc.expectedTTL {
+			t.Errorf("Test %d: expected ttl %d, got %d", i, tc.expectedTTL, b.ttl)
+		}
+		if len(b.sources) != tc.expectedLists {
+			t.Errorf("Test %d: expected %d lists, got %d", i, tc.expectedLists, len(b.sources))
+		}
+	}
+}
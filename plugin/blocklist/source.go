@@ -0,0 +1,219 @@
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// source is a list of names, read from a file or retrieved from a URL.
+type source struct {
+	location string // path or URL
+	isURL    bool
+
+	trie *trie // the entries from the last successful read
+
+	// Used to detect changes.
+	mtime        time.Time
+	size         int64
+	etag         string
+	lastModified string
+}
+
+func newSource(location string) *source {
+	isURL := strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+	return &source{location: location, isURL: isURL}
+}
+
+// fetchTimeout is the timeout for retrieving a list from a URL.
+const fetchTimeout = 1 * time.Minute
+
+var client = &http.Client{Timeout: fetchTimeout}
+
+// update reads the source again if it changed since the last read, and builds the trie of its entries. It
+// returns true if the entries changed.
+func (s *source) update(ctx context.Context) (bool, error) {
+	if s.isURL {
+		return s.updateURL(ctx)
+	}
+	return s.updateFile()
+}
+
+func (s *source) updateFile() (bool, error) {
+	f, err := os.Open(s.location)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if s.mtime.Equal(stat.ModTime()) && s.size == stat.Size() {
+		return false, nil
+	}
+
+	entries, err := parse(f)
+	if err != nil {
+		return false, err
+	}
+	s.trie = newTrie(entries)
+	s.mtime = stat.ModTime()
+	s.size = stat.Size()
+	return true, nil
+}
+
+func (s *source) updateURL(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.location, nil)
+	if err != nil {
+		return false, err
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	if s.lastModified != "" {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	entries, err := parse(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	s.trie = newTrie(entries)
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	return true, nil
+}
+
+// maxLineLen is the longest line we read from a list, longer lines are an error.
+const maxLineLen = 64 * 1024
+
+// parse reads a list. The format is detected for every line, so lists in different formats can be
+// combined. The supported formats are:
+//
+//   - hosts files: "0.0.0.0 example.com www.example.com" blocks the names, the address is ignored.
+//   - plain lists: "example.com" blocks the name, "*.example.com" blocks all names below example.com.
+//   - AdBlock-style lists: "||example.com^" blocks example.com and all names below it,
+//     "@@||example.com^" allows them again. Rules with options or paths are skipped.
+//
+// Comments start with "#" or "!".
+func parse(r io.Reader) ([]entry, error) {
+	var entries []entry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxLineLen)
+	for scanner.Scan() {
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "!") || strings.HasPrefix(fields[0], "[") {
+			continue
+		}
+
+		if net.ParseIP(fields[0]) != nil {
+			// hosts file
+			for _, f := range fields[1:] {
+				if e, ok := newEntry(f, blockName); ok {
+					entries = append(entries, e)
+				}
+			}
+			continue
+		}
+
+		if len(fields) > 1 {
+			continue
+		}
+		if e, ok := parseAdBlock(fields[0]); ok {
+			entries = append(entries, e)
+			continue
+		}
+		name, flags := fields[0], blockName
+		if strings.HasPrefix(name, "*.") {
+			name, flags = name[2:], blockSubtree
+		}
+		if e, ok := newEntry(name, flags); ok {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// stripComment removes a comment from line. A "#" only starts a comment at the start of the line or after
+// white space, so AdBlock element hiding rules such as "example.com##.ad" are not mistaken for a name.
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseAdBlock parses a "||example.com^" or "@@||example.com^" rule.
+func parseAdBlock(rule string) (entry, bool) {
+	flags := blockName | blockSubtree
+	if strings.HasPrefix(rule, "@@") {
+		rule = rule[2:]
+		flags = allowName | allowSubtree
+	}
+	if !strings.HasPrefix(rule, "||") {
+		return entry{}, false
+	}
+	rule = rule[2:]
+	rule = strings.TrimSuffix(rule, "$important")
+	if !strings.HasSuffix(rule, "^") {
+		return entry{}, false
+	}
+	return newEntry(rule[:len(rule)-1], flags)
+}
+
+// ignored holds the names found in the default hosts files of many systems, which are never blocked.
+var ignored = map[string]bool{
+	"localhost.":             true,
+	"localhost.localdomain.": true,
+	"local.":                 true,
+	"broadcasthost.":         true,
+	"ip6-localhost.":         true,
+	"ip6-loopback.":          true,
+	"ip6-localnet.":          true,
+	"ip6-mcastprefix.":       true,
+	"ip6-allnodes.":          true,
+	"ip6-allrouters.":        true,
+	"ip6-allhosts.":          true,
+}
+
+// newEntry returns the entry for name, if name is a valid domain name that can be blocked.
+func newEntry(name string, flags uint8) (entry, bool) {
+	if net.ParseIP(name) != nil {
+		return entry{}, false
+	}
+	name = strings.ToLower(dns.Fqdn(name))
+	if name == "." || ignored[name] {
+		return entry{}, false
+	}
+	if _, ok := dns.IsDomainName(name); !ok || strings.ContainsAny(name, `/*^$|#@\`) {
+		return entry{}, false
+	}
+	return entry{name: name, flags: flags}, true
+}
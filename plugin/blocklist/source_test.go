@@ -0,0 +1,125 @@
+package blocklist
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const listData = `# hosts file
+127.0.0.1 localhost
+0.0.0.0 ads.example.com tracker.example.com # trailing comment
+::1 ip6-localhost
+0.0.0.0 0.0.0.0
+
+! AdBlock style
+[Adblock Plus 2.0]
+||doubleclick.example^
+@@||good.doubleclick.example^
+||important.example^$important
+||path.example/ads^
+example.org##.banner
+
+Malware.Example.NET
+*.wild.example
+bad name here
+`
+
+func TestParse(t *testing.T) {
+	entries, err := parse(strings.NewReader(listData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []entry{
+		{"ads.example.com.", blockName},
+		{"tracker.example.com.", blockName},
+		{"doubleclick.example.", blockName | blockSubtree},
+		{"good.doubleclick.example.", allowName | allowSubtree},
+		{"important.example.", blockName | blockSubtree},
+		{"malware.example.net.", blockName},
+		{"wild.example.", blockSubtree},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+}
+
+func TestSourceFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "list")
+	if err := os.WriteFile(name, []byte("a.example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newSource(name)
+	if changed, err := s.update(context.Background()); err != nil || !changed {
+		t.Fatalf("Expected first read to change, got %t, %v", changed, err)
+	}
+	if changed, err := s.update(context.Background()); err != nil || changed {
+		t.Fatalf("Expected unmodified file not to change, got %t, %v", changed, err)
+	}
+
+	if err := os.WriteFile(name, []byte("a.example\nb.example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := s.update(context.Background()); err != nil || !changed {
+		t.Fatalf("Expected modified file to change, got %t, %v", changed, err)
+	}
+	if s.trie.len != 2 {
+		t.Errorf("Expected 2 entries, got %d", s.trie.len)
+	}
+
+	os.Remove(name)
+	if _, err := s.update(context.Background()); err == nil {
+		t.Error("Expected error for missing file")
+	}
+	if s.trie.len != 2 {
+		t.Errorf("Expected entries to be kept after a failed read, got %d", s.trie.len)
+	}
+}
+
+func TestSourceURL(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Write([]byte("||a.example^\n"))
+	}))
+	defer srv.Close()
+
+	s := newSource(srv.URL)
+	if !s.isURL {
+		t.Fatal("Expected source to be a URL")
+	}
+	if changed, err := s.update(context.Background()); err != nil || !changed {
+		t.Fatalf("Expected first fetch to change, got %t, %v", changed, err)
+	}
+	if changed, err := s.update(context.Background()); err != nil || changed {
+		t.Fatalf("Expected not modified list not to change, got %t, %v", changed, err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+	if s.trie.len != 1 {
+		t.Errorf("Expected 1 entry, got %d", s.trie.len)
+	}
+
+	bad := newSource(srv.URL + "/missing")
+	if _, err := bad.update(context.Background()); err == nil {
+		t.Error("Expected error for 404")
+	}
+}
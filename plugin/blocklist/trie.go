@@ -0,0 +1,146 @@
+package blocklist
+
+import (
+	"sort"
+	"strings"
+)
+
+// The flags of a name in the blocklist.
+const (
+	blockName    uint8 = 1 << iota // block the name itself
+	blockSubtree                   // block all names below the name
+	allowName                      // allow the name itself, overrides a block
+	allowSubtree                   // allow all names below the name, overrides a block
+)
+
+// entry is a name as read from a list, with its flags. Names are lowercased and fully qualified.
+type entry struct {
+	name  string
+	flags uint8
+}
+
+// node is a node in the trie, for a single label. The children are sorted by label and stored by value,
+// which keeps the trie compact for lists with millions of names.
+type node struct {
+	label    string
+	flags    uint8
+	children []node
+}
+
+// trie holds the blocked and allowed names, indexed by their labels from right to left.
+type trie struct {
+	root node
+	len  int // number of entries
+}
+
+// newTrie builds a trie from entries. Entries is sorted in place, which lets us build the trie by only
+// appending children. The flags of duplicate names are combined.
+func newTrie(entries []entry) *trie {
+	sort.Slice(entries, func(i, j int) bool { return compareReversed(entries[i].name, entries[j].name) < 0 })
+
+	t := &trie{}
+	for _, e := range entries {
+		n := &t.root
+		name := e.name
+		end := len(name) - 1 // skip the root label's dot
+		for end > 0 {
+			start := strings.LastIndexByte(name[:end], '.') + 1
+			label := name[start:end]
+			if c := len(n.children); c > 0 && n.children[c-1].label == label {
+				n = &n.children[c-1]
+			} else {
+				n.children = append(n.children, node{label: label})
+				n = &n.children[len(n.children)-1]
+			}
+			end = start - 1
+		}
+		if n.flags == 0 {
+			t.len++
+		}
+		n.flags |= e.flags
+	}
+	return t
+}
+
+// lists are the tries of the sources of a blocklist. Each source has its own trie, so a list that changes
+// can be rebuilt on its own, and the entries are only held once.
+type lists []*trie
+
+// blocked returns true if name is blocked. The tries are walked together, so the flags of a name are the
+// combined flags of all lists. The most specific entry decides: an entry for the name itself before an
+// entry for the closest ancestor. When a name is both blocked and allowed, it is allowed.
+func (l lists) blocked(name string) bool {
+	var buf [8]*node
+	nodes := buf[:0]
+	for _, t := range l {
+		nodes = append(nodes, &t.root)
+	}
+
+	blocked := false
+	flags := uint8(0)
+	end := len(name) - 1
+	for end > 0 {
+		start := strings.LastIndexByte(name[:end], '.') + 1
+		label := name[start:end]
+		flags = 0
+		found := nodes[:0]
+		for _, n := range nodes {
+			if c := n.child(label); c != nil {
+				found = append(found, c)
+				flags |= c.flags
+			}
+		}
+		nodes = found
+		if len(nodes) == 0 {
+			return blocked
+		}
+		end = start - 1
+		if end <= 0 {
+			break
+		}
+		// The nodes are ancestors of name.
+		switch {
+		case flags&allowSubtree != 0:
+			blocked = false
+		case flags&blockSubtree != 0:
+			blocked = true
+		}
+	}
+
+	switch {
+	case flags&allowName != 0:
+		return false
+	case flags&blockName != 0:
+		return true
+	}
+	return blocked
+}
+
+// child returns the child of n with label, or nil if there is none.
+func (n *node) child(label string) *node {
+	i := sort.Search(len(n.children), func(i int) bool { return n.children[i].label >= label })
+	if i < len(n.children) && n.children[i].label == label {
+		return &n.children[i]
+	}
+	return nil
+}
+
+// compareReversed compares the names a and b label by label, starting with the rightmost label.
+func compareReversed(a, b string) int {
+	ea, eb := len(a)-1, len(b)-1
+	for ea > 0 && eb > 0 {
+		sa := strings.LastIndexByte(a[:ea], '.') + 1
+		sb := strings.LastIndexByte(b[:eb], '.') + 1
+		if c := strings.Compare(a[sa:ea], b[sb:eb]); c != 0 {
+			return c
+		}
+		ea, eb = sa-1, sb-1
+	}
+	switch {
+	case ea > 0:
+		return 1
+	case eb > 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+package blocklist
+
+import "testing"
+
+func TestTrie(t *testing.T) {
+	tr := newTrie([]entry{
+		{"example.com.", blockName},
+		{"ads.example.net.", blockName | blockSubtree},
+		{"ok.ads.example.net.", allowName | allowSubtree},
+		{"bad.ok.ads.example.net.", blockName},
+		{"tracker.example.org.", blockSubtree},
+		{"both.example.", blockName | allowName},
+		{"example.com.", blockName},
+	})
+
+	if tr.len != 6 {
+		t.Errorf("Expected 6 names, got %d", tr.len)
+	}
+
+	tests := []struct {
+		name    string
+		blocked bool
+	}{
+		{"example.com.", true},
+		{"www.example.com.", false},
+		{"com.", false},
+		{"ads.example.net.", true},
+		{"x.ads.example.net.", true},
+		{"x.y.ads.example.net.", true},
+		{"ok.ads.example.net.", false},
+		{"x.ok.ads.example.net.", false},
+		{"bad.ok.ads.example.net.", true},
+		{"x.bad.ok.ads.example.net.", false},
+		{"example.net.", false},
+		{"tracker.example.org.", false},
+		{"x.tracker.example.org.", true},
+		{"both.example.", false},
+		{".", false},
+	}
+	for _, tc := range tests {
+		if got := (lists{tr}).blocked(tc.name); got != tc.blocked {
+			t.Errorf("Name %q: expected blocked to be %t, got %t", tc.name, tc.blocked, got)
+		}
+	}
+}
+
+func TestLists(t *testing.T) {
+	// The entries of one list are combined with the ones of the others, as if they were in one list.
+	l := lists{
+		newTrie([]entry{{"ads.example.net.", blockName | blockSubtree}, {"example.com.", blockName}}),
+		newTrie([]entry{{"ok.ads.example.net.", allowName | allowSubtree}}),
+		newTrie([]entry{{"example.com.", allowName}, {"bad.ok.ads.example.net.", blockName}}),
+	}
+
+	tests := []struct {
+		name    string
+		blocked bool
+	}{
+		{"ads.example.net.", true},
+		{"x.ads.example.net.", true},
+		{"ok.ads.example.net.", false},
+		{"x.ok.ads.example.net.", false},
+		{"bad.ok.ads.example.net.", true},
+		{"example.com.", false},
+		{"example.org.", false},
+	}
+	for _, tc := range tests {
+		if got := l.blocked(tc.name); got != tc.blocked {
+			t.Errorf("Name %q: expected blocked to be %t, got %t", tc.name, tc.blocked, got)
+		}
+	}
+}
+
+func TestCompareReversed(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"example.com.", "example.com.", 0},
+		{"a.example.com.", "example.com.", 1},
+		{"example.com.", "a.example.com.", -1},
+		{"z.example.com.", "a.example.net.", -1},
+		{"b.example.", "a.example.", 1},
+	}
+	for _, tc := range tests {
+		if got := compareReversed(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareReversed(%q, %q): expected %d, got %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/reuseport"
+
+	"github.com/miekg/dns"
+)
+
+// admin exports an HTTP endpoint to inspect and purge the entries of a cache.
+type admin struct {
+	Addr string
+
+	ln      net.Listener
+	nlSetup bool
+	mux     *http.ServeMux
+}
+
+// entry is a cache item as listed by the admin endpoint.
+type entry struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Cache string `json:"cache"` // Success or Denial
+	Rcode string `json:"rcode"`
+	TTL   int    `json:"ttl"` // remaining TTL, negative when the item is stale
+	Hits  uint64 `json:"hits"`
+}
+
+// OnStartup starts the HTTP listener for the admin endpoint of c.
+func (a *admin) OnStartup(c *Cache) error {
+	ln, err := reuseport.Listen("tcp", a.Addr)
+	if err != nil {
+		return err
+	}
+
+	a.ln = ln
+	a.mux = http.NewServeMux()
+	a.nlSetup = true
+
+	a.mux.HandleFunc("/cache/entries", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		pattern := r.URL.Query().Get("name")
+		if _, err := path.Match(pattern, ""); err != nil {
+			http.Error(w, "bad name pattern: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, c.entries(strings.ToLower(pattern)))
+	})
+
+	a.mux.HandleFunc("/cache/purge", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		var match func(name string) bool
+		switch {
+		case q.Get("name") != "":
+			name := plugin.Name(q.Get("name")).Normalize()
+			match = func(n string) bool { return n == name }
+		case q.Get("zone") != "":
+			zone := plugin.Name(q.Get("zone")).Normalize()
+			match = func(n string) bool { return dns.IsSubDomain(zone, n) }
+		case q.Get("all") == "true":
+			match = func(string) bool { return true }
+		default:
+			http.Error(w, "one of name, zone or all=true is required", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, struct {
+			Purged int `json:"purged"`
+		}{c.purge(match)})
+	})
+
+	go func() { http.Serve(a.ln, a.mux) }()
+	return nil
+}
+
+// OnShutdown stops the HTTP listener.
+func (a *admin) OnShutdown() error {
+	if !a.nlSetup {
+		return nil
+	}
+	a.ln.Close()
+	a.nlSetup = false
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// entries returns all items in the success and denial caches whose (lowercased) name matches pattern,
+// as understood by path.Match. An empty pattern matches all items.
+func (c *Cache) entries(pattern string) []entry {
+	now := c.now().UTC()
+	es := []entry{}
+	walk := func(ca *cache.Cache, class string) {
+		ca.Walk(func(items map[uint64]interface{}, key uint64) bool {
+			i, ok := items[key].(*item)
+			if !ok {
+				return true
+			}
+			name := strings.ToLower(i.Name)
+			if pattern != "" {
+				if ok, _ := path.Match(pattern, name); !ok {
+					return true
+				}
+			}
+			es = append(es, entry{
+				Name:  name,
+				Type:  dns.Type(i.QType).String(),
+				Cache: class,
+				Rcode: dns.RcodeToString[i.Rcode],
+				TTL:   i.ttl(now),
+				Hits:  atomic.LoadUint64(&i.hits),
+			})
+			return true
+		})
+	}
+	walk(c.pcache, Success)
+	walk(c.ncache, Denial)
+	return es
+}
+
+// purge removes all items from the success and denial caches for which match returns true. It returns the
+// number of items removed.
+func (c *Cache) purge(match func(name string) bool) int {
+	n := 0
+	for _, ca := range []*cache.Cache{c.pcache, c.ncache} {
+		var keys []uint64
+		ca.Walk(func(items map[uint64]interface{}, key uint64) bool {
+			if i, ok := items[key].(*item); ok && match(strings.ToLower(i.Name)) {
+				keys = append(keys, key)
+			}
+			return true
+		})
+		for _, k := range keys {
+			ca.Remove(k)
+		}
+		n += len(keys)
+	}
+	return n
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestAdmin(t *testing.T) {
+	c := New()
+	c.Next = BackendHandler()
+	for _, name := range []string{"a.example.org.", "b.example.org.", "example.net.", "a.example.org."} {
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeA)
+		c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+
+	a := &admin{Addr: "127.0.0.1:0"}
+	if err := a.OnStartup(c); err != nil {
+		t.Fatalf("Failed to start admin endpoint: %s", err)
+	}
+	defer a.OnShutdown()
+	url := "http://" + a.ln.Addr().String()
+
+	entries := func(pattern string) []entry {
+		resp, err := http.Get(url + "/cache/entries?name=" + pattern)
+		if err != nil {
+			t.Fatalf("Failed to list entries: %s", err)
+		}
+		defer resp.Body.Close()
+		var es []entry
+		if err := json.NewDecoder(resp.Body).Decode(&es); err != nil {
+			t.Fatalf("Failed to decode entries: %s", err)
+		}
+		return es
+	}
+	purge := func(query string) int {
+		resp, err := http.Post(url+"/cache/purge?"+query, "", nil)
+		if err != nil {
+			t.Fatalf("Failed to purge: %s", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		var p struct {
+			Purged int `json:"purged"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+			t.Fatalf("Failed to decode purge result: %s", err)
+		}
+		return p.Purged
+	}
+
+	if x := len(entries("")); x != 3 {
+		t.Errorf("Expected 3 entries, got %d", x)
+	}
+	es := entries("a.example.org.")
+	if len(es) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(es))
+	}
+	if es[0].Hits != 1 || es[0].Type != "A" || es[0].Cache != Success || es[0].TTL <= 0 {
+		t.Errorf("Unexpected entry: %+v", es[0])
+	}
+	if x := len(entries("*.example.org.")); x != 2 {
+		t.Errorf("Expected 2 entries, got %d", x)
+	}
+
+	if x := purge("name=A.example.org"); x != 1 {
+		t.Errorf("Expected 1 purged entry, got %d", x)
+	}
+	if x := purge("zone=org"); x != 1 {
+		t.Errorf("Expected 1 purged entry, got %d", x)
+	}
+	if x := purge("all=true"); x != 1 {
+		t.Errorf("Expected 1 purged entry, got %d", x)
+	}
+	if c.pcache.Len() != 0 {
+		t.Errorf("Expected empty cache, got %d items", c.pcache.Len())
+	}
+
+	resp, err := http.Post(url+"/cache/purge", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to purge: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
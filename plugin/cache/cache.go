@@ -10,6 +10,7 @@ import (
 	"github.com/coredns/coredns/plugin/pkg/cache"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/plugin/pkg/response"
+	"github.com/coredns/coredns/plugin/pkg/views"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -51,6 +52,22 @@ type Cache struct {
 	// Keep ttl option
 	keepttl bool
 
+	// Cache answers per EDNS0 Client Subnet scope, see RFC 7871.
+	ecs       bool
+	ecsScopes ecsScopes // scopes of the answers cached
+
+	// Snapshots of the cache on disk, nil when disabled.
+	persist *persister
+
+	// HTTP endpoint to inspect and purge the cache, nil when disabled.
+	admin *admin
+
+	// Store shared with other instances behind the in-memory caches, nil when disabled.
+	shared *shared
+
+	// Plugins that answer from per view data, the answers of their views are cached separately.
+	views []views.Selector
+
 	// Testing.
 	now func() time.Time
 }
@@ -110,6 +127,14 @@ func hash(qname string, qtype uint16, do bool) uint64 {
 	return h.Sum64()
 }
 
+// viewHash returns the key k of an answer for the queries in view.
+func viewHash(k uint64, view string) uint64 {
+	h := fnv.New64()
+	h.Write([]byte{byte(k >> 56), byte(k >> 48), byte(k >> 40), byte(k >> 32), byte(k >> 24), byte(k >> 16), byte(k >> 8), byte(k)})
+	h.Write([]byte(view))
+	return h.Sum64()
+}
+
 func computeTTL(msgTTL, minTTL, maxTTL time.Duration) time.Duration {
 	ttl := msgTTL
 	if ttl < minTTL {
@@ -127,6 +152,7 @@ type ResponseWriter struct {
 	*Cache
 	state  request.Request
 	server string // Server handling the request.
+	view   string // View of the request, see Cache.view.
 
 	do         bool // When true the original request had the DO bit set.
 	ad         bool // When true the original request had the AD bit set.
@@ -135,6 +161,8 @@ type ResponseWriter struct {
 
 	wildcardFunc func() string // function to retrieve wildcard name that synthesized the result.
 
+	ecsScope uint8 // ECS scope prefix length of the answer being cached.
+
 	pexcept []string // positive zone exceptions
 	nexcept []string // negative zone exceptions
 }
@@ -142,7 +170,7 @@ type ResponseWriter struct {
 // newPrefetchResponseWriter returns a Cache ResponseWriter to be used in
 // prefetch requests. It ensures RemoteAddr() can be called even after the
 // original connection has already been closed.
-func newPrefetchResponseWriter(server string, state request.Request, c *Cache) *ResponseWriter {
+func newPrefetchResponseWriter(server, view string, state request.Request, c *Cache) *ResponseWriter {
 	// Resolve the address now, the connection might be already closed when the
 	// actual prefetch request is made.
 	addr := state.W.RemoteAddr()
@@ -158,6 +186,7 @@ func newPrefetchResponseWriter(server string, state request.Request, c *Cache) *
 		Cache:          c,
 		state:          state,
 		server:         server,
+		view:           view,
 		do:             state.Do(),
 		prefetch:       true,
 		remoteAddr:     addr,
@@ -178,6 +207,15 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 
 	// key returns empty string for anything we don't want to cache.
 	hasKey, key := key(w.state.Name(), res, mt, w.do)
+	if hasKey && w.ecs {
+		key, w.ecsScope, hasKey = ecsKey(w.state, res, key)
+		if hasKey && w.ecsScope > 0 {
+			w.ecsScopes.add(w.ecsScope)
+		}
+	}
+	if hasKey && w.view != "" {
+		key = viewHash(key, w.view)
+	}
 
 	msgTTL := dnsutil.MinimalTTL(res, mt)
 	var duration time.Duration
@@ -208,7 +246,13 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 	ttl := uint32(duration.Seconds())
 	res.Answer = filterRRSlice(res.Answer, ttl, false)
 	res.Ns = filterRRSlice(res.Ns, ttl, false)
+	opt := res.IsEdns0()
+	ecs := w.ecs && ecsSubnet(res) != nil
 	res.Extra = filterRRSlice(res.Extra, ttl, false)
+	if ecs {
+		// Hand the client subnet with the scope of the answer back to the client.
+		res.Extra = append(res.Extra, opt)
+	}
 
 	if !w.do && !w.ad {
 		// unset AD bit if requester is not OK with DNSSEC
@@ -229,12 +273,16 @@ func (w *ResponseWriter) set(m *dns.Msg, key uint64, mt response.Type, duration
 			return
 		}
 		i := newItem(m, w.now(), duration)
+		i.ecsScope = w.ecsScope
 		if w.wildcardFunc != nil {
 			i.wildcard = w.wildcardFunc()
 		}
 		if w.pcache.Add(key, i) {
 			evictions.WithLabelValues(w.server, Success, w.zonesMetricLabel, w.viewMetricLabel).Inc()
 		}
+		if w.shared != nil {
+			w.shared.put(w.Cache, Success, key, i, duration)
+		}
 		// when pre-fetching, remove the negative cache entry if it exists
 		if w.prefetch {
 			w.ncache.Remove(key)
@@ -246,12 +294,16 @@ func (w *ResponseWriter) set(m *dns.Msg, key uint64, mt response.Type, duration
 			return
 		}
 		i := newItem(m, w.now(), duration)
+		i.ecsScope = w.ecsScope
 		if w.wildcardFunc != nil {
 			i.wildcard = w.wildcardFunc()
 		}
 		if w.ncache.Add(key, i) {
 			evictions.WithLabelValues(w.server, Denial, w.zonesMetricLabel, w.viewMetricLabel).Inc()
 		}
+		if w.shared != nil {
+			w.shared.put(w.Cache, Denial, key, i, duration)
+		}
 
 	case response.OtherError:
 		// don't cache these
@@ -305,6 +357,8 @@ const (
 
 	defaultCap = 10000 // default capacity of the cache.
 
+	defaultPersistInterval = 5 * time.Minute // default interval between cache snapshots.
+
 	// Success is the class for caching positive caching.
 	Success = "success"
 	// Denial is the class defined for negative caching.
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"hash/fnv"
+	"net"
+	"sync/atomic"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// ecsSubnet returns the EDNS0 Client Subnet option (RFC 7871) of m, or nil if there is none.
+func ecsSubnet(m *dns.Msg) *dns.EDNS0_SUBNET {
+	o := m.IsEdns0()
+	if o == nil {
+		return nil
+	}
+	for _, opt := range o.Option {
+		if e, ok := opt.(*dns.EDNS0_SUBNET); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// ecsHash returns the key for the answer to qname, qtype and do that is valid for all clients in the
+// subnet of addr with a prefix length of scope.
+func ecsHash(qname string, qtype uint16, do bool, family uint16, addr net.IP, scope uint8) uint64 {
+	h := fnv.New64()
+
+	if do {
+		h.Write(one)
+	} else {
+		h.Write(zero)
+	}
+
+	h.Write([]byte{byte(qtype >> 8)})
+	h.Write([]byte{byte(qtype)})
+	h.Write([]byte(qname))
+
+	h.Write([]byte{byte(family >> 8), byte(family), scope})
+	h.Write(ecsMask(family, addr, scope))
+	return h.Sum64()
+}
+
+// ecsMask returns addr truncated to the first scope bits.
+func ecsMask(family uint16, addr net.IP, scope uint8) net.IP {
+	if family == 1 {
+		return addr.To4().Mask(net.CIDRMask(int(scope), net.IPv4len*8))
+	}
+	return addr.To16().Mask(net.CIDRMask(int(scope), net.IPv6len*8))
+}
+
+// ecsScopes is the set of the scope prefix lengths of the answers cached, so a lookup only tries the keys of
+// scopes that can be in the cache instead of one for every prefix length the client sent.
+type ecsScopes [3]atomic.Uint64
+
+// add adds scope to the set.
+func (s *ecsScopes) add(scope uint8) {
+	b := &s[scope/64]
+	for {
+		old := b.Load()
+		if old&(1<<(scope%64)) != 0 || b.CompareAndSwap(old, old|1<<(scope%64)) {
+			return
+		}
+	}
+}
+
+// has returns true if scope is in the set.
+func (s *ecsScopes) has(scope uint8) bool { return s[scope/64].Load()&(1<<(scope%64)) != 0 }
+
+// ecsKeys returns the keys under which an answer for state can be cached when ECS-aware caching is
+// enabled. If the query carries a client subnet, there is a key for every scope prefix length in scopes the
+// answer can have, longest first; an answer is never valid for a longer prefix than the client sent,
+// see RFC 7871, Section 7.3.1. The last key is the one for answers that are shared by all clients.
+func ecsKeys(state request.Request, scopes *ecsScopes) []uint64 {
+	k := hash(state.Name(), state.QType(), state.Do())
+
+	e := ecsSubnet(state.Req)
+	if e == nil || e.SourceNetmask == 0 {
+		return []uint64{k}
+	}
+
+	var keys []uint64
+	for scope := e.SourceNetmask; scope > 0; scope-- {
+		if scopes.has(scope) {
+			keys = append(keys, ecsHash(state.Name(), state.QType(), state.Do(), e.Family, e.Address, scope))
+		}
+	}
+	return append(keys, k)
+}
+
+// ecsKey returns the key under which res should be cached, given that it would be cached under k without
+// ECS-aware caching, and the scope prefix length of the answer. The answer is cached under k when it is
+// valid for all clients. If res is not a valid answer to the client subnet in the query, false is returned.
+func ecsKey(state request.Request, res *dns.Msg, k uint64) (uint64, uint8, bool) {
+	qe := ecsSubnet(state.Req)
+	re := ecsSubnet(res)
+	if re == nil || re.SourceScope == 0 {
+		return k, 0, true
+	}
+	if qe == nil || qe.Family != re.Family || qe.SourceNetmask != re.SourceNetmask ||
+		!ecsMask(qe.Family, qe.Address, qe.SourceNetmask).Equal(ecsMask(re.Family, re.Address, re.SourceNetmask)) {
+		// RFC 7871, Section 7.3: the response must echo the family, source prefix length and address.
+		return 0, 0, false
+	}
+
+	scope := re.SourceScope
+	if scope > qe.SourceNetmask {
+		// We can't tell which longer prefixes this answer is valid for, so cache it for the prefix we sent.
+		scope = qe.SourceNetmask
+	}
+	return ecsHash(state.Name(), state.QType(), state.Do(), qe.Family, qe.Address, scope), scope, true
+}
+
+// setECS adds a client subnet option to the reply m built from the cache, echoing the client subnet in
+// the query with scope as the scope prefix length. Nothing is done when the query has no client subnet.
+func setECS(req, m *dns.Msg, scope uint8) {
+	qe := ecsSubnet(req)
+	if qe == nil {
+		return
+	}
+	e := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        qe.Family,
+		SourceNetmask: qe.SourceNetmask,
+		SourceScope:   scope,
+		Address:       qe.Address,
+	}
+	o := m.IsEdns0()
+	if o == nil {
+		o = new(dns.OPT)
+		o.Hdr.Name = "."
+		o.Hdr.Rrtype = dns.TypeOPT
+		o.SetUDPSize(dns.DefaultMsgSize)
+		m.Extra = append(m.Extra, o)
+	}
+	o.Option = append(o.Option, e)
+}
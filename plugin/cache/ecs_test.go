@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// ecsBackend returns an answer with the first address of the IPv4 client subnet in the query, and scope as the
+// scope prefix length. It counts the queries it receives in n.
+func ecsBackend(scope uint8, n *int) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		*n++
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Response, m.RecursionAvailable = true, true
+
+		addr := "127.0.0.1"
+		if e := ecsSubnet(r); e != nil {
+			if e.Family == 1 {
+				addr = ecsMask(e.Family, e.Address, e.SourceNetmask).String()
+			}
+			o := new(dns.OPT)
+			o.Hdr.Name = "."
+			o.Hdr.Rrtype = dns.TypeOPT
+			o.Option = append(o.Option, &dns.EDNS0_SUBNET{
+				Code:          dns.EDNS0SUBNET,
+				Family:        e.Family,
+				SourceNetmask: e.SourceNetmask,
+				SourceScope:   scope,
+				Address:       e.Address,
+			})
+			m.Extra = append(m.Extra, o)
+		}
+		m.Answer = []dns.RR{test.A("example.org. 60 IN A " + addr)}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func ecsQuery(subnet string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if subnet == "" {
+		return m
+	}
+	_, ipnet, _ := net.ParseCIDR(subnet)
+	ones, _ := ipnet.Mask.Size()
+	e := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, SourceNetmask: uint8(ones), Address: ipnet.IP}
+	e.Family = 1
+	if ipnet.IP.To4() == nil {
+		e.Family = 2
+	}
+	m.SetEdns0(4096, false)
+	o := m.IsEdns0()
+	o.Option = append(o.Option, e)
+	return m
+}
+
+func TestCacheECS(t *testing.T) {
+	tests := []struct {
+		scope    uint8
+		queries  []string
+		upstream int // number of queries expected upstream
+	}{
+		// Scope 0 answers are shared by all clients.
+		{0, []string{"10.0.0.0/24", "10.1.0.0/24", ""}, 1},
+		// Scope 16 answers are shared within a /16.
+		{16, []string{"10.0.0.0/24", "10.0.1.0/24", "10.1.0.0/24"}, 2},
+		// A scope longer than the source prefix is capped to the source prefix.
+		{32, []string{"10.0.0.0/24", "10.0.0.0/24", "10.0.1.0/24"}, 2},
+		// Queries without a client subnet don't get scoped answers.
+		{24, []string{"10.0.0.0/24", ""}, 2},
+		// Source prefix 0 asks for an answer that is valid for all clients.
+		{24, []string{"10.0.0.0/24", "0.0.0.0/0"}, 2},
+		{56, []string{"2001:db8::/56", "2001:db8:0:100::/56", "2001:db8::/56"}, 2},
+	}
+
+	for i, tc := range tests {
+		n := 0
+		c := New()
+		c.ecs = true
+		c.Next = ecsBackend(tc.scope, &n)
+
+		for _, q := range tc.queries {
+			rec := dnstest.NewRecorder(&test.ResponseWriter{})
+			c.ServeDNS(context.TODO(), rec, ecsQuery(q))
+			if q == "" {
+				continue
+			}
+			e := ecsSubnet(rec.Msg)
+			if e == nil {
+				t.Errorf("Test %d: expected client subnet in the reply for %s", i, q)
+			}
+		}
+		if n != tc.upstream {
+			t.Errorf("Test %d: expected %d upstream queries, got %d", i, tc.upstream, n)
+		}
+	}
+}
+
+func TestCacheECSScopeInReply(t *testing.T) {
+	n := 0
+	c := New()
+	c.ecs = true
+	c.Next = ecsBackend(16, &n)
+
+	c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), ecsQuery("10.0.0.0/24"))
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	c.ServeDNS(context.TODO(), rec, ecsQuery("10.0.1.0/24"))
+	if n != 1 {
+		t.Fatalf("Expected the second query to be served from the cache")
+	}
+	e := ecsSubnet(rec.Msg)
+	if e == nil {
+		t.Fatal("Expected client subnet in the reply")
+	}
+	if e.SourceNetmask != 24 || e.SourceScope != 16 || !e.Address.Equal(net.ParseIP("10.0.1.0")) {
+		t.Errorf("Expected 10.0.1.0/24 with scope 16, got %s/%d with scope %d", e.Address, e.SourceNetmask, e.SourceScope)
+	}
+	if a := rec.Msg.Answer[0].(*dns.A).A.String(); a != "10.0.0.0" {
+		t.Errorf("Expected cached answer 10.0.0.0, got %s", a)
+	}
+}
+
+func TestCacheECSDisabled(t *testing.T) {
+	n := 0
+	c := New()
+	c.Next = ecsBackend(24, &n)
+
+	c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), ecsQuery("10.0.0.0/24"))
+	c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), ecsQuery("10.1.0.0/24"))
+	if n != 1 {
+		t.Errorf("Expected 1 upstream query without ecs, got %d", n)
+	}
+}
+
+func TestECSKeys(t *testing.T) {
+	var scopes ecsScopes
+	state := request.Request{W: &test.ResponseWriter{}, Req: ecsQuery("2001:db8::1/128")}
+	if keys := ecsKeys(state, &scopes); len(keys) != 1 {
+		t.Errorf("Expected 1 key without cached scopes, got %d", len(keys))
+	}
+
+	scopes.add(24)
+	scopes.add(16)
+	scopes.add(100)
+	tests := []struct {
+		subnet string
+		keys   int
+	}{
+		{"10.0.0.0/32", 3},
+		{"10.0.0.0/20", 2},
+		{"10.0.0.0/8", 1},
+		{"2001:db8::1/128", 4},
+	}
+	for _, tc := range tests {
+		state := request.Request{W: &test.ResponseWriter{}, Req: ecsQuery(tc.subnet)}
+		if keys := ecsKeys(state, &scopes); len(keys) != tc.keys {
+			t.Errorf("Expected %d keys for %s, got %d", tc.keys, tc.subnet, len(keys))
+		}
+	}
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"math"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
@@ -27,6 +28,7 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 
 	now := c.now().UTC()
 	server := metrics.WithServer(ctx)
+	view := c.view(ctx, state)
 
 	// On cache refresh, we will just use the DO bit from the incoming query for the refresh since we key our cache
 	// with the query DO bit. That means two separate cache items for the query DO bit true or false. In the situation
@@ -34,17 +36,18 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	// DNSSEC RRs in the response are written to cache with the response.
 
 	ttl := 0
-	i := c.getIgnoreTTL(now, state, server)
+	i := c.getIgnoreTTL(now, state, server, view)
 	if i == nil {
-		crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server, do: do, ad: ad,
+		crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server, view: view, do: do, ad: ad,
 			nexcept: c.nexcept, pexcept: c.pexcept, wildcardFunc: wildcardFunc(ctx)}
 		return c.doRefresh(ctx, state, crr)
 	}
+	atomic.AddUint64(&i.hits, 1)
 	ttl = i.ttl(now)
 	if ttl < 0 {
 		// serve stale behavior
 		if c.verifyStale {
-			crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server, do: do}
+			crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server, view: view, do: do}
 			cw := newVerifyStaleResponseWriter(crr)
 			ret, err := c.doRefresh(ctx, state, cw)
 			if cw.refreshed {
@@ -55,12 +58,12 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		// Adjust the time to get a 0 TTL in the reply built from a stale item.
 		now = now.Add(time.Duration(ttl) * time.Second)
 		if !c.verifyStale {
-			cw := newPrefetchResponseWriter(server, state, c)
+			cw := newPrefetchResponseWriter(server, view, state, c)
 			go c.doPrefetch(ctx, state, cw, i, now)
 		}
 		servedStale.WithLabelValues(server, c.zonesMetricLabel, c.viewMetricLabel).Inc()
 	} else if c.shouldPrefetch(i, now) {
-		cw := newPrefetchResponseWriter(server, state, c)
+		cw := newPrefetchResponseWriter(server, view, state, c)
 		go c.doPrefetch(ctx, state, cw, i, now)
 	}
 
@@ -77,6 +80,9 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		now = i.stored
 	}
 	resp := i.toMsg(r, now, do, ad)
+	if c.ecs {
+		setECS(r, resp, i.ecsScope)
+	}
 	w.WriteMsg(resp)
 	return dns.RcodeSuccess, nil
 }
@@ -98,7 +104,7 @@ func (c *Cache) doPrefetch(ctx context.Context, state request.Request, cw *Respo
 	// When prefetching we loose the item i, and with it the frequency
 	// that we've gathered sofar. See we copy the frequencies info back
 	// into the new item that was stored in the cache.
-	if i1 := c.exists(state); i1 != nil {
+	if i1 := c.exists(state, cw.view); i1 != nil {
 		i1.Freq.Reset(now, i.Freq.Hits())
 	}
 }
@@ -120,37 +126,72 @@ func (c *Cache) shouldPrefetch(i *item, now time.Time) bool {
 func (c *Cache) Name() string { return "cache" }
 
 // getIgnoreTTL unconditionally returns an item if it exists in the cache.
-func (c *Cache) getIgnoreTTL(now time.Time, state request.Request, server string) *item {
-	k := hash(state.Name(), state.QType(), state.Do())
+func (c *Cache) getIgnoreTTL(now time.Time, state request.Request, server, view string) *item {
 	cacheRequests.WithLabelValues(server, c.zonesMetricLabel, c.viewMetricLabel).Inc()
 
-	if i, ok := c.ncache.Get(k); ok {
-		itm := i.(*item)
-		ttl := itm.ttl(now)
-		if itm.matches(state) && (ttl > 0 || (c.staleUpTo > 0 && -ttl < int(c.staleUpTo.Seconds()))) {
+	keys := c.keys(state, view)
+	for _, k := range keys {
+		if i, ok := c.ncache.Get(k); ok && c.usable(i.(*item), now, state) {
 			cacheHits.WithLabelValues(server, Denial, c.zonesMetricLabel, c.viewMetricLabel).Inc()
 			return i.(*item)
 		}
-	}
-	if i, ok := c.pcache.Get(k); ok {
-		itm := i.(*item)
-		ttl := itm.ttl(now)
-		if itm.matches(state) && (ttl > 0 || (c.staleUpTo > 0 && -ttl < int(c.staleUpTo.Seconds()))) {
+		if i, ok := c.pcache.Get(k); ok && c.usable(i.(*item), now, state) {
 			cacheHits.WithLabelValues(server, Success, c.zonesMetricLabel, c.viewMetricLabel).Inc()
 			return i.(*item)
 		}
 	}
+	if c.shared != nil {
+		if i, class := c.shared.get(c, now, state, server, keys); i != nil {
+			cacheHits.WithLabelValues(server, class, c.zonesMetricLabel, c.viewMetricLabel).Inc()
+			return i
+		}
+	}
 	cacheMisses.WithLabelValues(server, c.zonesMetricLabel, c.viewMetricLabel).Inc()
 	return nil
 }
 
-func (c *Cache) exists(state request.Request) *item {
-	k := hash(state.Name(), state.QType(), state.Do())
-	if i, ok := c.ncache.Get(k); ok {
-		return i.(*item)
-	}
-	if i, ok := c.pcache.Get(k); ok {
-		return i.(*item)
+// usable returns true if i answers state and has not expired, or may still be served stale.
+func (c *Cache) usable(i *item, now time.Time, state request.Request) bool {
+	ttl := i.ttl(now)
+	return i.matches(state) && (ttl > 0 || (c.staleUpTo > 0 && -ttl < int(c.staleUpTo.Seconds())))
+}
+
+func (c *Cache) exists(state request.Request, view string) *item {
+	for _, k := range c.keys(state, view) {
+		if i, ok := c.ncache.Get(k); ok {
+			return i.(*item)
+		}
+		if i, ok := c.pcache.Get(k); ok {
+			return i.(*item)
+		}
 	}
 	return nil
 }
+
+// keys returns the keys to look up an answer for state in view under, in order of preference.
+func (c *Cache) keys(state request.Request, view string) []uint64 {
+	var keys []uint64
+	if c.ecs {
+		keys = ecsKeys(state, &c.ecsScopes)
+	} else {
+		keys = []uint64{hash(state.Name(), state.QType(), state.Do())}
+	}
+	if view != "" {
+		for i := range keys {
+			keys[i] = viewHash(keys[i], view)
+		}
+	}
+	return keys
+}
+
+// view returns the views of state of the plugins that answer from per view data, or the empty string if
+// there are none.
+func (c *Cache) view(ctx context.Context, state request.Request) string {
+	view := ""
+	for _, v := range c.views {
+		if name := v.SelectView(ctx, state); name != "" {
+			view += name + ","
+		}
+	}
+	return view
+}
@@ -11,6 +11,8 @@ import (
 )
 
 type item struct {
+	hits uint64 // number of times this item was served, atomic so needs to be first for proper alignment
+
 	Name               string
 	QType              uint16
 	Rcode              int
@@ -20,6 +22,7 @@ type item struct {
 	Ns                 []dns.RR
 	Extra              []dns.RR
 	wildcard           string
+	ecsScope           uint8 // ECS scope prefix length, 0 when the answer is valid for all clients
 
 	origTTL uint32
 	stored  time.Time
@@ -64,4 +64,18 @@ var (
 		Name:      "evictions_total",
 		Help:      "The count of cache evictions.",
 	}, []string{"server", "type", "zones", "view"})
+	// sharedHits is the counter of items found in the shared cache, after missing the in-memory cache.
+	sharedHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "shared_hits_total",
+		Help:      "The count of cache hits from the shared cache.",
+	}, []string{"server", "type", "zones", "view"})
+	// sharedErrors is the counter of failed or dropped reads and writes of the shared cache.
+	sharedErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "shared_errors_total",
+		Help:      "The count of failed or dropped reads and writes of the shared cache.",
+	}, []string{"zones", "view"})
 )
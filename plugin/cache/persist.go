@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
+	"github.com/miekg/dns"
+)
+
+// persistVersion is the version of the snapshot file format, a snapshot with a different version is ignored.
+const persistVersion = 1
+
+// persistHeader is the first value in a snapshot file.
+type persistHeader struct {
+	Version int
+	Written time.Time
+}
+
+// persistItem is a cache item as stored in a snapshot file. The records are kept in wire format.
+type persistItem struct {
+	Key      uint64
+	Denial   bool // true for an item from the denial cache
+	Msg      []byte
+	Stored   time.Time
+	OrigTTL  uint32
+	Wildcard string
+	ECSScope uint8
+
+	item *item // not encoded, only used while writing the snapshot
+}
+
+// saveMu serializes writing snapshots, also across the old and new instance during a reload.
+var saveMu sync.Mutex
+
+// persister periodically writes the contents of the cache to a file, so the cache can be warmed up
+// again after a restart.
+type persister struct {
+	file     string
+	interval time.Duration
+
+	mu   sync.Mutex // protects stop
+	stop chan struct{}
+}
+
+func newPersister(file string, interval time.Duration) *persister {
+	return &persister{file: file, interval: interval}
+}
+
+// loadSnapshot reads the snapshot into c. Errors are logged: without a snapshot we just start cold.
+func (p *persister) loadSnapshot(c *Cache) {
+	n, err := p.load(c)
+	if err != nil {
+		log.Warningf("Failed to load cache snapshot from %s: %s", p.file, err)
+		return
+	}
+	if n > 0 {
+		log.Infof("Loaded %d items from cache snapshot %s", n, p.file)
+	}
+}
+
+// start starts writing snapshots of c every p.interval.
+func (p *persister) start(c *Cache) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	p.stop = stop
+
+	go func() {
+		tick := time.NewTicker(p.interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				p.saveSnapshot(c)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// shutdown stops the periodic snapshots and writes a final one.
+func (p *persister) shutdown(c *Cache) {
+	p.mu.Lock()
+	if p.stop == nil {
+		p.mu.Unlock()
+		return
+	}
+	close(p.stop)
+	p.stop = nil
+	p.mu.Unlock()
+
+	p.saveSnapshot(c)
+}
+
+func (p *persister) saveSnapshot(c *Cache) {
+	if err := p.save(c); err != nil {
+		log.Warningf("Failed to write cache snapshot to %s: %s", p.file, err)
+	}
+}
+
+// save writes the positive and negative items of c to a temporary file and renames that to p.file,
+// so that a crash never leaves a partial snapshot behind.
+func (p *persister) save(c *Cache) error {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.file), filepath.Base(p.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+
+	enc := gob.NewEncoder(tmp)
+	if err := enc.Encode(persistHeader{Version: persistVersion, Written: c.now().UTC()}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := encodeItems(enc, c.pcache, false); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := encodeItems(enc, c.ncache, true); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.file)
+}
+
+func encodeItems(enc *gob.Encoder, ca *cache.Cache, denial bool) error {
+	// Collect the items first, so that no shard is locked while we write to disk.
+	var pis []persistItem
+	ca.Walk(func(items map[uint64]interface{}, key uint64) bool {
+		if i, ok := items[key].(*item); ok {
+			pis = append(pis, persistItem{Key: key, Denial: denial, Stored: i.stored, OrigTTL: i.origTTL, Wildcard: i.wildcard, ECSScope: i.ecsScope, item: i})
+		}
+		return true
+	})
+
+	for _, pi := range pis {
+		buf, err := pi.item.toWire()
+		if err != nil {
+			// Skip items that can't be packed, they are not worth failing the whole snapshot for.
+			continue
+		}
+		pi.Msg = buf
+		if err := enc.Encode(pi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads the snapshot in p.file into c. Items that have expired, taking serve_stale into account,
+// are skipped. It returns the number of items loaded.
+func (p *persister) load(c *Cache) (int, error) {
+	f, err := os.Open(p.file)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(f)
+	var hdr persistHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return 0, err
+	}
+	if hdr.Version != persistVersion {
+		return 0, fmt.Errorf("unsupported snapshot version: %d", hdr.Version)
+	}
+
+	now := c.now().UTC()
+	n := 0
+	for {
+		var pi persistItem
+		if err := dec.Decode(&pi); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return n, err
+		}
+
+		m := new(dns.Msg)
+		if err := m.Unpack(pi.Msg); err != nil {
+			continue
+		}
+		i := newItem(m, pi.Stored, time.Duration(pi.OrigTTL)*time.Second)
+		i.wildcard = pi.Wildcard
+		i.ecsScope = pi.ECSScope
+		if i.ecsScope > 0 {
+			c.ecsScopes.add(i.ecsScope)
+		}
+		if ttl := i.ttl(now); ttl <= 0 && -ttl >= int(c.staleUpTo.Seconds()) {
+			continue
+		}
+
+		if pi.Denial {
+			c.ncache.Add(pi.Key, i)
+		} else {
+			c.pcache.Add(pi.Key, i)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// toWire packs i into a message in wire format. The record TTLs in the message have no meaning, the
+// TTL is derived from origTTL and stored when the item is used.
+func (i *item) toWire() ([]byte, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(i.Name, i.QType)
+	m.Response = true
+	m.Rcode = i.Rcode
+	m.AuthenticatedData = i.AuthenticatedData
+	m.RecursionAvailable = i.RecursionAvailable
+	m.Answer = i.Answer
+	m.Ns = i.Ns
+	m.Extra = i.Extra
+	m.Compress = true
+	return m.Pack()
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestPersist(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	c := New()
+	c.Next = ttlBackend(60)
+	ctx := context.TODO()
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	c.ServeDNS(ctx, dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	c.Next = nxDomainBackend(60)
+	nx := new(dns.Msg)
+	nx.SetQuestion("nx.example.org.", dns.TypeA)
+	c.ServeDNS(ctx, dnstest.NewRecorder(&test.ResponseWriter{}), nx)
+
+	if err := newPersister(file, time.Minute).save(c); err != nil {
+		t.Fatalf("Failed to write snapshot: %s", err)
+	}
+
+	tests := []struct {
+		futureSeconds int
+		staleUpTo     time.Duration
+		expectedItems int
+	}{
+		{10, 0, 2},
+		{70, 0, 0},
+		{70, time.Hour, 2},
+		{3700, time.Hour, 0},
+	}
+
+	for i, tt := range tests {
+		c1 := New()
+		c1.staleUpTo = tt.staleUpTo
+		c1.now = func() time.Time { return time.Now().Add(time.Duration(tt.futureSeconds) * time.Second) }
+		c1.Next = plugin.HandlerFunc(func(context.Context, dns.ResponseWriter, *dns.Msg) (int, error) {
+			return 255, nil // Below, a 255 means we tried querying upstream.
+		})
+
+		n, err := newPersister(file, time.Minute).load(c1)
+		if err != nil {
+			t.Fatalf("Test %d: failed to load snapshot: %s", i, err)
+		}
+		if n != tt.expectedItems {
+			t.Errorf("Test %d: expected %d items, got %d", i, tt.expectedItems, n)
+		}
+		if n == 0 {
+			continue
+		}
+		if c1.pcache.Len() != 1 || c1.ncache.Len() != 1 {
+			t.Errorf("Test %d: expected 1 positive and 1 negative item, got %d and %d", i, c1.pcache.Len(), c1.ncache.Len())
+		}
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if ret, _ := c1.ServeDNS(ctx, rec, req); ret != dns.RcodeSuccess {
+			t.Errorf("Test %d: expected reply from cache, got %d", i, ret)
+			continue
+		}
+		if x := rec.Msg.Answer[0].(*dns.A).A.String(); x != "127.0.0.53" {
+			t.Errorf("Test %d: expected 127.0.0.53, got %s", i, x)
+		}
+		ttl := 60 - tt.futureSeconds
+		if ttl < 0 {
+			ttl = 0
+		}
+		if x := rec.Msg.Answer[0].Header().Ttl; x != uint32(ttl) && x != uint32(ttl+1) {
+			t.Errorf("Test %d: expected TTL %d, got %d", i, ttl, x)
+		}
+	}
+}
+
+func TestPersistMissingFile(t *testing.T) {
+	c := New()
+	n, err := newPersister(filepath.Join(t.TempDir(), "missing"), time.Minute).load(c)
+	if err != nil || n != 0 {
+		t.Errorf("Expected no items and no error for a missing snapshot, got %d and %v", n, err)
+	}
+}
@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxIdleRedisConns is the number of idle connections kept open to the Redis server.
+const maxIdleRedisConns = 8
+
+// redisStore is a store that talks the Redis protocol (RESP), so any Redis compatible server can be used
+// to share cache items between CoreDNS instances.
+type redisStore struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mu   sync.Mutex // protects idle
+	idle []*redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// newRedisStore returns a store for the Redis server in u, which looks like redis://[:PASSWORD@]HOST[:PORT][/DB].
+func newRedisStore(u *url.URL, timeout time.Duration) (*redisStore, error) {
+	s := &redisStore{addr: u.Host, timeout: timeout}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		s.password, _ = u.User.Password()
+	}
+	if db := u.Path; len(db) > 1 {
+		n, err := strconv.Atoi(db[1:])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid redis database: %s", db[1:])
+		}
+		s.db = n
+	}
+	return s, nil
+}
+
+// mget implements the store interface.
+func (s *redisStore) mget(keys []string) ([][]byte, error) {
+	args := make([]string, 0, len(keys)+1)
+	args = append(args, "MGET")
+	args = append(args, keys...)
+
+	reply, err := s.do(args...)
+	if err != nil {
+		return nil, err
+	}
+	vals, ok := reply.([]interface{})
+	if !ok || len(vals) != len(keys) {
+		return nil, errors.New("redis: unexpected reply to MGET")
+	}
+	bufs := make([][]byte, len(vals))
+	for i, v := range vals {
+		bufs[i], _ = v.([]byte)
+	}
+	return bufs, nil
+}
+
+// set implements the store interface.
+func (s *redisStore) set(key string, val []byte, ttl time.Duration) error {
+	_, err := s.do("SET", key, string(val), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// close implements the store interface.
+func (s *redisStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.idle {
+		c.Close()
+	}
+	s.idle = nil
+	return nil
+}
+
+// do sends a command to the server and returns the reply. A connection is only reused when the
+// exchange succeeded, an error reply from the server included.
+func (s *redisStore) do(args ...string) (interface{}, error) {
+	c, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	c.SetDeadline(time.Now().Add(s.timeout))
+
+	reply, err := c.exchange(args...)
+	var rerr redisError
+	if err != nil && !errors.As(err, &rerr) {
+		c.Close()
+		return nil, err
+	}
+	s.put(c)
+	return reply, err
+}
+
+// conn returns an idle connection or dials a new one.
+func (s *redisStore) conn() (*redisConn, error) {
+	s.mu.Lock()
+	if n := len(s.idle); n > 0 {
+		c := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+		return c, nil
+	}
+	s.mu.Unlock()
+
+	nc, err := net.DialTimeout("tcp", s.addr, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	c.SetDeadline(time.Now().Add(s.timeout))
+
+	if s.password != "" {
+		if _, err := c.exchange("AUTH", s.password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if _, err := c.exchange("SELECT", strconv.Itoa(s.db)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (s *redisStore) put(c *redisConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.idle) >= maxIdleRedisConns {
+		c.Close()
+		return
+	}
+	s.idle = append(s.idle, c)
+}
+
+// exchange writes the command in args and reads the reply.
+func (c *redisConn) exchange(args ...string) (interface{}, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, a := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(a)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, a...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// readReply reads a single reply. Bulk strings are returned as []byte, nil bulk strings and arrays as nil.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2) // includes the trailing \r\n
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		vals := make([]interface{}, n)
+		for i := range vals {
+			if vals[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return vals, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type: %q", line[0])
+}
+
+// readLine reads a line terminated by \r\n and returns it without the terminator.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+	return line[:len(line)-2], nil
+}
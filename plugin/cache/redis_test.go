@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-process stand-in for a Redis server, it supports just enough commands for the shared cache.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu   sync.Mutex
+	data map[string]fakeRedisValue
+}
+
+type fakeRedisValue struct {
+	val     string
+	expires time.Time
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	f := &fakeRedis{ln: ln, password: password, data: map[string]fakeRedisValue{}}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeRedis) url() string {
+	if f.password != "" {
+		return "redis://:" + f.password + "@" + f.ln.Addr().String()
+	}
+	return "redis://" + f.ln.Addr().String()
+}
+
+func (f *fakeRedis) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.data)
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authed := f.password == ""
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		vals, _ := reply.([]interface{})
+		args := make([]string, len(vals))
+		for i, v := range vals {
+			b, _ := v.([]byte)
+			args[i] = string(b)
+		}
+		if len(args) == 0 {
+			return
+		}
+
+		var out string
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			if args[1] != f.password {
+				out = "-WRONGPASS invalid password\r\n"
+				break
+			}
+			authed = true
+			out = "+OK\r\n"
+		case !authed:
+			out = "-NOAUTH Authentication required.\r\n"
+		case cmd == "SELECT":
+			out = "+OK\r\n"
+		case cmd == "SET":
+			ttl, _ := strconv.Atoi(args[4])
+			f.mu.Lock()
+			f.data[args[1]] = fakeRedisValue{val: args[2], expires: time.Now().Add(time.Duration(ttl) * time.Millisecond)}
+			f.mu.Unlock()
+			out = "+OK\r\n"
+		case cmd == "MGET":
+			out = fmt.Sprintf("*%d\r\n", len(args)-1)
+			f.mu.Lock()
+			for _, k := range args[1:] {
+				v, ok := f.data[k]
+				if !ok || time.Now().After(v.expires) {
+					out += "$-1\r\n"
+					continue
+				}
+				out += fmt.Sprintf("$%d\r\n%s\r\n", len(v.val), v.val)
+			}
+			f.mu.Unlock()
+		default:
+			out = "-ERR unknown command\r\n"
+		}
+		if _, err := c.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	u, _ := url.Parse(f.url() + "/1")
+	s, err := newRedisStore(u, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create store: %s", err)
+	}
+	defer s.close()
+
+	if err := s.set("a", []byte("value\r\nwith crlf"), time.Minute); err != nil {
+		t.Fatalf("Failed to set: %s", err)
+	}
+	if err := s.set("b", []byte("gone"), time.Millisecond); err != nil {
+		t.Fatalf("Failed to set: %s", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	vals, err := s.mget([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("Failed to get: %s", err)
+	}
+	if string(vals[0]) != "value\r\nwith crlf" {
+		t.Errorf("Expected value for a, got %q", vals[0])
+	}
+	if vals[1] != nil || vals[2] != nil {
+		t.Errorf("Expected no values for b and c, got %q and %q", vals[1], vals[2])
+	}
+	if len(s.idle) != 1 {
+		t.Errorf("Expected the connection to be reused, got %d idle connections", len(s.idle))
+	}
+}
+
+func TestRedisStoreAuth(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	u, _ := url.Parse("redis://:wrong@" + f.ln.Addr().String())
+	s, _ := newRedisStore(u, time.Second)
+	defer s.close()
+
+	if _, err := s.mget([]string{"a"}); err == nil {
+		t.Fatal("Expected error with the wrong password")
+	}
+}
+
+func TestNewRedisStore(t *testing.T) {
+	tests := []struct {
+		url       string
+		shouldErr bool
+		addr      string
+		password  string
+		db        int
+	}{
+		{"redis://localhost", false, "localhost:6379", "", 0},
+		{"redis://:pw@10.0.0.1:6380/2", false, "10.0.0.1:6380", "pw", 2},
+		{"redis://[::1]/3", false, "[::1]:6379", "", 3},
+		{"redis://localhost/db", true, "", "", 0},
+	}
+	for i, tc := range tests {
+		u, _ := url.Parse(tc.url)
+		s, err := newRedisStore(u, time.Second)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if s.addr != tc.addr || s.password != tc.password || s.db != tc.db {
+			t.Errorf("Test %d: expected %s %q %d, got %s %q %d", i, tc.addr, tc.password, tc.db, s.addr, s.password, s.db)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/cache"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/views"
 )
 
 var log = clog.NewWithPlugin("cache")
@@ -26,9 +28,38 @@ func setup(c *caddy.Controller) error {
 
 	c.OnStartup(func() error {
 		ca.viewMetricLabel = dnsserver.GetConfig(c).ViewName
+		for _, h := range dnsserver.GetConfig(c).Handlers() {
+			if v, ok := h.(views.Selector); ok {
+				ca.views = append(ca.views, v)
+			}
+		}
 		return nil
 	})
 
+	if ca.persist != nil {
+		c.OnStartup(func() error {
+			ca.persist.loadSnapshot(ca)
+			ca.persist.start(ca)
+			return nil
+		})
+		// Write the snapshot before a reload, so the new instance can pick it up when it starts.
+		c.OnRestart(func() error { ca.persist.shutdown(ca); return nil })
+		c.OnRestartFailed(func() error { ca.persist.start(ca); return nil })
+		c.OnFinalShutdown(func() error { ca.persist.shutdown(ca); return nil })
+	}
+
+	if ca.admin != nil {
+		c.OnStartup(func() error { return ca.admin.OnStartup(ca) })
+		c.OnRestart(ca.admin.OnShutdown)
+		c.OnFinalShutdown(ca.admin.OnShutdown)
+		c.OnRestartFailed(func() error { return ca.admin.OnStartup(ca) })
+	}
+
+	if ca.shared != nil {
+		c.OnStartup(func() error { return ca.shared.OnStartup(ca) })
+		c.OnShutdown(ca.shared.OnShutdown)
+	}
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		ca.Next = next
 		return ca
@@ -245,6 +276,61 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 					return nil, c.ArgErr()
 				}
 				ca.keepttl = true
+			case "ecs":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				ca.ecs = true
+			case "shared":
+				// shared URL [TIMEOUT]
+				args := c.RemainingArgs()
+				if len(args) < 1 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				timeout := defaultSharedTimeout
+				if len(args) > 1 {
+					d, err := time.ParseDuration(args[1])
+					if err != nil {
+						return nil, err
+					}
+					if d <= 0 {
+						return nil, fmt.Errorf("shared cache timeout must be positive: %s", d)
+					}
+					timeout = d
+				}
+				sh, err := newShared(args[0], timeout)
+				if err != nil {
+					return nil, err
+				}
+				sh.namespace = strings.ToLower(strings.Join(c.ServerBlockKeys, ","))
+				ca.shared = sh
+			case "persist":
+				// persist FILE [INTERVAL]
+				args := c.RemainingArgs()
+				if len(args) < 1 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				interval := defaultPersistInterval
+				if len(args) > 1 {
+					d, err := time.ParseDuration(args[1])
+					if err != nil {
+						return nil, err
+					}
+					if d <= 0 {
+						return nil, fmt.Errorf("persist interval must be positive: %s", d)
+					}
+					interval = d
+				}
+				ca.persist = newPersister(args[0], interval)
+			case "admin":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				if _, _, err := net.SplitHostPort(args[0]); err != nil {
+					return nil, err
+				}
+				ca.admin = &admin{Addr: args[0]}
 			default:
 				return nil, c.ArgErr()
 			}
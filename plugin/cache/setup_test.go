@@ -260,3 +260,168 @@ func TestKeepttl(t *testing.T) {
 		}
 	}
 }
+
+func TestPersistSetup(t *testing.T) {
+	tests := []struct {
+		input            string
+		shouldErr        bool
+		expectedFile     string
+		expectedInterval time.Duration
+	}{
+		{"cache", false, "", 0},
+		{"cache {\n persist /var/lib/coredns/cache\n}", false, "/var/lib/coredns/cache", defaultPersistInterval},
+		{"cache {\n persist /var/lib/coredns/cache 30s\n}", false, "/var/lib/coredns/cache", 30 * time.Second},
+		// fails
+		{"cache {\n persist\n}", true, "", 0},
+		{"cache {\n persist /var/lib/coredns/cache 0s\n}", true, "", 0},
+		{"cache {\n persist /var/lib/coredns/cache often\n}", true, "", 0},
+		{"cache {\n persist /var/lib/coredns/cache 30s 1m\n}", true, "", 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if test.expectedFile == "" {
+			if ca.persist != nil {
+				t.Errorf("Test %v: Expected persist to be disabled", i)
+			}
+			continue
+		}
+		if ca.persist.file != test.expectedFile {
+			t.Errorf("Test %v: Expected file %s, got %s", i, test.expectedFile, ca.persist.file)
+		}
+		if ca.persist.interval != test.expectedInterval {
+			t.Errorf("Test %v: Expected interval %s, got %s", i, test.expectedInterval, ca.persist.interval)
+		}
+	}
+}
+
+func TestAdminSetup(t *testing.T) {
+	tests := []struct {
+		input        string
+		shouldErr    bool
+		expectedAddr string
+	}{
+		{"cache", false, ""},
+		{"cache {\n admin localhost:9154\n}", false, "localhost:9154"},
+		// fails
+		{"cache {\n admin\n}", true, ""},
+		{"cache {\n admin localhost\n}", true, ""},
+		{"cache {\n admin localhost:9154 localhost:9155\n}", true, ""},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if test.expectedAddr == "" {
+			if ca.admin != nil {
+				t.Errorf("Test %v: Expected admin to be disabled", i)
+			}
+			continue
+		}
+		if ca.admin.Addr != test.expectedAddr {
+			t.Errorf("Test %v: Expected address %s, got %s", i, test.expectedAddr, ca.admin.Addr)
+		}
+	}
+}
+
+func TestECSSetup(t *testing.T) {
+	tests := []struct {
+		input       string
+		shouldErr   bool
+		expectedECS bool
+	}{
+		{"cache", false, false},
+		{"cache {\n ecs\n}", false, true},
+		// fails
+		{"cache {\n ecs on\n}", true, false},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if ca.ecs != test.expectedECS {
+			t.Errorf("Test %v: Expected ecs %t but found: %t", i, test.expectedECS, ca.ecs)
+		}
+	}
+}
+
+func TestSharedSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  string
+	}{
+		{"cache", false, ""},
+		{"cache {\n shared redis://localhost:6379\n}", false, "redis://localhost:6379"},
+		{"cache {\n shared redis://:pw@localhost/1 50ms\n}", false, "redis://:xxxxx@localhost/1"},
+		// fails
+		{"cache {\n shared\n}", true, ""},
+		{"cache {\n shared memcache://localhost\n}", true, ""},
+		{"cache {\n shared redis://localhost/db\n}", true, ""},
+		{"cache {\n shared redis://localhost 0s\n}", true, ""},
+		{"cache {\n shared redis://localhost 1s 2s\n}", true, ""},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if test.expected == "" {
+			if ca.shared != nil {
+				t.Errorf("Test %v: Expected shared cache to be disabled", i)
+			}
+			continue
+		}
+		if ca.shared == nil || ca.shared.url != test.expected {
+			t.Errorf("Test %v: Expected shared cache %s", i, test.expected)
+		}
+	}
+}
+
+func TestSharedSetupNamespace(t *testing.T) {
+	c := caddy.NewTestController("dns", "cache {\n shared redis://localhost\n}")
+	c.ServerBlockKeys = []string{"Example.org:53", "example.net:53"}
+	ca, err := cacheParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if x := ca.shared.namespace; x != "example.org:53,example.net:53" {
+		t.Errorf("Expected the server block keys as namespace, got %q", x)
+	}
+}
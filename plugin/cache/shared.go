@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// store is a key value store that holds cache items shared by several CoreDNS instances. It is the second
+// level behind the in-memory success and denial caches of each instance.
+type store interface {
+	// mget returns the values for keys, with a nil value for each key that does not exist.
+	mget(keys []string) ([][]byte, error)
+	// set stores val under key, the store removes it after ttl.
+	set(key string, val []byte, ttl time.Duration) error
+	// close releases the resources held by the store.
+	close() error
+}
+
+// newStore returns the store for the URL u. Only the redis scheme is supported.
+func newStore(u *url.URL, timeout time.Duration) (store, error) {
+	switch u.Scheme {
+	case "redis":
+		return newRedisStore(u, timeout)
+	}
+	return nil, fmt.Errorf("unsupported shared cache scheme: %q", u.Scheme)
+}
+
+const (
+	defaultSharedTimeout = 100 * time.Millisecond // default timeout for talking to the shared store.
+	sharedQueueLen       = 1024                   // number of pending writes to the shared store.
+	sharedVersion        = 1                      // version of the encoding of items in the shared store.
+	minSharedBackoff     = time.Second            // time the store is skipped after an error.
+	maxSharedBackoff     = 30 * time.Second       // limit of the time the store is skipped after consecutive errors.
+)
+
+// sharedWrite is a pending write of an item to the shared store.
+type sharedWrite struct {
+	key string
+	val []byte
+	ttl time.Duration
+}
+
+// shared connects a Cache to a store shared with other CoreDNS instances. Items that miss the in-memory
+// caches are looked up in the store, and items added to the in-memory caches are also written to it.
+// Writes are done in the background, and dropped when the store can't keep up. After an error the store is
+// skipped for a while, so an unreachable store doesn't slow down every cache miss.
+type shared struct {
+	url       string // the URL of the store, without the password, for logging.
+	namespace string // the server block the cache is in, keys are only shared within it.
+	prefix    string // prefix for all keys, set on startup.
+
+	store store
+
+	failures atomic.Int64 // number of consecutive errors
+	skip     atomic.Int64 // time in unix nanoseconds until which the store is skipped
+
+	mu     sync.Mutex // protects writes
+	writes chan sharedWrite
+}
+
+func newShared(rawurl string, timeout time.Duration) (*shared, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	st, err := newStore(u, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &shared{url: u.Redacted(), store: st}, nil
+}
+
+// OnStartup starts writing items to the store of s. The keys in the store are qualified with the server
+// block and the view of c, as different server blocks and views may give different answers to the same
+// question.
+func (s *shared) OnStartup(c *Cache) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writes != nil {
+		return nil
+	}
+	s.prefix = "coredns:cache:" + s.namespace + ":" + c.viewMetricLabel + ":"
+	writes := make(chan sharedWrite, sharedQueueLen)
+	s.writes = writes
+
+	go func() {
+		for w := range writes {
+			if !s.available(time.Now()) {
+				sharedErrors.WithLabelValues(c.zonesMetricLabel, c.viewMetricLabel).Inc()
+				continue
+			}
+			if err := s.store.set(w.key, w.val, w.ttl); err != nil {
+				s.failed(time.Now())
+				sharedErrors.WithLabelValues(c.zonesMetricLabel, c.viewMetricLabel).Inc()
+				log.Debugf("Failed to write to shared cache %s: %s", s.url, err)
+				continue
+			}
+			s.succeeded()
+		}
+		s.store.close()
+	}()
+	return nil
+}
+
+// OnShutdown stops writing to the store, it is closed once the pending writes are done.
+func (s *shared) OnShutdown() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writes == nil {
+		return nil
+	}
+	close(s.writes)
+	s.writes = nil
+	return nil
+}
+
+// available returns true if the store may be used at now, i.e. we are not backing off after errors.
+func (s *shared) available(now time.Time) bool { return now.UnixNano() >= s.skip.Load() }
+
+// failed records an error talking to the store. The store is skipped for minSharedBackoff, doubled for
+// every consecutive error up to maxSharedBackoff.
+func (s *shared) failed(now time.Time) {
+	d := minSharedBackoff
+	for n := s.failures.Add(1); n > 1 && d < maxSharedBackoff; n-- {
+		d *= 2
+	}
+	if d > maxSharedBackoff {
+		d = maxSharedBackoff
+	}
+	s.skip.Store(now.Add(d).UnixNano())
+}
+
+// succeeded records a successful exchange with the store.
+func (s *shared) succeeded() {
+	if s.failures.Load() != 0 {
+		s.failures.Store(0)
+	}
+}
+
+func (s *shared) key(class string, k uint64) string {
+	return s.prefix + class + ":" + strconv.FormatUint(k, 16)
+}
+
+// put queues i for writing to the store under k, in the cache class. The item is kept in the store
+// for d plus the period we're allowed to serve it stale.
+func (s *shared) put(c *Cache, class string, k uint64, i *item, d time.Duration) {
+	val, err := i.encode()
+	if err != nil {
+		return
+	}
+	w := sharedWrite{key: s.key(class, k), val: val, ttl: d + c.staleUpTo}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writes == nil {
+		return
+	}
+	select {
+	case s.writes <- w:
+	default:
+		sharedErrors.WithLabelValues(c.zonesMetricLabel, c.viewMetricLabel).Inc()
+	}
+}
+
+// get looks up keys in the store, and returns the first usable item and its cache class. A usable item
+// is added to the in-memory cache of its class.
+func (s *shared) get(c *Cache, now time.Time, state request.Request, server string, keys []uint64) (*item, string) {
+	if !s.available(time.Now()) {
+		return nil, ""
+	}
+	skeys := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		skeys = append(skeys, s.key(Denial, k), s.key(Success, k))
+	}
+	vals, err := s.store.mget(skeys)
+	if err != nil {
+		s.failed(time.Now())
+		sharedErrors.WithLabelValues(c.zonesMetricLabel, c.viewMetricLabel).Inc()
+		log.Debugf("Failed to read from shared cache %s: %s", s.url, err)
+		return nil, ""
+	}
+	s.succeeded()
+
+	for j, val := range vals {
+		if val == nil {
+			continue
+		}
+		i, err := decodeItem(val)
+		if err != nil || !c.usable(i, now, state) {
+			continue
+		}
+		k := keys[j/2]
+		if j%2 == 0 {
+			c.ncache.Add(k, i)
+			sharedHits.WithLabelValues(server, Denial, c.zonesMetricLabel, c.viewMetricLabel).Inc()
+			return i, Denial
+		}
+		c.pcache.Add(k, i)
+		sharedHits.WithLabelValues(server, Success, c.zonesMetricLabel, c.viewMetricLabel).Inc()
+		return i, Success
+	}
+	return nil, ""
+}
+
+// encode returns i in the format used in the shared store: a version, the time the item was stored, the
+// original TTL, the ECS scope, the wildcard name and the message in wire format.
+func (i *item) encode() ([]byte, error) {
+	if len(i.wildcard) > 255 {
+		return nil, errBadItem
+	}
+	msg, err := i.toWire()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, 16+len(i.wildcard)+len(msg))
+	buf = append(buf, sharedVersion)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(i.stored.UnixNano()))
+	buf = binary.BigEndian.AppendUint32(buf, i.origTTL)
+	buf = append(buf, i.ecsScope, byte(len(i.wildcard)))
+	buf = append(buf, i.wildcard...)
+	return append(buf, msg...), nil
+}
+
+var errBadItem = errors.New("bad item in shared cache")
+
+// decodeItem returns the item encoded in buf by item.encode.
+func decodeItem(buf []byte) (*item, error) {
+	if len(buf) < 15 || buf[0] != sharedVersion {
+		return nil, errBadItem
+	}
+	stored := time.Unix(0, int64(binary.BigEndian.Uint64(buf[1:9])))
+	origTTL := binary.BigEndian.Uint32(buf[9:13])
+	scope := buf[13]
+	n := int(buf[14])
+	buf = buf[15:]
+	if len(buf) < n {
+		return nil, errBadItem
+	}
+	wildcard := string(buf[:n])
+
+	m := new(dns.Msg)
+	if err := m.Unpack(buf[n:]); err != nil {
+		return nil, err
+	}
+	i := newItem(m, stored, time.Duration(origTTL)*time.Second)
+	i.ecsScope = scope
+	i.wildcard = wildcard
+	return i, nil
+}
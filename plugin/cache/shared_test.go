@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	golog "log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func newSharedTestCache(t *testing.T, url string, next plugin.Handler) *Cache {
+	sh, err := newShared(url, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create shared cache: %s", err)
+	}
+	c := New()
+	c.shared = sh
+	c.Next = next
+	c.shared.OnStartup(c)
+	t.Cleanup(func() { c.shared.OnShutdown() })
+	return c
+}
+
+func TestSharedCache(t *testing.T) {
+	f := newFakeRedis(t, "")
+
+	n := 0
+	backend := plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		n++
+		return ttlBackend(60).ServeDNS(ctx, w, r)
+	})
+	c1 := newSharedTestCache(t, f.url(), backend)
+	c2 := newSharedTestCache(t, f.url(), backend)
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	c1.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	if n != 1 {
+		t.Fatalf("Expected 1 upstream query, got %d", n)
+	}
+
+	// The write to the shared store is done in the background.
+	for i := 0; f.len() == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	c2.ServeDNS(context.TODO(), rec, req)
+	if n != 1 {
+		t.Fatalf("Expected the second instance to answer from the shared cache, got %d upstream queries", n)
+	}
+	if len(rec.Msg.Answer) != 1 || rec.Msg.Answer[0].Header().Ttl > 60 || rec.Msg.Answer[0].Header().Ttl < 58 {
+		t.Errorf("Expected the shared answer with the remaining TTL, got %v", rec.Msg.Answer)
+	}
+	if c2.pcache.Len() != 1 {
+		t.Errorf("Expected the shared answer to be added to the in-memory cache, got %d items", c2.pcache.Len())
+	}
+}
+
+func TestSharedCacheUnavailable(t *testing.T) {
+	f := newFakeRedis(t, "")
+	f.ln.Close()
+
+	n := 0
+	c := newSharedTestCache(t, f.url(), plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		n++
+		return ttlBackend(60).ServeDNS(ctx, w, r)
+	}))
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	for i := 0; i < 2; i++ {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, req)
+		if rec.Msg == nil || len(rec.Msg.Answer) != 1 {
+			t.Fatalf("Expected an answer without the shared cache")
+		}
+	}
+	if n != 1 {
+		t.Errorf("Expected the in-memory cache to keep working, got %d upstream queries", n)
+	}
+}
+
+// errStore is a store that fails every operation, it counts the reads.
+type errStore struct{ reads int }
+
+func (e *errStore) mget(keys []string) ([][]byte, error)                { e.reads++; return nil, errors.New("down") }
+func (e *errStore) set(key string, val []byte, ttl time.Duration) error { return errors.New("down") }
+func (e *errStore) close() error                                        { return nil }
+
+func TestSharedLogRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	golog.SetOutput(&buf)
+	clog.D.Set()
+	defer func() {
+		clog.D.Clear()
+		clog.Discard()
+	}()
+
+	sh, err := newShared("redis://:secret@redis.example.net:6379/0", time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create shared cache: %s", err)
+	}
+	sh.store = &errStore{}
+	c := New()
+	c.shared = sh
+	c.Next = ttlBackend(60)
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+
+	logged := buf.String()
+	if !strings.Contains(logged, "redis.example.net") {
+		t.Fatalf("Expected the failed read to be logged, got %q", logged)
+	}
+	if strings.Contains(logged, "secret") {
+		t.Errorf("Expected the password not to be logged, got %q", logged)
+	}
+}
+
+func TestSharedCacheBackoff(t *testing.T) {
+	st := &errStore{}
+	c := New()
+	c.shared = &shared{url: "err://", store: st}
+	c.Next = ttlBackend(60)
+
+	for _, name := range []string{"a.example.org.", "b.example.org."} {
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeA)
+		c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+	if st.reads != 1 {
+		t.Fatalf("Expected the store to be skipped after an error, got %d reads", st.reads)
+	}
+
+	now := time.Now()
+	tests := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, maxSharedBackoff, maxSharedBackoff}
+	for i, d := range tests {
+		c.shared.failed(now)
+		if c.shared.available(now.Add(d - time.Millisecond)) {
+			t.Errorf("Test %d: expected the store to be skipped for %s", i, d)
+		}
+		if !c.shared.available(now.Add(d)) {
+			t.Errorf("Test %d: expected the store to be used again after %s", i, d)
+		}
+	}
+
+	c.shared.succeeded()
+	c.shared.failed(now)
+	if !c.shared.available(now.Add(minSharedBackoff)) {
+		t.Errorf("Expected the backoff to be reset after a success")
+	}
+}
+
+func TestSharedCacheNamespace(t *testing.T) {
+	f := newFakeRedis(t, "")
+
+	newCache := func(namespace string) *Cache {
+		sh, err := newShared(f.url(), time.Second)
+		if err != nil {
+			t.Fatalf("Failed to create shared cache: %s", err)
+		}
+		sh.namespace = namespace
+		c := New()
+		c.shared = sh
+		c.Next = ttlBackend(60)
+		sh.OnStartup(c)
+		t.Cleanup(func() { sh.OnShutdown() })
+		return c
+	}
+	c1 := newCache("example.org:53")
+	c2 := newCache("example.net:53")
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	c1.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	for i := 0; f.len() == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c2.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	for i := 0; f.len() < 2 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if f.len() != 2 {
+		t.Errorf("Expected each server block to have its own entry in the store, got %d entries", f.len())
+	}
+}
+
+func TestItemEncode(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Answer = []dns.RR{test.A("example.org. 60 IN A 127.0.0.53")}
+	i := newItem(m, time.Now(), 30*time.Second)
+	i.wildcard = "*.example.org."
+	i.ecsScope = 24
+
+	buf, err := i.encode()
+	if err != nil {
+		t.Fatalf("Failed to encode: %s", err)
+	}
+	i1, err := decodeItem(buf)
+	if err != nil {
+		t.Fatalf("Failed to decode: %s", err)
+	}
+	if i1.Name != i.Name || i1.QType != i.QType || i1.origTTL != i.origTTL || !i1.stored.Equal(i.stored) ||
+		i1.wildcard != i.wildcard || i1.ecsScope != i.ecsScope || len(i1.Answer) != 1 {
+		t.Errorf("Expected %+v, got %+v", i, i1)
+	}
+
+	if _, err := decodeItem(buf[:10]); err == nil {
+		t.Error("Expected error decoding a truncated item")
+	}
+}
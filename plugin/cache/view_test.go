@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/views"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// internalView puts the clients in 10.0.0.0/8 in the internal view.
+type internalView struct{}
+
+func (internalView) SelectView(ctx context.Context, state request.Request) string {
+	if strings.HasPrefix(state.IP(), "10.") {
+		return "internal"
+	}
+	return ""
+}
+
+// viewBackend answers with 10.0.0.1 for the internal view, and 192.0.2.1 for others.
+func viewBackend(n *int) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		*n++
+		state := request.Request{W: w, Req: r}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A("example.org. 60 IN A 192.0.2.1")}
+		if (internalView{}).SelectView(ctx, state) != "" {
+			m.Answer = []dns.RR{test.A("example.org. 60 IN A 10.0.0.1")}
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestCacheViews(t *testing.T) {
+	n := 0
+	c := New()
+	c.Next = viewBackend(&n)
+	c.views = []views.Selector{internalView{}}
+
+	tests := []struct {
+		remoteIP string
+		expected string
+		queries  int // queries seen by the backend
+	}{
+		{"10.1.1.1", "10.0.0.1", 1},
+		{"192.168.1.1", "192.0.2.1", 2},
+		{"10.2.2.2", "10.0.0.1", 2},
+		{"192.168.2.2", "192.0.2.1", 2},
+	}
+	for i, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion("example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.remoteIP})
+		c.ServeDNS(context.TODO(), rec, req)
+
+		if a := rec.Msg.Answer[0].(*dns.A).A.String(); a != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, a)
+		}
+		if n != tc.queries {
+			t.Errorf("Test %d: expected %d backend queries, got %d", i, tc.queries, n)
+		}
+	}
+}
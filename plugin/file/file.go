@@ -8,6 +8,7 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/views"
 	"github.com/coredns/coredns/plugin/transfer"
 	"github.com/coredns/coredns/request"
 
@@ -28,6 +29,13 @@ type (
 	Zones struct {
 		Z     map[string]*Zone // A map mapping zone (origin) to the Zone's data
 		Names []string         // All the keys from the map Z as a string slice.
+		Views []View           // Zone data served instead of Z to the queries in a view, first match wins.
+	}
+
+	// View is the zone data of the queries in a view.
+	View struct {
+		*views.View
+		Zones
 	}
 )
 
@@ -42,7 +50,19 @@ func (f File) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (i
 		return plugin.NextOrFailure(f.Name(), f.Next, ctx, w, r)
 	}
 
+	// Dynamic updates change the zone data that isn't in a view.
+	if r.Opcode == dns.OpcodeUpdate {
+		z, ok := f.Zones.Z[zone]
+		if !ok || z == nil {
+			return dns.RcodeServerFailure, nil
+		}
+		return z.update(state)
+	}
+
 	z, ok := f.Zones.Z[zone]
+	if v := f.view(ctx, state, zone); v != nil {
+		z, ok = v.Z[zone]
+	}
 	if !ok || z == nil {
 		return dns.RcodeServerFailure, nil
 	}
@@ -116,6 +136,29 @@ func (f File) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (i
 // Name implements the Handler interface.
 func (f File) Name() string { return "file" }
 
+// view returns the first view that state is in and that has data for zone, or nil if there is none.
+func (f File) view(ctx context.Context, state request.Request, zone string) *View {
+	for i := range f.Zones.Views {
+		v := &f.Zones.Views[i]
+		if _, ok := v.Z[zone]; ok && v.Match(ctx, state) {
+			return v
+		}
+	}
+	return nil
+}
+
+// SelectView implements the views.Selector interface.
+func (f File) SelectView(ctx context.Context, state request.Request) string {
+	zone := plugin.Zones(f.Zones.Names).Matches(state.Name())
+	if zone == "" {
+		return ""
+	}
+	if v := f.view(ctx, state, zone); v != nil {
+		return v.Name
+	}
+	return ""
+}
+
 type serialErr struct {
 	err    string
 	zone   string
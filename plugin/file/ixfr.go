@@ -0,0 +1,244 @@
+package file
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// transferInIncremental requests an incremental zone transfer (IXFR, RFC 1995) from the primaries, starting at
+// the version of z with SOA soa, and sets the result live. A primary may answer with the differences or with the
+// whole zone. If none of the primaries gave a usable answer, an error is returned and a full zone transfer
+// should be done.
+func (z *Zone) transferInIncremental(soa *dns.SOA) error {
+	m := new(dns.Msg)
+	m.SetIxfr(z.origin, soa.Serial, soa.Ns, soa.Mbox)
+
+	var Err error
+	for _, tr := range z.TransferFrom {
+		req, secrets := z.signed(m, tr)
+		rrs, err := transferInRRs(req, tr, secrets)
+		if err != nil {
+			Err = fmt.Errorf("failed to transfer %q from %q: %v", z.origin, tr, err)
+			continue
+		}
+		z1, err := z.applyIXFR(soa, rrs)
+		if err != nil {
+			Err = fmt.Errorf("failed to apply transfer %q from %q: %v", z.origin, tr, err)
+			continue
+		}
+		if z1 == nil {
+			return nil // up to date
+		}
+
+		z.replace(z1)
+		z.Lock()
+		z.Expired = false
+		z.Unlock()
+		log.Infof("Transferred incrementally: %s from %s with %d SOA serial", z.origin, tr, z1.Apex.SOA.Serial)
+		return nil
+	}
+	return Err
+}
+
+// transferInRRs sends the transfer request m to the primary at addr, and returns all records of the answer.
+// If m is TSIG signed, secrets holds the secret of its key.
+func transferInRRs(m *dns.Msg, addr string, secrets map[string]string) ([]dns.RR, error) {
+	t := &dns.Transfer{TsigSecret: secrets}
+	c, err := t.In(m, addr)
+	if err != nil {
+		return nil, err
+	}
+	var rrs []dns.RR
+	for env := range c {
+		if env.Error != nil {
+			return nil, env.Error
+		}
+		rrs = append(rrs, env.RR...)
+	}
+	return rrs, nil
+}
+
+// applyIXFR returns a new version of z from the answer rrs to an incremental zone transfer request for the version
+// with SOA soa. The answer is either a single SOA if z is up to date, in which case nil is returned, the
+// differences between the versions, or the whole zone. Nil is also returned if the primary has an older version.
+func (z *Zone) applyIXFR(soa *dns.SOA, rrs []dns.RR) (*Zone, error) {
+	if len(rrs) == 0 {
+		return nil, fmt.Errorf("empty answer")
+	}
+	last, ok := rrs[0].(*dns.SOA)
+	if !ok {
+		return nil, fmt.Errorf("answer doesn't start with a SOA")
+	}
+	if !serialNewer(last.Serial, soa.Serial) {
+		return nil, nil
+	}
+	if len(rrs) == 1 {
+		return nil, fmt.Errorf("no differences for %d SOA serial", last.Serial)
+	}
+
+	z1 := z.CopyWithoutApex()
+
+	if _, ok := rrs[1].(*dns.SOA); !ok {
+		// The whole zone, like an AXFR.
+		for _, rr := range rrs[:len(rrs)-1] {
+			if err := z1.Insert(rr); err != nil {
+				return nil, err
+			}
+		}
+		return z1, nil
+	}
+
+	if end, ok := rrs[len(rrs)-1].(*dns.SOA); !ok || end.Serial != last.Serial {
+		return nil, fmt.Errorf("answer doesn't end with %d SOA serial", last.Serial)
+	}
+
+	// Start from the current records and apply each difference sequence: the old SOA, the deleted records,
+	// the new SOA and the added records.
+	z.RLock()
+	set := newRecordSet(z.records())
+	z.RUnlock()
+
+	serial := soa.Serial
+	adding := true
+	for _, rr := range rrs[1 : len(rrs)-1] {
+		if s, ok := rr.(*dns.SOA); ok {
+			if !adding {
+				serial = s.Serial
+				adding = true
+				continue
+			}
+			if s.Serial != serial {
+				return nil, fmt.Errorf("difference from %d SOA serial, expected %d", s.Serial, serial)
+			}
+			adding = false
+			continue
+		}
+
+		if adding {
+			set.add(rr)
+			continue
+		}
+		set.remove(rr)
+	}
+	if !adding || serial != last.Serial {
+		return nil, fmt.Errorf("differences end at %d SOA serial, expected %d", serial, last.Serial)
+	}
+
+	if err := z1.Insert(dns.Copy(last)); err != nil {
+		return nil, err
+	}
+	for _, r := range set.all() {
+		if err := z1.Insert(r); err != nil {
+			return nil, err
+		}
+	}
+	return z1, nil
+}
+
+// recordSet is an ordered set of records, in which records are matched with their key.
+type recordSet struct {
+	keys    []string
+	records map[string]dns.RR
+	names   map[string]map[uint16][]dns.RR // the records by lower cased owner name and type
+}
+
+func newRecordSet(rrs []dns.RR) *recordSet {
+	s := &recordSet{
+		keys:    make([]string, 0, len(rrs)),
+		records: make(map[string]dns.RR, len(rrs)),
+		names:   make(map[string]map[uint16][]dns.RR),
+	}
+	for _, r := range rrs {
+		s.add(r)
+	}
+	return s
+}
+
+// add adds r to s, replacing the record with the same key. It returns false if r was already in s.
+func (s *recordSet) add(r dns.RR) bool {
+	k := ixfrKey(r)
+	old, ok := s.records[k]
+	if !ok {
+		s.keys = append(s.keys, k)
+	}
+	s.records[k] = r
+
+	name, qtype := strings.ToLower(r.Header().Name), r.Header().Rrtype
+	types := s.names[name]
+	if types == nil {
+		types = make(map[uint16][]dns.RR)
+		s.names[name] = types
+	}
+	rrs := types[qtype]
+	if i := indexRR(rrs, old); ok && i >= 0 {
+		rrs[i] = r
+	} else {
+		types[qtype] = append(rrs, r)
+	}
+	return !ok || old.Header().Ttl != r.Header().Ttl
+}
+
+// remove removes the record with the key of r from s. It returns false if there was none.
+func (s *recordSet) remove(r dns.RR) bool {
+	k := ixfrKey(r)
+	old, ok := s.records[k]
+	if !ok {
+		return false
+	}
+	delete(s.records, k)
+
+	name, qtype := strings.ToLower(old.Header().Name), old.Header().Rrtype
+	types := s.names[name]
+	rrs := types[qtype]
+	if i := indexRR(rrs, old); i >= 0 {
+		rrs = append(rrs[:i], rrs[i+1:]...)
+	}
+	switch {
+	case len(rrs) > 0:
+		types[qtype] = rrs
+	case len(types) > 1:
+		delete(types, qtype)
+	default:
+		delete(s.names, name)
+	}
+	return true
+}
+
+// indexRR returns the index of r in rrs, or -1 if it is not there. Records are compared by identity.
+func indexRR(rrs []dns.RR, r dns.RR) int {
+	for i := range rrs {
+		if rrs[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// all returns the records in s, in the order they were first added.
+func (s *recordSet) all() []dns.RR {
+	rrs := make([]dns.RR, 0, len(s.records))
+	seen := make(map[string]struct{}, len(s.records))
+	for _, k := range s.keys {
+		// k is in keys again if it was removed and added back
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		if r, ok := s.records[k]; ok {
+			rrs = append(rrs, r)
+			seen[k] = struct{}{}
+		}
+	}
+	return rrs
+}
+
+// ixfrKey returns the key of r used to match the deleted records of an incremental zone transfer or dynamic
+// update. The owner name is matched case insensitively, and the TTL and class are ignored.
+func ixfrKey(r dns.RR) string {
+	r = dns.Copy(r)
+	r.Header().Name = strings.ToLower(r.Header().Name)
+	r.Header().Ttl = 0
+	r.Header().Class = dns.ClassINET
+	return r.String()
+}
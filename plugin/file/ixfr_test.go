@@ -0,0 +1,137 @@
+package file
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+
+	"github.com/miekg/dns"
+)
+
+// primary answers transfer requests from zone, and refuses IXFR requests if noIXFR is set.
+type primary struct {
+	zone   *Zone
+	noIXFR bool
+	qtypes []uint16
+}
+
+func (p *primary) Handler(w dns.ResponseWriter, req *dns.Msg) {
+	qtype := req.Question[0].Qtype
+	p.qtypes = append(p.qtypes, qtype)
+
+	m := new(dns.Msg)
+	m.SetReply(req)
+	if qtype == dns.TypeIXFR && p.noIXFR {
+		m.Rcode = dns.RcodeRefused
+		w.WriteMsg(m)
+		return
+	}
+	var serial uint32
+	if qtype == dns.TypeIXFR {
+		serial = req.Ns[0].(*dns.SOA).Serial
+	}
+	ch, _ := p.zone.Transfer(serial)
+	for rrs := range ch {
+		m.Answer = append(m.Answer, rrs...)
+	}
+	w.WriteMsg(m)
+}
+
+func sortedRecords(z *Zone) string {
+	rrs := []string{z.Apex.SOA.String()}
+	for _, r := range z.records() {
+		rrs = append(rrs, r.String())
+	}
+	sort.Strings(rrs)
+	return strings.Join(rrs, "\n")
+}
+
+func TestTransferInIncremental(t *testing.T) {
+	tests := []struct {
+		name    string
+		zone    string // zone of the secondary
+		noIXFR  bool
+		qtypes  []uint16
+		journal int // differences in the journal of the secondary after the transfer
+	}{
+		{"differences", journalZone1, false, []uint16{dns.TypeIXFR}, 1},
+		{"whole zone", `example.org.	3600	IN	SOA	ns.example.org. admin.example.org. 0 7200 3600 1209600 3600
+example.org.	3600	IN	NS	ns.example.org.
+`, false, []uint16{dns.TypeIXFR}, 1},
+		{"refused", journalZone1, true, []uint16{dns.TypeIXFR, dns.TypeAXFR}, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &primary{zone: journalZones(t, journalZone1, journalZone2, journalZone3), noIXFR: tc.noIXFR}
+			s := dnstest.NewServer(p.Handler)
+			defer s.Close()
+
+			z, err := Parse(strings.NewReader(tc.zone), "example.org.", "stdin", -1)
+			if err != nil {
+				t.Fatalf("Failed to parse zone: %s", err)
+			}
+			z.TransferFrom = []string{s.Addr}
+			if err := z.TransferIn(); err != nil {
+				t.Fatalf("Failed to transfer: %s", err)
+			}
+
+			if got, expect := sortedRecords(z), sortedRecords(p.zone); got != expect {
+				t.Errorf("Expected records\n%s\ngot\n%s", expect, got)
+			}
+			if len(p.qtypes) != len(tc.qtypes) || p.qtypes[0] != tc.qtypes[0] {
+				t.Errorf("Expected requests %v, got %v", tc.qtypes, p.qtypes)
+			}
+			if len(z.journal) != tc.journal {
+				t.Errorf("Expected %d differences in the journal, got %d", tc.journal, len(z.journal))
+			}
+		})
+	}
+}
+
+func TestApplyIXFR(t *testing.T) {
+	z, err := Parse(strings.NewReader(journalZone1), "example.org.", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse zone: %s", err)
+	}
+	soa := func(serial string) dns.RR {
+		rr, _ := dns.NewRR("example.org. 3600 IN SOA ns.example.org. admin.example.org. " + serial + " 7200 3600 1209600 3600")
+		return rr
+	}
+	a := func(s string) dns.RR {
+		rr, _ := dns.NewRR(s)
+		return rr
+	}
+
+	// Up to date.
+	if z1, err := z.applyIXFR(z.Apex.SOA, []dns.RR{soa("1")}); z1 != nil || err != nil {
+		t.Errorf("Expected no new zone and no error, got %v, %v", z1, err)
+	}
+
+	// Deleted records are matched regardless of their TTL and case.
+	z1, err := z.applyIXFR(z.Apex.SOA, []dns.RR{
+		soa("3"),
+		soa("1"), a("A.example.org. 60 IN A 127.0.0.2"), soa("2"), a("d.example.org. IN A 127.0.0.5"),
+		soa("2"), a("d.example.org. IN A 127.0.0.5"), soa("3"), a("d.example.org. IN A 127.0.0.6"),
+		soa("3"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if z1.Apex.SOA.Serial != 3 {
+		t.Errorf("Expected serial 3, got %d", z1.Apex.SOA.Serial)
+	}
+	if e, _ := z1.Search("a.example.org."); e != nil {
+		t.Errorf("Expected a.example.org. to be deleted, got %v", e.All())
+	}
+	if e, _ := z1.Search("d.example.org."); e == nil || len(e.All()) != 1 || e.All()[0].(*dns.A).A.String() != "127.0.0.6" {
+		t.Errorf("Expected d.example.org. with 127.0.0.6")
+	}
+
+	// Differences that don't start at our serial.
+	if _, err := z.applyIXFR(z.Apex.SOA, []dns.RR{soa("3"), soa("2"), soa("3"), soa("3")}); err == nil {
+		t.Errorf("Expected error for differences from the wrong serial")
+	}
+}
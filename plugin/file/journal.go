@@ -0,0 +1,183 @@
+package file
+
+import (
+	"github.com/miekg/dns"
+)
+
+// diff is the difference between two versions of a zone, as used in incremental zone transfers (RFC 1995).
+type diff struct {
+	from    *dns.SOA
+	to      *dns.SOA
+	deleted []dns.RR
+	added   []dns.RR
+}
+
+// maxJournal is the maximum number of differences a zone keeps for incremental zone transfers.
+const maxJournal = 100
+
+// replace sets the apex and tree of z to those of the newer version z1 of the zone, and records the
+// difference between the two in the journal of z.
+func (z *Zone) replace(z1 *Zone) {
+	rrs := z1.records()
+	d := z.diff(z1.Apex.SOA, rrs)
+
+	z.Lock()
+	defer z.Unlock()
+	z.Apex = z1.Apex
+	z.Tree = z1.Tree
+	z.NSEC3 = z1.NSEC3
+
+	if d == nil {
+		z.journal = nil
+		return
+	}
+	z.journal = append(z.journal, d)
+
+	// Drop the oldest differences when there are too many, or when together they are larger than the zone
+	// itself; a full zone transfer is cheaper then.
+	n := 0
+	for _, d := range z.journal {
+		n += d.len()
+	}
+	for len(z.journal) > 0 && (len(z.journal) > maxJournal || n > len(rrs)) {
+		n -= z.journal[0].len()
+		z.journal[0] = nil
+		z.journal = z.journal[1:]
+	}
+}
+
+// diff returns the difference between z and the newer version of the zone with SOA to and records rrs. It
+// returns nil if there is no difference to record, because either version has no SOA or the serial didn't
+// increase.
+func (z *Zone) diff(to *dns.SOA, rrs []dns.RR) *diff {
+	z.RLock()
+	from := z.Apex.SOA
+	old := z.records()
+	z.RUnlock()
+
+	if from == nil || to == nil || !serialNewer(to.Serial, from.Serial) {
+		return nil
+	}
+
+	d := &diff{from: from, to: to}
+	seen := make(map[string]struct{}, len(old))
+	for _, r := range old {
+		seen[r.String()] = struct{}{}
+	}
+	for _, r := range rrs {
+		k := r.String()
+		if _, ok := seen[k]; ok {
+			delete(seen, k)
+			continue
+		}
+		d.added = append(d.added, r)
+	}
+	for _, r := range old {
+		if _, ok := seen[r.String()]; ok {
+			d.deleted = append(d.deleted, r)
+		}
+	}
+	return d
+}
+
+// len returns the number of records in d.
+func (d *diff) len() int { return len(d.deleted) + len(d.added) }
+
+// records returns all records of z, except the SOA. The caller must hold the lock of z, if needed.
+func (z *Zone) records() []dns.RR {
+	rrs := make([]dns.RR, 0, len(z.Apex.SIGSOA)+len(z.Apex.NS)+len(z.Apex.SIGNS))
+	rrs = append(rrs, z.Apex.SIGSOA...)
+	rrs = append(rrs, z.Apex.NS...)
+	rrs = append(rrs, z.Apex.SIGNS...)
+	if z.Tree == nil {
+		return rrs
+	}
+	for _, e := range z.Tree.All() {
+		rrs = append(rrs, e.All()...)
+	}
+	if z.NSEC3 == nil {
+		return rrs
+	}
+	for _, e := range z.NSEC3.All() {
+		rrs = append(rrs, e.All()...)
+	}
+	return rrs
+}
+
+// ixfr returns the incremental zone transfer from serial to the current version soa of z: the journal
+// condensed into a single difference sequence, enclosed by soa. It returns nil if the journal doesn't go
+// back to serial, and a full zone transfer must be sent instead.
+func (z *Zone) ixfr(serial uint32, soa *dns.SOA) []dns.RR {
+	z.RLock()
+	defer z.RUnlock()
+
+	if len(z.journal) == 0 || z.journal[len(z.journal)-1].to.Serial != soa.Serial {
+		return nil
+	}
+	i := len(z.journal) - 1
+	for ; i >= 0; i-- {
+		if z.journal[i].from.Serial == serial {
+			break
+		}
+	}
+	if i < 0 {
+		return nil
+	}
+
+	deleted, added := condense(z.journal[i:])
+	rrs := make([]dns.RR, 0, len(deleted)+len(added)+4)
+	rrs = append(rrs, soa, z.journal[i].from)
+	rrs = append(rrs, deleted...)
+	rrs = append(rrs, soa)
+	rrs = append(rrs, added...)
+	return append(rrs, soa)
+}
+
+// condense merges the consecutive differences diffs into one, records that are added and later deleted
+// again (or the other way around) are left out.
+func condense(diffs []*diff) (deleted, added []dns.RR) {
+	if len(diffs) == 1 {
+		return diffs[0].deleted, diffs[0].added
+	}
+
+	del := make(map[string]dns.RR)
+	add := make(map[string]dns.RR)
+	order := []string{}
+	for _, d := range diffs {
+		for _, r := range d.deleted {
+			k := r.String()
+			if _, ok := add[k]; ok {
+				delete(add, k)
+				continue
+			}
+			del[k] = r
+			order = append(order, k)
+		}
+		for _, r := range d.added {
+			k := r.String()
+			if _, ok := del[k]; ok {
+				delete(del, k)
+				continue
+			}
+			add[k] = r
+			order = append(order, k)
+		}
+	}
+
+	for _, k := range order {
+		if r, ok := del[k]; ok {
+			deleted = append(deleted, r)
+			delete(del, k)
+		}
+		if r, ok := add[k]; ok {
+			added = append(added, r)
+			delete(add, k)
+		}
+	}
+	return deleted, added
+}
+
+// serialNewer returns true if serial a is newer than b, using serial number arithmetic (RFC 1982).
+func serialNewer(a, b uint32) bool {
+	return int32(a-b) > 0
+}
@@ -0,0 +1,149 @@
+package file
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const journalZone1 = `example.org.	3600	IN	SOA	ns.example.org. admin.example.org. 1 7200 3600 1209600 3600
+example.org.	3600	IN	NS	ns.example.org.
+ns.example.org.	3600	IN	A	127.0.0.1
+a.example.org.	3600	IN	A	127.0.0.2
+b.example.org.	3600	IN	A	127.0.0.3
+c.example.org.	3600	IN	A	127.0.0.4
+`
+
+const journalZone2 = `example.org.	3600	IN	SOA	ns.example.org. admin.example.org. 2 7200 3600 1209600 3600
+example.org.	3600	IN	NS	ns.example.org.
+ns.example.org.	3600	IN	A	127.0.0.1
+a.example.org.	3600	IN	A	127.0.0.20
+b.example.org.	3600	IN	A	127.0.0.3
+c.example.org.	3600	IN	A	127.0.0.4
+d.example.org.	3600	IN	A	127.0.0.5
+`
+
+const journalZone3 = `example.org.	3600	IN	SOA	ns.example.org. admin.example.org. 3 7200 3600 1209600 3600
+example.org.	3600	IN	NS	ns.example.org.
+ns.example.org.	3600	IN	A	127.0.0.1
+a.example.org.	3600	IN	A	127.0.0.20
+b.example.org.	3600	IN	A	127.0.0.3
+c.example.org.	3600	IN	A	127.0.0.4
+e.example.org.	3600	IN	A	127.0.0.6
+`
+
+func journalZones(t *testing.T, zones ...string) *Zone {
+	t.Helper()
+	z, err := Parse(strings.NewReader(zones[0]), "example.org.", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse zone: %s", err)
+	}
+	for _, zone := range zones[1:] {
+		z1, err := Parse(strings.NewReader(zone), "example.org.", "stdin", 0)
+		if err != nil {
+			t.Fatalf("Failed to parse zone: %s", err)
+		}
+		z.replace(z1)
+	}
+	return z
+}
+
+func transferRRs(t *testing.T, z *Zone, serial uint32) []string {
+	t.Helper()
+	ch, err := z.Transfer(serial)
+	if err != nil {
+		t.Fatalf("Failed to transfer: %s", err)
+	}
+	rrs := []string{}
+	for records := range ch {
+		for _, r := range records {
+			rrs = append(rrs, shortRR(r))
+		}
+	}
+	return rrs
+}
+
+// shortRR returns the name, type and data of r.
+func shortRR(r dns.RR) string {
+	if soa, ok := r.(*dns.SOA); ok {
+		return "SOA " + strings.Fields(soa.String())[6]
+	}
+	f := strings.Fields(r.String())
+	return f[0] + " " + f[3] + " " + f[4]
+}
+
+func TestTransferIXFR(t *testing.T) {
+	z := journalZones(t, journalZone1, journalZone2, journalZone3)
+
+	tests := []struct {
+		serial uint32
+		expect []string
+	}{
+		{3, []string{"SOA 3"}},
+		{2, []string{
+			"SOA 3",
+			"SOA 2", "d.example.org. A 127.0.0.5",
+			"SOA 3", "e.example.org. A 127.0.0.6",
+			"SOA 3",
+		}},
+		// Two differences condensed into one, d.example.org is added and deleted again.
+		{1, []string{
+			"SOA 3",
+			"SOA 1", "a.example.org. A 127.0.0.2",
+			"SOA 3", "a.example.org. A 127.0.0.20", "e.example.org. A 127.0.0.6",
+			"SOA 3",
+		}},
+	}
+
+	for _, tc := range tests {
+		got := transferRRs(t, z, tc.serial)
+		if strings.Join(got, "\n") != strings.Join(tc.expect, "\n") {
+			t.Errorf("Serial %d: expected\n%s\ngot\n%s", tc.serial, strings.Join(tc.expect, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestTransferIXFRFallback(t *testing.T) {
+	z := journalZones(t, journalZone1, journalZone2, journalZone3)
+
+	// The journal doesn't go back this far, the whole zone is sent.
+	for _, serial := range []uint32{0, 10} {
+		got := transferRRs(t, z, serial)
+		if len(got) != 8 || got[0] != "SOA 3" || got[len(got)-1] != "SOA 3" {
+			t.Errorf("Serial %d: expected full zone transfer, got\n%s", serial, strings.Join(got, "\n"))
+		}
+	}
+
+	// A serial that goes back clears the journal.
+	z1, err := Parse(strings.NewReader(journalZone1), "example.org.", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse zone: %s", err)
+	}
+	z.replace(z1)
+	if len(z.journal) != 0 {
+		t.Errorf("Expected empty journal, got %d differences", len(z.journal))
+	}
+}
+
+func TestJournalSize(t *testing.T) {
+	z := journalZones(t, journalZone1, journalZone2, journalZone3)
+	if len(z.journal) != 2 {
+		t.Fatalf("Expected 2 differences, got %d", len(z.journal))
+	}
+
+	// A difference larger than the zone itself is not kept.
+	z1, err := Parse(strings.NewReader(`example.org.	3600	IN	SOA	ns.example.org. admin.example.org. 4 7200 3600 1209600 3600
+example.org.	3600	IN	NS	ns2.example.org.
+`), "example.org.", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse zone: %s", err)
+	}
+	z.replace(z1)
+	if len(z.journal) != 0 {
+		t.Errorf("Expected empty journal, got %d differences", len(z.journal))
+	}
+	if got := transferRRs(t, z, 3); len(got) != 3 {
+		t.Errorf("Expected full zone transfer, got\n%s", strings.Join(got, "\n"))
+	}
+}
@@ -40,6 +40,10 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 	z.RLock()
 	ap := z.Apex
 	tr := z.Tree
+	var n3 *nsec3Chain // only set when the zone uses NSEC3 for denial of existence
+	if do {
+		n3 = newNSEC3Chain(z.NSEC3, z.origin)
+	}
 	z.RUnlock()
 	if ap.SOA == nil {
 		return nil, nil, nil, ServerFailure
@@ -163,6 +167,10 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 			if do {
 				dss := typeFromElem(elem, dns.TypeDS, do)
 				nsrrs = append(nsrrs, dss...)
+				if len(dss) == 0 && n3 != nil {
+					// Prove there is no DS: an insecure delegation.
+					nsrrs = append(nsrrs, n3.nodata(elem.Name())...)
+				}
 			}
 
 			return nil, nsrrs, glue, Delegation
@@ -188,7 +196,10 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 		// NODATA
 		if len(rrs) == 0 {
 			ret := ap.soa(do)
-			if do {
+			switch {
+			case n3 != nil:
+				ret = append(ret, n3.nodata(qname)...)
+			case do:
 				nsec := typeFromElem(elem, dns.TypeNSEC, do)
 				ret = append(ret, nsec...)
 			}
@@ -227,7 +238,10 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 		// NODATA response.
 		if len(rrs) == 0 {
 			ret := ap.soa(do)
-			if do {
+			switch {
+			case n3 != nil:
+				ret = append(ret, n3.wildcardNodata(qname, wildElem.Name())...)
+			case do:
 				nsec := typeFromElem(wildElem, dns.TypeNSEC, do)
 				ret = append(ret, nsec...)
 			}
@@ -237,7 +251,9 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 		auth := ap.ns(do)
 		if do {
 			// An NSEC is needed to say no longer name exists under this wildcard.
-			if deny, found := tr.Prev(qname); found {
+			if n3 != nil {
+				auth = append(auth, n3.wildcardAnswer(qname, wildElem.Name())...)
+			} else if deny, found := tr.Prev(qname); found {
 				nsec := typeFromElem(deny, dns.TypeNSEC, do)
 				auth = append(auth, nsec...)
 			}
@@ -260,6 +276,14 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 	}
 
 	ret := ap.soa(do)
+	if n3 != nil {
+		if rcode == NameError {
+			ret = append(ret, n3.nxdomain(qname)...)
+		} else {
+			ret = append(ret, n3.nodata(qname)...)
+		}
+		return nil, ret, nil, rcode
+	}
 	if do {
 		deny, found := tr.Prev(qname)
 		if !found {
@@ -0,0 +1,124 @@
+package file
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/plugin/file/tree"
+
+	"github.com/miekg/dns"
+)
+
+// nsec3Chain is the NSEC3 chain of a zone, used to create the denial of existence proofs of RFC 5155,
+// section 7.2.
+type nsec3Chain struct {
+	tree   *tree.Tree
+	origin string
+	param  *dns.NSEC3 // a record of the chain, for the hash parameters
+}
+
+// newNSEC3Chain returns the chain in t for the zone origin, or nil if t has no NSEC3 records.
+func newNSEC3Chain(t *tree.Tree, origin string) *nsec3Chain {
+	if t == nil || t.Min() == nil {
+		return nil
+	}
+	rrs := t.Min().Type(dns.TypeNSEC3)
+	if len(rrs) == 0 {
+		return nil
+	}
+	return &nsec3Chain{tree: t, origin: origin, param: rrs[0].(*dns.NSEC3)}
+}
+
+// hash returns the hashed owner name of name.
+func (c *nsec3Chain) hash(name string) string {
+	return strings.ToLower(dns.HashName(name, c.param.Hash, c.param.Iterations, c.param.Salt)) + "." + c.origin
+}
+
+// match returns the element with the NSEC3 record that matches name, or nil if there is none.
+func (c *nsec3Chain) match(name string) *tree.Elem {
+	e, _ := c.tree.Search(c.hash(name))
+	return e
+}
+
+// cover returns the element with the NSEC3 record that covers name.
+func (c *nsec3Chain) cover(name string) *tree.Elem {
+	if e, found := c.tree.Prev(c.hash(name)); found {
+		return e
+	}
+	// The hash is smaller than all hashed owner names, it's covered by the last record of the chain.
+	return c.tree.Max()
+}
+
+// closestEncloser returns the closest provable encloser of qname, and its proof: the NSEC3 record that
+// matches it, and the one that covers the next closer name.
+func (c *nsec3Chain) closestEncloser(qname string) (string, []*tree.Elem) {
+	next := qname
+	for next != c.origin {
+		off, end := dns.NextLabel(next, 0)
+		if end {
+			break
+		}
+		ce := next[off:]
+		if e := c.match(ce); e != nil {
+			return ce, []*tree.Elem{e, c.cover(next)}
+		}
+		next = ce
+	}
+	return c.origin, nil
+}
+
+// nxdomain returns the proof that qname doesn't exist: the closest encloser proof, and the NSEC3 record
+// that covers the wildcard at the closest encloser.
+func (c *nsec3Chain) nxdomain(qname string) []dns.RR {
+	ce, proof := c.closestEncloser(qname)
+	return nsec3RRs(append(proof, c.cover("*."+ce)))
+}
+
+// nodata returns the proof that qname doesn't have the queried type: the NSEC3 record that matches qname.
+// Without one, qname is in an opt-out span and the closest provable encloser proof is returned.
+func (c *nsec3Chain) nodata(qname string) []dns.RR {
+	if e := c.match(qname); e != nil {
+		return nsec3RRs([]*tree.Elem{e})
+	}
+	_, proof := c.closestEncloser(qname)
+	return nsec3RRs(proof)
+}
+
+// wildcardNodata returns the proof that qname, which matches wildcard, doesn't have the queried type: the
+// closest encloser proof and the NSEC3 record that matches the wildcard.
+func (c *nsec3Chain) wildcardNodata(qname, wildcard string) []dns.RR {
+	ce := wildcard[2:]
+	proof := []*tree.Elem{c.match(ce), c.cover(nextCloser(qname, ce)), c.match(wildcard)}
+	return nsec3RRs(proof)
+}
+
+// wildcardAnswer returns the proof that qname doesn't exist, which is needed when the answer is expanded
+// from wildcard: the NSEC3 record that covers the next closer name.
+func (c *nsec3Chain) wildcardAnswer(qname, wildcard string) []dns.RR {
+	return nsec3RRs([]*tree.Elem{c.cover(nextCloser(qname, wildcard[2:]))})
+}
+
+// nextCloser returns the name that is one label longer than the closest encloser ce of qname.
+func nextCloser(qname, ce string) string {
+	off := 0
+	for i := dns.CountLabel(qname) - dns.CountLabel(ce) - 1; i > 0; i-- {
+		off, _ = dns.NextLabel(qname, off)
+	}
+	return qname[off:]
+}
+
+// nsec3RRs returns the NSEC3 records and their signatures from elems, skipping duplicates and nil elements.
+func nsec3RRs(elems []*tree.Elem) []dns.RR {
+	var rrs []dns.RR
+	seen := make(map[string]struct{}, len(elems))
+	for _, e := range elems {
+		if e == nil {
+			continue
+		}
+		if _, ok := seen[e.Name()]; ok {
+			continue
+		}
+		seen[e.Name()] = struct{}{}
+		rrs = append(rrs, typeFromElem(e, dns.TypeNSEC3, true)...)
+	}
+	return rrs
+}
@@ -3,19 +3,36 @@ package file
 import (
 	"strings"
 	"testing"
+
+	"github.com/miekg/dns"
 )
 
 func TestParseNSEC3PARAM(t *testing.T) {
-	_, err := Parse(strings.NewReader(nsec3paramTest), "miek.nl", "stdin", 0)
-	if err == nil {
-		t.Fatalf("Expected error when reading zone, got nothing")
+	z, err := Parse(strings.NewReader(nsec3paramTest), "miek.nl", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Expected no error when reading zone, got %q", err)
+	}
+	apex, _ := z.Search("miek.nl.")
+	if apex == nil || len(apex.Type(dns.TypeNSEC3PARAM)) != 1 {
+		t.Errorf("Expected the NSEC3PARAM record in the apex")
 	}
 }
 
 func TestParseNSEC3(t *testing.T) {
-	_, err := Parse(strings.NewReader(nsec3Test), "miek.nl", "stdin", 0)
-	if err == nil {
-		t.Fatalf("Expected error when reading zone, got nothing")
+	z, err := Parse(strings.NewReader(nsec3Test), "example.org", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Expected no error when reading zone, got %q", err)
+	}
+	name := "aub8v9ce95ie18spjubsr058h41n7pa5.example.org."
+	if _, ok := z.Search(name); ok {
+		t.Errorf("Expected %s to be kept out of the zone's names", name)
+	}
+	e, ok := z.NSEC3.Search(name)
+	if !ok {
+		t.Fatalf("Expected %s in the NSEC3 records", name)
+	}
+	if len(e.Type(dns.TypeNSEC3)) != 1 || len(e.Type(dns.TypeRRSIG)) != 1 {
+		t.Errorf("Expected the NSEC3 record and its signature, got %v", e.All())
 	}
 }
 
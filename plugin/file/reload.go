@@ -10,6 +10,7 @@ import (
 
 // Reload reloads a zone when it is changed on disk. If z.ReloadInterval is zero, no reloading will be done.
 func (z *Zone) Reload(t *transfer.Transfer) error {
+	z.notifier = t
 	if z.ReloadInterval == 0 {
 		return nil
 	}
@@ -26,21 +27,20 @@ func (z *Zone) Reload(t *transfer.Transfer) error {
 					continue
 				}
 
+				z.updates.Lock()
 				serial := z.SOASerialIfDefined()
 				zone, err := Parse(reader, z.origin, zFile, serial)
 				reader.Close()
 				if err != nil {
+					z.updates.Unlock()
 					if _, ok := err.(*serialErr); !ok {
 						log.Errorf("Parsing zone %q: %v", z.origin, err)
 					}
 					continue
 				}
 
-				// copy elements we need
-				z.Lock()
-				z.Apex = zone.Apex
-				z.Tree = zone.Tree
-				z.Unlock()
+				z.replace(zone)
+				z.updates.Unlock()
 
 				log.Infof("Successfully reloaded zone %q in %q with %d SOA serial", z.origin, zFile, z.Apex.SOA.Serial)
 				if t != nil {
@@ -1,17 +1,31 @@
 package file
 
 import (
+	"fmt"
 	"math/rand"
 	"time"
 
 	"github.com/miekg/dns"
 )
 
-// TransferIn retrieves the zone from the masters, parses it and sets it live.
+// TransferIn retrieves the zone from the masters, parses it and sets it live. If we already have a version
+// of the zone, an incremental transfer is tried first.
 func (z *Zone) TransferIn() error {
 	if len(z.TransferFrom) == 0 {
 		return nil
 	}
+
+	z.RLock()
+	soa := z.Apex.SOA
+	z.RUnlock()
+	if soa != nil {
+		err := z.transferInIncremental(soa)
+		if err == nil {
+			return nil
+		}
+		log.Warningf("Incremental transfer failed, falling back to full transfer: %v", err)
+	}
+
 	m := new(dns.Msg)
 	m.SetAxfr(z.origin)
 
@@ -24,7 +38,9 @@ func (z *Zone) TransferIn() error {
 Transfer:
 	for _, tr = range z.TransferFrom {
 		t := new(dns.Transfer)
-		c, err := t.In(m, tr)
+		req, secrets := z.signed(m, tr)
+		t.TsigSecret = secrets
+		c, err := t.In(req, tr)
 		if err != nil {
 			log.Errorf("Failed to setup transfer `%s' with `%q': %v", z.origin, tr, err)
 			Err = err
@@ -51,9 +67,8 @@ Transfer:
 		return Err
 	}
 
+	z.replace(z1)
 	z.Lock()
-	z.Tree = z1.Tree
-	z.Apex = z1.Apex
 	z.Expired = false
 	z.Unlock()
 	log.Infof("Transferred: %s from %s", z.origin, tr)
@@ -74,7 +89,9 @@ func (z *Zone) shouldTransfer() (bool, error) {
 Transfer:
 	for _, tr := range z.TransferFrom {
 		Err = nil
-		ret, _, err := c.Exchange(m, tr)
+		req, secrets := z.signed(m, tr)
+		c.TsigSecret = secrets
+		ret, _, err := c.Exchange(req, tr)
 		if err != nil || ret.Rcode != dns.RcodeSuccess {
 			Err = err
 			continue
@@ -95,6 +112,30 @@ Transfer:
 	return less(z.Apex.SOA.Serial, uint32(serial)), Err
 }
 
+// signed returns m signed with the TSIG key of primary, and the secrets needed to sign it. If primary doesn't
+// require TSIG, m is returned as is.
+func (z *Zone) signed(m *dns.Msg, primary string) (*dns.Msg, map[string]string) {
+	key, ok := z.TransferKeys[primary]
+	if !ok {
+		return m, nil
+	}
+	m = m.Copy()
+	m.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+	return m, z.TsigSecret
+}
+
+// SetTsigSecret sets the TSIG secrets to sign the requests to the primaries with. It returns an error if the
+// secret of one of the keys in z.TransferKeys is missing.
+func (z *Zone) SetTsigSecret(secrets map[string]string) error {
+	for _, key := range z.TransferKeys {
+		if _, ok := secrets[key.Name]; !ok {
+			return fmt.Errorf("no secret for TSIG key %q", key.Name)
+		}
+	}
+	z.TsigSecret = secrets
+	return nil
+}
+
 // less returns true of a is smaller than b when taking RFC 1982 serial arithmetic into account.
 func less(a, b uint32) bool {
 	if a < b {
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/parse"
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/coredns/coredns/request"
 
@@ -144,3 +145,36 @@ func newRequest(zone string, qtype uint16) request.Request {
 	m.SetEdns0(4097, true)
 	return request.Request{W: &test.ResponseWriter{}, Req: m}
 }
+
+func TestTransferInTSIG(t *testing.T) {
+	soa := soa{250}
+
+	var keys []string
+	s := dnstest.NewServer(func(w dns.ResponseWriter, req *dns.Msg) {
+		if tsig := req.IsTsig(); tsig != nil {
+			keys = append(keys, tsig.Hdr.Name)
+		}
+		soa.Handler(w, req)
+	})
+	defer s.Close()
+
+	z := NewZone(testZone, "stdin")
+	z.TransferFrom = []string{s.Addr}
+	z.TransferKeys = map[string]parse.Key{s.Addr: {Name: "transfer.key.", Algorithm: dns.HmacSHA256}}
+	if err := z.SetTsigSecret(map[string]string{"other.key.": "NoTCJU+DMqFWywaPyxSijrDEA/eC3nK0xi3AMEZuPVk="}); err == nil {
+		t.Fatal("Expected error for missing TSIG secret")
+	}
+	if err := z.SetTsigSecret(map[string]string{"transfer.key.": "NoTCJU+DMqFWywaPyxSijrDEA/eC3nK0xi3AMEZuPVk="}); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if _, err := z.shouldTransfer(); err != nil {
+		t.Fatalf("Unable to run shouldTransfer: %v", err)
+	}
+	if err := z.TransferIn(); err != nil {
+		t.Fatalf("Unable to run TransferIn: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "transfer.key." || keys[1] != "transfer.key." {
+		t.Errorf("Expected 2 requests signed with transfer.key., got %q", keys)
+	}
+}
@@ -2,15 +2,20 @@ package file
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/plugin/pkg/views"
 	"github.com/coredns/coredns/plugin/transfer"
+
+	"github.com/miekg/dns"
 )
 
 func init() { plugin.Register("file", setup) }
@@ -54,10 +59,26 @@ func setup(c *caddy.Controller) error {
 		z := zones.Z[n]
 		c.OnShutdown(z.OnShutdown)
 		c.OnStartup(func() error {
+			for _, key := range z.UpdateKeys {
+				if _, ok := dnsserver.GetConfig(c).TsigSecret[key]; !ok {
+					return plugin.Error("file", fmt.Errorf("no secret for TSIG key %q", key))
+				}
+			}
 			z.StartupOnce.Do(func() { z.Reload(f.transfer) })
 			return nil
 		})
 	}
+	// Zones in views are not transferred, so there is nobody to notify.
+	for _, v := range zones.Views {
+		for _, n := range v.Names {
+			z := v.Z[n]
+			c.OnShutdown(z.OnShutdown)
+			c.OnStartup(func() error {
+				z.StartupOnce.Do(func() { z.Reload(nil) })
+				return nil
+			})
+		}
+	}
 
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		f.Next = next
@@ -75,6 +96,7 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 
 	var openErr error
 	reload := 1 * time.Minute
+	var fileViews []View
 
 	for c.Next() {
 		// file db.file [zones...]
@@ -84,36 +106,23 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 		fileName := c.Val()
 
 		origins := plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+		viewsBefore := len(fileViews)
+		var updateKeys []string
 		if !filepath.IsAbs(fileName) && config.Root != "" {
 			fileName = filepath.Join(config.Root, fileName)
 		}
 
-		reader, err := os.Open(filepath.Clean(fileName))
-		if err != nil {
-			openErr = err
-		}
-
-		err = func() error {
-			defer reader.Close()
-
-			for i := range origins {
-				z[origins[i]] = NewZone(origins[i], fileName)
-				if openErr == nil {
-					reader.Seek(0, 0)
-					zone, err := Parse(reader, origins[i], fileName, 0)
-					if err != nil {
-						return err
-					}
-					z[origins[i]] = zone
-				}
-				names = append(names, origins[i])
-			}
-			return nil
-		}()
-
+		zones, zErr, err := parseZones(fileName, origins)
 		if err != nil {
 			return Zones{}, err
 		}
+		if zErr != nil {
+			openErr = zErr
+		}
+		for _, origin := range origins {
+			z[origin] = zones[origin]
+			names = append(names, origin)
+		}
 
 		for c.NextBlock() {
 			switch c.Val() {
@@ -127,9 +136,43 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 					return Zones{}, plugin.Error("file", err)
 				}
 				reload = d
+			case "update":
+				t := c.RemainingArgs()
+				if len(t) < 1 {
+					return Zones{}, c.ArgErr()
+				}
+				// The whole file is written after an update, so it can only hold one zone.
+				if len(origins) > 1 {
+					return Zones{}, c.Errf("update needs a file with a single zone, got %d", len(origins))
+				}
+				for _, key := range t {
+					updateKeys = append(updateKeys, strings.ToLower(dns.Fqdn(key)))
+				}
+				config.Updates = true
 			case "upstream":
 				// remove soon
 				c.RemainingArgs()
+			case "view":
+				t := c.RemainingArgs()
+				if len(t) < 2 {
+					return Zones{}, c.ArgErr()
+				}
+				v, err := views.New(t[0], t[2:])
+				if err != nil {
+					return Zones{}, c.Err(err.Error())
+				}
+				viewFile := t[1]
+				if !filepath.IsAbs(viewFile) && config.Root != "" {
+					viewFile = filepath.Join(config.Root, viewFile)
+				}
+				zones, zErr, err := parseZones(viewFile, origins)
+				if err != nil {
+					return Zones{}, err
+				}
+				if zErr != nil {
+					openErr = zErr
+				}
+				fileViews = append(fileViews, View{View: v, Zones: Zones{Z: zones, Names: origins}})
 
 			default:
 				return Zones{}, c.Errf("unknown property '%s'", c.Val())
@@ -139,6 +182,13 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 		for i := range origins {
 			z[origins[i]].ReloadInterval = reload
 			z[origins[i]].Upstream = upstream.New()
+			z[origins[i]].UpdateKeys = updateKeys
+		}
+		for _, v := range fileViews[viewsBefore:] {
+			for _, zone := range v.Z {
+				zone.ReloadInterval = reload
+				zone.Upstream = upstream.New()
+			}
 		}
 	}
 
@@ -149,5 +199,28 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 		}
 		log.Warningf("Failed to open %q: trying again in %s", openErr, reload)
 	}
-	return Zones{Z: z, Names: names}, nil
+	return Zones{Z: z, Names: names, Views: fileViews}, nil
+}
+
+// parseZones returns the zones of origins in fileName. If fileName can't be opened, the zones are empty and
+// openErr is set; they are loaded when the file is reloaded.
+func parseZones(fileName string, origins []string) (z map[string]*Zone, openErr, err error) {
+	z = make(map[string]*Zone)
+	reader, openErr := os.Open(filepath.Clean(fileName))
+	if openErr == nil {
+		defer reader.Close()
+	}
+
+	for _, origin := range origins {
+		z[origin] = NewZone(origin, fileName)
+		if openErr == nil {
+			reader.Seek(0, 0)
+			zone, err := Parse(reader, origin, fileName, 0)
+			if err != nil {
+				return nil, nil, err
+			}
+			z[origin] = zone
+		}
+	}
+	return z, openErr, nil
 }
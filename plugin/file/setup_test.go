@@ -41,7 +41,28 @@ func TestFileParse(t *testing.T) {
 			false,
 			Zones{Names: []string{"10.in-addr.arpa."}},
 		},
+		{
+			`file ` + zoneFileName1 + ` miek.nl. {
+				update update.key
+			}`,
+			false,
+			Zones{Names: []string{"miek.nl."}},
+		},
 		// errors.
+		{
+			`file ` + zoneFileName1 + ` miek.nl. example.org. {
+				update update.key.
+			}`,
+			true,
+			Zones{},
+		},
+		{
+			`file ` + zoneFileName1 + ` miek.nl. {
+				update
+			}`,
+			true,
+			Zones{},
+		},
 		{
 			`file ` + zoneFileName1 + ` miek.nl {
 				transfer from 127.0.0.1
@@ -122,3 +143,22 @@ func TestParseReload(t *testing.T) {
 		}
 	}
 }
+
+func TestParseUpdate(t *testing.T) {
+	name, rm, err := test.TempFile(".", dbMiekNL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	c := caddy.NewTestController("dns", `file `+name+` miek.nl. {
+		update Update.Key other.key.
+	}`)
+	z, err := fileParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if keys := z.Z["miek.nl."].UpdateKeys; len(keys) != 2 || keys[0] != "update.key." || keys[1] != "other.key." {
+		t.Errorf("Expected update keys [update.key. other.key.], got %v", keys)
+	}
+}
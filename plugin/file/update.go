@@ -0,0 +1,323 @@
+package file
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// update handles the dynamic update (RFC 2136) in state for zone z, and writes the reply.
+func (z *Zone) update(state request.Request) (int, error) {
+	rcode, changed := z.applyUpdate(state)
+
+	m := new(dns.Msg)
+	m.SetRcode(state.Req, rcode)
+	if t := state.Req.IsTsig(); t != nil && state.W.TsigStatus() == nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, 300, time.Now().Unix())
+	}
+	state.W.WriteMsg(m)
+
+	if changed && z.notifier != nil {
+		if err := z.notifier.Notify(z.origin); err != nil {
+			log.Warningf("Failed sending notifies: %s", err)
+		}
+	}
+	return dns.RcodeSuccess, nil
+}
+
+// applyUpdate checks the dynamic update in state, and applies it to z. It returns the rcode of the reply,
+// and true if z was changed.
+func (z *Zone) applyUpdate(state request.Request) (int, bool) {
+	r := state.Req
+	if len(z.UpdateKeys) == 0 {
+		return dns.RcodeRefused, false
+	}
+	if state.QType() != dns.TypeSOA {
+		return dns.RcodeFormatError, false
+	}
+	if state.Name() != z.origin {
+		return dns.RcodeNotAuth, false
+	}
+	if !z.updateAllowed(state) {
+		log.Infof("Refused update of %s from %s: not signed with an allowed key", z.origin, state.IP())
+		return dns.RcodeRefused, false
+	}
+
+	z.updates.Lock()
+	defer z.updates.Unlock()
+
+	z.RLock()
+	soa := z.Apex.SOA
+	expired := z.Expired
+	rrs := z.records()
+	z.RUnlock()
+	if soa == nil || expired {
+		return dns.RcodeServerFailure, false
+	}
+
+	set := newRecordSet(append([]dns.RR{soa}, rrs...))
+	if rcode := z.checkPrerequisites(set, r.Answer); rcode != dns.RcodeSuccess {
+		return rcode, false
+	}
+	if rcode := z.checkUpdates(r.Ns); rcode != dns.RcodeSuccess {
+		return rcode, false
+	}
+
+	newSOA := soa
+	changed := false
+	for _, rr := range r.Ns {
+		if s, ok := rr.(*dns.SOA); ok && rr.Header().Class == dns.ClassINET {
+			if strings.ToLower(s.Hdr.Name) == z.origin && serialNewer(s.Serial, newSOA.Serial) {
+				newSOA = s
+				changed = true
+			}
+			continue
+		}
+		if z.applyRR(set, rr) {
+			changed = true
+		}
+	}
+	if !changed {
+		return dns.RcodeSuccess, false
+	}
+
+	newSOA = dns.Copy(newSOA).(*dns.SOA)
+	if newSOA.Serial == soa.Serial {
+		newSOA.Serial++
+	}
+
+	z1 := z.CopyWithoutApex()
+	if err := z1.Insert(newSOA); err != nil {
+		log.Errorf("Failed to update %s: %s", z.origin, err)
+		return dns.RcodeServerFailure, false
+	}
+	for _, rr := range set.all() {
+		if rr.Header().Rrtype == dns.TypeSOA {
+			continue
+		}
+		if err := z1.Insert(rr); err != nil {
+			log.Errorf("Failed to update %s: %s", z.origin, err)
+			return dns.RcodeServerFailure, false
+		}
+	}
+
+	if err := z1.write(z.File()); err != nil {
+		log.Errorf("Failed to write updated zone %s: %s", z.origin, err)
+		return dns.RcodeServerFailure, false
+	}
+	z.replace(z1)
+
+	log.Infof("Updated zone %q from %s with %d SOA serial", z.origin, state.IP(), newSOA.Serial)
+	return dns.RcodeSuccess, true
+}
+
+// updateAllowed returns true if the update is signed with one of the keys in z.UpdateKeys and the signature is valid.
+func (z *Zone) updateAllowed(state request.Request) bool {
+	t := state.Req.IsTsig()
+	if t == nil || state.W.TsigStatus() != nil {
+		return false
+	}
+	key := strings.ToLower(t.Hdr.Name)
+	for _, k := range z.UpdateKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPrerequisites checks the prerequisite section of an update against the records set of the zone,
+// as described in RFC 2136, section 3.2.
+func (z *Zone) checkPrerequisites(set *recordSet, prereqs []dns.RR) int {
+	exact := map[rrsetKey][]dns.RR{}
+	for _, rr := range prereqs {
+		h := rr.Header()
+		name := strings.ToLower(h.Name)
+		if h.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if !dns.IsSubDomain(z.origin, name) {
+			return dns.RcodeNotZone
+		}
+
+		switch h.Class {
+		case dns.ClassANY:
+			if h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if h.Rrtype == dns.TypeANY {
+				if len(set.rrset(name, dns.TypeANY)) == 0 {
+					return dns.RcodeNameError
+				}
+			} else if len(set.rrset(name, h.Rrtype)) == 0 {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if h.Rrtype == dns.TypeANY {
+				if len(set.rrset(name, dns.TypeANY)) > 0 {
+					return dns.RcodeYXDomain
+				}
+			} else if len(set.rrset(name, h.Rrtype)) > 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			k := rrsetKey{name, h.Rrtype}
+			exact[k] = append(exact[k], rr)
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	// The RRsets must exist with exactly these records.
+	for k, rrs := range exact {
+		want := newRecordSet(rrs)
+		have := set.rrset(k.name, k.qtype)
+		if len(have) != len(want.records) {
+			return dns.RcodeNXRrset
+		}
+		for _, rr := range have {
+			if _, ok := want.records[ixfrKey(rr)]; !ok {
+				return dns.RcodeNXRrset
+			}
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// checkUpdates checks the update section of an update, as described in RFC 2136, section 3.4.1.3.
+func (z *Zone) checkUpdates(updates []dns.RR) int {
+	for _, rr := range updates {
+		h := rr.Header()
+		if !dns.IsSubDomain(z.origin, strings.ToLower(h.Name)) {
+			return dns.RcodeNotZone
+		}
+		switch h.Rrtype {
+		case dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB:
+			return dns.RcodeFormatError
+		}
+
+		switch h.Class {
+		case dns.ClassINET:
+			if h.Rrtype == dns.TypeANY {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassANY:
+			if h.Ttl != 0 || h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if h.Ttl != 0 || h.Rrtype == dns.TypeANY {
+				return dns.RcodeFormatError
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// applyRR applies the update rr to set, as described in RFC 2136, section 3.4.2. It returns true if set
+// was changed.
+func (z *Zone) applyRR(set *recordSet, rr dns.RR) bool {
+	h := rr.Header()
+	name := strings.ToLower(h.Name)
+	apex := name == z.origin
+
+	switch h.Class {
+	case dns.ClassINET:
+		// A CNAME can't be added to a name with other records, and the other way around.
+		if h.Rrtype == dns.TypeCNAME {
+			for _, r := range set.rrset(name, dns.TypeANY) {
+				if t := r.Header().Rrtype; t != dns.TypeCNAME && t != dns.TypeRRSIG && t != dns.TypeNSEC {
+					return false
+				}
+			}
+		} else if len(set.rrset(name, dns.TypeCNAME)) > 0 {
+			return false
+		}
+		return set.add(rr)
+
+	case dns.ClassANY:
+		changed := false
+		for _, r := range set.rrset(name, h.Rrtype) {
+			if t := r.Header().Rrtype; apex && (t == dns.TypeSOA || t == dns.TypeNS) {
+				continue
+			}
+			changed = set.remove(r) || changed
+		}
+		return changed
+
+	case dns.ClassNONE:
+		if h.Rrtype == dns.TypeSOA {
+			return false
+		}
+		if apex && h.Rrtype == dns.TypeNS && len(set.rrset(name, dns.TypeNS)) == 1 {
+			return false
+		}
+		return set.remove(rr)
+	}
+	return false
+}
+
+type rrsetKey struct {
+	name  string
+	qtype uint16
+}
+
+// rrset returns the records in s with the lower cased owner name and type qtype. TypeANY returns all records
+// with the name.
+func (s *recordSet) rrset(name string, qtype uint16) []dns.RR {
+	types := s.names[name]
+	if qtype != dns.TypeANY {
+		return append([]dns.RR(nil), types[qtype]...)
+	}
+	var rrs []dns.RR
+	for _, r := range types {
+		rrs = append(rrs, r...)
+	}
+	return rrs
+}
+
+// write writes z to fileName in the master file format. The file is replaced atomically, by writing a
+// temporary file in the same directory and renaming it.
+func (z *Zone) write(fileName string) error {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails after the rename, which is fine
+
+	w := bufio.NewWriter(tmp)
+	w.WriteString(z.Apex.SOA.String() + "\n")
+	for _, r := range z.records() {
+		w.WriteString(r.String() + "\n")
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
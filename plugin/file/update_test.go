@@ -0,0 +1,306 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+const updateKey = "update.key."
+
+// tsigWriter is a ResponseWriter whose TSIG status is err.
+type tsigWriter struct {
+	test.ResponseWriter
+	err error
+}
+
+func (t *tsigWriter) TsigStatus() error { return t.err }
+
+func newRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %s", s, err)
+	}
+	return rr
+}
+
+// updateZone returns the zone in journalZone1, backed by a temporary file.
+func updateZone(t *testing.T) *Zone {
+	t.Helper()
+	name, rm, err := test.TempFile(".", journalZone1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rm)
+
+	z, err := Parse(strings.NewReader(journalZone1), "example.org.", name, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse zone: %s", err)
+	}
+	z.UpdateKeys = []string{updateKey}
+	return z
+}
+
+// sendUpdate sends m to z as it would arrive from the wire, and returns the rcode of the reply.
+func sendUpdate(t *testing.T, z *Zone, m *dns.Msg, tsigErr error) int {
+	t.Helper()
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatalf("Failed to pack update: %s", err)
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(buf); err != nil {
+		t.Fatalf("Failed to unpack update: %s", err)
+	}
+
+	rec := dnstest.NewRecorder(&tsigWriter{err: tsigErr})
+	z.update(request.Request{W: rec, Req: r})
+	return rec.Rcode
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(m *dns.Msg)
+		rcode  int
+		serial uint32
+		exists []string // records in the zone after the update
+		absent []string // records not in the zone after the update
+	}{
+		{
+			name:   "add",
+			update: func(m *dns.Msg) { m.Insert([]dns.RR{newRR(t, "D.example.org. 300 IN A 127.0.0.5")}) },
+			serial: 2,
+			exists: []string{"d.example.org.	300	IN	A	127.0.0.5"},
+		},
+		{
+			name:   "delete rrset",
+			update: func(m *dns.Msg) { m.RemoveRRset([]dns.RR{newRR(t, "a.example.org. A 127.0.0.1")}) },
+			serial: 2,
+			absent: []string{"a.example.org.	3600	IN	A	127.0.0.2"},
+		},
+		{
+			name:   "delete record",
+			update: func(m *dns.Msg) { m.Remove([]dns.RR{newRR(t, "b.example.org. 3600 IN A 127.0.0.3")}) },
+			serial: 2,
+			absent: []string{"b.example.org.	3600	IN	A	127.0.0.3"},
+		},
+		{
+			name:   "delete last apex NS",
+			update: func(m *dns.Msg) { m.Remove([]dns.RR{newRR(t, "example.org. 3600 IN NS ns.example.org.")}) },
+			serial: 1,
+			exists: []string{"example.org.	3600	IN	NS	ns.example.org."},
+		},
+		{
+			name:   "CNAME on a name with records",
+			update: func(m *dns.Msg) { m.Insert([]dns.RR{newRR(t, "a.example.org. 3600 IN CNAME b.example.org.")}) },
+			serial: 1,
+			absent: []string{"a.example.org.	3600	IN	CNAME	b.example.org."},
+		},
+		{
+			name: "new SOA",
+			update: func(m *dns.Msg) {
+				m.Insert([]dns.RR{newRR(t, "example.org. 3600 IN SOA ns.example.org. admin.example.org. 10 7200 3600 1209600 3600")})
+			},
+			serial: 10,
+		},
+		{
+			name: "name in use",
+			update: func(m *dns.Msg) {
+				m.NameUsed([]dns.RR{newRR(t, "example.org. A 127.0.0.1")})
+				m.RRsetUsed([]dns.RR{newRR(t, "example.org. SOA ns.example.org. admin.example.org. 1 1 1 1 1")})
+				m.Used([]dns.RR{newRR(t, "a.example.org. A 127.0.0.2")})
+				m.Insert([]dns.RR{newRR(t, "d.example.org. 300 IN A 127.0.0.5")})
+			},
+			serial: 2,
+			exists: []string{"d.example.org.	300	IN	A	127.0.0.5"},
+		},
+		{
+			name:   "name not in use",
+			update: func(m *dns.Msg) { m.NameNotUsed([]dns.RR{newRR(t, "a.example.org. A 127.0.0.1")}) },
+			rcode:  dns.RcodeYXDomain,
+			serial: 1,
+		},
+		{
+			name:   "name in use missing",
+			update: func(m *dns.Msg) { m.NameUsed([]dns.RR{newRR(t, "x.example.org. A 127.0.0.1")}) },
+			rcode:  dns.RcodeNameError,
+			serial: 1,
+		},
+		{
+			name:   "rrset not in use",
+			update: func(m *dns.Msg) { m.RRsetNotUsed([]dns.RR{newRR(t, "a.example.org. A 127.0.0.1")}) },
+			rcode:  dns.RcodeYXRrset,
+			serial: 1,
+		},
+		{
+			name:   "rrset with other records",
+			update: func(m *dns.Msg) { m.Used([]dns.RR{newRR(t, "a.example.org. A 127.0.0.9")}) },
+			rcode:  dns.RcodeNXRrset,
+			serial: 1,
+		},
+		{
+			name:   "not in zone",
+			update: func(m *dns.Msg) { m.Insert([]dns.RR{newRR(t, "d.example.net. 300 IN A 127.0.0.5")}) },
+			rcode:  dns.RcodeNotZone,
+			serial: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			z := updateZone(t)
+			m := new(dns.Msg)
+			m.SetUpdate("example.org.")
+			tc.update(m)
+			m.SetTsig(updateKey, dns.HmacSHA256, 300, time.Now().Unix())
+
+			if rcode := sendUpdate(t, z, m, nil); rcode != tc.rcode {
+				t.Fatalf("Expected rcode %s, got %s", dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+			}
+			if z.Apex.SOA.Serial != tc.serial {
+				t.Errorf("Expected serial %d, got %d", tc.serial, z.Apex.SOA.Serial)
+			}
+
+			// The file must hold the updated zone.
+			f, err := os.Open(z.File())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			z1, err := Parse(f, "example.org.", z.File(), 0)
+			if err != nil {
+				t.Fatalf("Failed to parse the written zone: %s", err)
+			}
+
+			for _, zone := range []*Zone{z, z1} {
+				records := sortedRecords(zone)
+				for _, rr := range tc.exists {
+					if !strings.Contains(records, rr) {
+						t.Errorf("Expected %q in the zone, got\n%s", rr, records)
+					}
+				}
+				for _, rr := range tc.absent {
+					if strings.Contains(records, rr) {
+						t.Errorf("Expected no %q in the zone, got\n%s", rr, records)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		zone    string
+		key     string
+		tsigErr error
+		rcode   int
+	}{
+		{"unsigned", "example.org.", "", nil, dns.RcodeRefused},
+		{"other key", "example.org.", "other.key.", nil, dns.RcodeRefused},
+		{"bad signature", "example.org.", updateKey, dns.ErrSig, dns.RcodeRefused},
+		{"other zone", "a.example.org.", updateKey, nil, dns.RcodeNotAuth},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			z := updateZone(t)
+			m := new(dns.Msg)
+			m.SetUpdate(tc.zone)
+			m.Insert([]dns.RR{newRR(t, "d.example.org. 300 IN A 127.0.0.5")})
+			if tc.key != "" {
+				m.SetTsig(tc.key, dns.HmacSHA256, 300, time.Now().Unix())
+			}
+
+			if rcode := sendUpdate(t, z, m, tc.tsigErr); rcode != tc.rcode {
+				t.Errorf("Expected rcode %s, got %s", dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+			}
+			if z.Apex.SOA.Serial != 1 {
+				t.Errorf("Expected the zone to be unchanged, got serial %d", z.Apex.SOA.Serial)
+			}
+		})
+	}
+}
+
+func TestRecordSetRRset(t *testing.T) {
+	set := newRecordSet([]dns.RR{
+		newRR(t, "a.example.org. 300 IN A 127.0.0.1"),
+		newRR(t, "A.example.org. 300 IN A 127.0.0.2"),
+		newRR(t, "a.example.org. 300 IN TXT \"a\""),
+		newRR(t, "b.example.org. 300 IN A 127.0.0.3"),
+	})
+
+	if x := len(set.rrset("a.example.org.", dns.TypeA)); x != 2 {
+		t.Errorf("Expected 2 A records, got %d", x)
+	}
+	if x := len(set.rrset("a.example.org.", dns.TypeANY)); x != 3 {
+		t.Errorf("Expected 3 records, got %d", x)
+	}
+
+	if !set.add(newRR(t, "a.example.org. 60 IN A 127.0.0.1")) {
+		t.Error("Expected a changed TTL to change the set")
+	}
+	if rrs := set.rrset("a.example.org.", dns.TypeA); len(rrs) != 2 || rrs[0].Header().Ttl != 60 {
+		t.Errorf("Expected the record to be replaced, got %v", rrs)
+	}
+
+	for _, r := range set.rrset("a.example.org.", dns.TypeA) {
+		set.remove(r)
+	}
+	if x := len(set.rrset("a.example.org.", dns.TypeA)); x != 0 {
+		t.Errorf("Expected no A records, got %d", x)
+	}
+	set.remove(newRR(t, "a.example.org. 0 NONE TXT \"a\""))
+	if _, ok := set.names["a.example.org."]; ok {
+		t.Error("Expected a.example.org. to be removed from the index")
+	}
+	if x := len(set.all()); x != 1 {
+		t.Errorf("Expected 1 record, got %d", x)
+	}
+}
+
+func TestUpdateNotAllowed(t *testing.T) {
+	z := updateZone(t)
+	z.UpdateKeys = nil
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{newRR(t, "d.example.org. 300 IN A 127.0.0.5")})
+
+	if rcode := sendUpdate(t, z, m, errors.New("no TSIG")); rcode != dns.RcodeRefused {
+		t.Errorf("Expected rcode REFUSED, got %s", dns.RcodeToString[rcode])
+	}
+}
+
+func TestUpdateServeDNS(t *testing.T) {
+	z := updateZone(t)
+	f := File{Zones: Zones{Z: map[string]*Zone{"example.org.": z}, Names: []string{"example.org."}}}
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{newRR(t, "d.example.org. 300 IN A 127.0.0.5")})
+	m.SetTsig(updateKey, dns.HmacSHA256, 300, time.Now().Unix())
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if rec.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected rcode NOERROR, got %s", dns.RcodeToString[rec.Rcode])
+	}
+	if e, _ := z.Search("d.example.org."); e == nil {
+		t.Errorf("Expected d.example.org. to be added")
+	}
+}
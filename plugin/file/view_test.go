@@ -0,0 +1,113 @@
+package file
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+const dbViewExternal = `
+$ORIGIN example.org.
+@	3600 IN	SOA sns.dns.icann.org. noc.dns.icann.org. 2017042745 7200 3600 1209600 3600
+	3600 IN NS a.iana-servers.net.
+www	3600 IN A 192.0.2.1
+`
+
+const dbViewInternal = `
+$ORIGIN example.org.
+@	3600 IN	SOA sns.dns.icann.org. noc.dns.icann.org. 2017042745 7200 3600 1209600 3600
+	3600 IN NS a.iana-servers.net.
+www	3600 IN A 10.0.0.1
+`
+
+func TestFileViews(t *testing.T) {
+	external, rm, err := test.TempFile(".", dbViewExternal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+	internal, rm, err := test.TempFile(".", dbViewInternal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	zones, err := fileParse(caddy.NewTestController("dns", `file `+external+` example.org {
+		view internal `+internal+` net 10.0.0.0/8
+		view local `+internal+` expr client_ip() == '::1'
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := File{Next: test.ErrorHandler(), Zones: zones}
+
+	tests := []struct {
+		remoteIP string
+		expected string
+		view     string
+	}{
+		{"10.1.2.3", "10.0.0.1", "internal"},
+		{"::1", "10.0.0.1", "local"},
+		{"192.168.1.1", "192.0.2.1", ""},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.org.", dns.TypeA)
+		w := &test.ResponseWriter{RemoteIP: tc.remoteIP}
+		rec := dnstest.NewRecorder(w)
+		if _, err := f.ServeDNS(context.Background(), rec, m); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if a := rec.Msg.Answer[0].(*dns.A).A.String(); a != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, a)
+		}
+		if v := f.SelectView(context.Background(), request.Request{W: w, Req: m}); v != tc.view {
+			t.Errorf("Test %d: expected view %q, got %q", i, tc.view, v)
+		}
+	}
+}
+
+func TestFileParseViews(t *testing.T) {
+	name, rm, err := test.TempFile(".", dbViewExternal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+	}{
+		{`file ` + name + ` example.org {
+			view internal ` + name + ` net 10.0.0.0/8 192.168.0.1
+		}`, false},
+		{`file ` + name + ` example.org {
+			view internal
+		}`, true},
+		{`file ` + name + ` example.org {
+			view internal ` + name + `
+		}`, true},
+		{`file ` + name + ` example.org {
+			view internal ` + name + ` net 10.0.0.0/33
+		}`, true},
+		{`file ` + name + ` example.org {
+			view internal ` + name + ` expr nope()
+		}`, true},
+		{`file ` + name + ` example.org {
+			reload 0
+			view internal /does/not/exist net 10.0.0.0/8
+		}`, true},
+	}
+	for i, tc := range tests {
+		_, err := fileParse(caddy.NewTestController("dns", tc.input))
+		if (err != nil) != tc.shouldErr {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.shouldErr, err)
+		}
+	}
+}
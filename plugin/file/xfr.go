@@ -16,30 +16,51 @@ func (f File) Transfer(zone string, serial uint32) (<-chan []dns.RR, error) {
 	return z.Transfer(serial)
 }
 
-// Transfer transfers a zone with serial in the returned channel and implements IXFR fallback, by just
-// sending a single SOA record.
+// Transfer transfers a zone with serial in the returned channel. If serial is not 0, this is an IXFR: if the
+// zone hasn't changed, only the SOA record is sent. If the journal of the zone goes back to serial, the
+// differences are sent (RFC 1995), otherwise the whole zone is, like an AXFR.
 func (z *Zone) Transfer(serial uint32) (<-chan []dns.RR, error) {
 	// get soa and apex
 	apex, err := z.ApexIfDefined()
 	if err != nil {
 		return nil, err
 	}
+	soa := apex[0].(*dns.SOA)
+
+	var ixfr []dns.RR
+	if serial != 0 && soa.Serial != serial {
+		ixfr = z.ixfr(serial, soa)
+	}
 
 	ch := make(chan []dns.RR)
 	go func() {
-		if serial != 0 && apex[0].(*dns.SOA).Serial == serial { // ixfr fallback, only send SOA
-			ch <- []dns.RR{apex[0]}
+		if serial != 0 && soa.Serial == serial { // ixfr fallback, only send SOA
+			ch <- []dns.RR{soa}
 
 			close(ch)
 			return
 		}
 
+		if ixfr != nil {
+			for len(ixfr) > 0 {
+				n := min(len(ixfr), ixfrChunk)
+				ch <- ixfr[:n]
+				ixfr = ixfr[n:]
+			}
+			close(ch)
+			return
+		}
+
 		ch <- apex
 		z.Walk(func(e *tree.Elem, _ map[uint16][]dns.RR) error { ch <- e.All(); return nil })
-		ch <- []dns.RR{apex[0]}
+		z.NSEC3.Walk(func(e *tree.Elem, _ map[uint16][]dns.RR) error { ch <- e.All(); return nil })
+		ch <- []dns.RR{soa}
 
 		close(ch)
 	}()
 
 	return ch, nil
 }
+
+// ixfrChunk is the number of records of an incremental zone transfer sent in one go.
+const ixfrChunk = 100
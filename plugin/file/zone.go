@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"github.com/coredns/coredns/plugin/file/tree"
+	"github.com/coredns/coredns/plugin/pkg/parse"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/plugin/transfer"
 
 	"github.com/miekg/dns"
 )
@@ -20,16 +22,25 @@ type Zone struct {
 	file    string
 	*tree.Tree
 	Apex
+	NSEC3   *tree.Tree // NSEC3 records and their signatures, these are kept out of the zone's names
 	Expired bool
 
 	sync.RWMutex
 
 	StartupOnce  sync.Once
 	TransferFrom []string
+	TransferKeys map[string]parse.Key // TSIG keys, by primary in TransferFrom, for the primaries that require TSIG
+	TsigSecret   map[string]string    // TSIG secrets, by key name
 
 	ReloadInterval time.Duration
 	reloadShutdown chan bool
 
+	UpdateKeys []string           // TSIG key names that may update the zone (RFC 2136)
+	updates    sync.Mutex         // serializes the updates and reloads of the zone
+	notifier   *transfer.Transfer // sends the notifies when the zone is changed
+
+	journal []*diff // differences between the last versions of the zone, oldest first
+
 	Upstream *upstream.Upstream // Upstream for looking up external names during the resolution process.
 }
 
@@ -48,6 +59,7 @@ func NewZone(name, file string) *Zone {
 		origLen:        dns.CountLabel(dns.Fqdn(name)),
 		file:           filepath.Clean(file),
 		Tree:           &tree.Tree{},
+		NSEC3:          &tree.Tree{},
 		reloadShutdown: make(chan bool),
 	}
 }
@@ -56,6 +68,8 @@ func NewZone(name, file string) *Zone {
 func (z *Zone) Copy() *Zone {
 	z1 := NewZone(z.origin, z.file)
 	z1.TransferFrom = z.TransferFrom
+	z1.TransferKeys = z.TransferKeys
+	z1.TsigSecret = z.TsigSecret
 	z1.Expired = z.Expired
 
 	z1.Apex = z.Apex
@@ -66,6 +80,8 @@ func (z *Zone) Copy() *Zone {
 func (z *Zone) CopyWithoutApex() *Zone {
 	z1 := NewZone(z.origin, z.file)
 	z1.TransferFrom = z.TransferFrom
+	z1.TransferKeys = z.TransferKeys
+	z1.TsigSecret = z.TsigSecret
 	z1.Expired = z.Expired
 
 	return z1
@@ -89,14 +105,18 @@ func (z *Zone) Insert(r dns.RR) error {
 
 		z.Apex.SOA = r.(*dns.SOA)
 		return nil
-	case dns.TypeNSEC3, dns.TypeNSEC3PARAM:
-		return fmt.Errorf("NSEC3 zone is not supported, dropping RR: %s for zone: %s", r.Header().Name, z.origin)
+	case dns.TypeNSEC3:
+		z.NSEC3.Insert(r)
+		return nil
 	case dns.TypeRRSIG:
 		x := r.(*dns.RRSIG)
 		switch x.TypeCovered {
 		case dns.TypeSOA:
 			z.Apex.SIGSOA = append(z.Apex.SIGSOA, x)
 			return nil
+		case dns.TypeNSEC3:
+			z.NSEC3.Insert(r)
+			return nil
 		case dns.TypeNS:
 			if r.Header().Name == z.origin {
 				z.Apex.SIGNS = append(z.Apex.SIGNS, x)
@@ -73,6 +73,13 @@ func (t *Transport) Dial(proto string) (*persistConn, bool, error) {
 
 // Connect selects an upstream, sends the request and waits for a response.
 func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options) (*dns.Msg, error) {
+	if p.doh != nil {
+		return p.connectDoH(ctx, state)
+	}
+	if p.doq != nil {
+		return p.connectDoQ(ctx, state)
+	}
+
 	start := time.Now()
 
 	proto := ""
@@ -97,6 +104,10 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 	}
 
 	pc.c.SetWriteDeadline(time.Now().Add(maxTimeout))
+
+	// Abort the exchange when ctx is done, i.e. when another upstream won the race.
+	stop := context.AfterFunc(ctx, func() { pc.c.SetDeadline(time.Now()) })
+	defer stop()
 	// records the origin Id before upstream.
 	originId := state.Req.Id
 	state.Req.Id = dns.Id()
@@ -106,6 +117,9 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 
 	if err := pc.c.WriteMsg(state.Req); err != nil {
 		pc.c.Close() // not giving it back
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		if err == io.EOF && cached {
 			return nil, ErrCachedClosed
 		}
@@ -114,10 +128,18 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 
 	var ret *dns.Msg
 	pc.c.SetReadDeadline(time.Now().Add(readTimeout))
+	if ctx.Err() != nil {
+		// Setting the read deadline undid a cancellation that came in before it.
+		pc.c.Close()
+		return nil, ctx.Err()
+	}
 	for {
 		ret, err = pc.c.ReadMsg()
 		if err != nil {
 			pc.c.Close() // not giving it back
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			if err == io.EOF && cached {
 				return nil, ErrCachedClosed
 			}
@@ -135,7 +157,12 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 	// recovery the origin Id after upstream.
 	ret.Id = originId
 
-	p.transport.Yield(pc)
+	if !stop() && ctx.Err() != nil {
+		// The deadline was reset by the cancellation, don't hand out this connection again.
+		pc.c.Close()
+	} else {
+		p.transport.Yield(pc)
+	}
 
 	rc, ok := dns.RcodeToString[ret.Rcode]
 	if !ok {
@@ -144,7 +171,9 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 
 	RequestCount.WithLabelValues(p.addr).Add(1)
 	RcodeCount.WithLabelValues(rc, p.addr).Add(1)
-	RequestDuration.WithLabelValues(p.addr, rc).Observe(time.Since(start).Seconds())
+	rtt := time.Since(start)
+	RequestDuration.WithLabelValues(p.addr, rc).Observe(rtt.Seconds())
+	p.updateRtt(rtt)
 
 	return ret, nil
 }
@@ -2,10 +2,12 @@ package forward
 
 import (
 	"net"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/coredns/coredns/plugin/dnstap/msg"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/request"
 
 	tap "github.com/dnstap/golang-dnstap"
@@ -14,18 +16,24 @@ import (
 
 // toDnstap will send the forward and received message to the dnstap plugin.
 func toDnstap(f *Forward, host string, state request.Request, opts options, reply *dns.Msg, start time.Time) {
+	t := state.Proto()
+	if u, err := url.Parse(host); err == nil && u.Scheme == transport.HTTPS {
+		host = u.Host // DoH always runs over TCP
+		t = "tcp"
+	} else {
+		switch {
+		case opts.forceTCP:
+			t = "tcp"
+		case opts.preferUDP:
+			t = "udp"
+		}
+	}
+
 	h, p, _ := net.SplitHostPort(host)      // this is preparsed and can't err here
 	port, _ := strconv.ParseUint(p, 10, 32) // same here
 	ip := net.ParseIP(h)
 
 	var ta net.Addr = &net.UDPAddr{IP: ip, Port: int(port)}
-	t := state.Proto()
-	switch {
-	case opts.forceTCP:
-		t = "tcp"
-	case opts.preferUDP:
-		t = "udp"
-	}
 
 	if t == "tcp" {
 		ta = &net.TCPAddr{IP: ip, Port: int(port)}
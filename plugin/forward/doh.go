@@ -0,0 +1,147 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// dohTransport sends DNS messages to a DNS-over-HTTPS (RFC 8484) upstream. Connections are kept
+// by the underlying http.Transport, which negotiates HTTP/2 and multiplexes queries over a single
+// connection.
+type dohTransport struct {
+	url    string
+	tr     *http.Transport
+	client *http.Client
+}
+
+func newDoHTransport(url string) *dohTransport {
+	tr := &http.Transport{
+		ForceAttemptHTTP2:   true,
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     defaultExpire,
+		TLSHandshakeTimeout: maxDialTimeout,
+		DialContext:         (&net.Dialer{Timeout: maxDialTimeout}).DialContext,
+		TLSClientConfig:     new(tls.Config),
+	}
+	return &dohTransport{url: url, tr: tr, client: &http.Client{Transport: tr}}
+}
+
+// SetTLSConfig sets a copy of cfg as the TLS config used for the HTTPS connections. It is copied because
+// the HTTP/2 setup adds its ALPN token to the config, which is shared with the other proxies.
+func (d *dohTransport) SetTLSConfig(cfg *tls.Config) { d.tr.TLSClientConfig = cfg.Clone() }
+
+// SetExpire sets the time after which an idle connection is closed.
+func (d *dohTransport) SetExpire(expire time.Duration) { d.tr.IdleConnTimeout = expire }
+
+// Stop closes all idle connections.
+func (d *dohTransport) Stop() { d.tr.CloseIdleConnections() }
+
+// exchange POSTs m to the upstream and returns the reply. The message ID is set to zero on the
+// wire, as recommended by RFC 8484, Section 4.1.
+func (d *dohTransport) exchange(ctx context.Context, m *dns.Msg, timeout time.Duration) (*dns.Msg, error) {
+	id := m.Id
+	m.Id = 0
+	buf, err := m.Pack()
+	m.Id = id
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				ConnCacheHitsCount.WithLabelValues(d.url, transport.HTTPS).Add(1)
+				return
+			}
+			ConnCacheMissesCount.WithLabelValues(d.url, transport.HTTPS).Add(1)
+		},
+	}
+	ctx = httptrace.WithClientTrace(ctx, trace)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", doh.MimeType)
+	req.Header.Set("accept", doh.MimeType)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status from %s: %d", d.url, resp.StatusCode)
+	}
+
+	ret, err := doh.ResponseToMsg(resp)
+	if err != nil {
+		return nil, err
+	}
+	ret.Id = id
+	return ret, nil
+}
+
+// connectDoH sends the request in state to a DoH upstream and waits for the response.
+func (p *Proxy) connectDoH(ctx context.Context, state request.Request) (*dns.Msg, error) {
+	start := time.Now()
+
+	ret, err := p.doh.exchange(ctx, state.Req, readTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, ok := dns.RcodeToString[ret.Rcode]
+	if !ok {
+		rc = strconv.Itoa(ret.Rcode)
+	}
+
+	RequestCount.WithLabelValues(p.addr).Add(1)
+	RcodeCount.WithLabelValues(rc, p.addr).Add(1)
+	rtt := time.Since(start)
+	RequestDuration.WithLabelValues(p.addr, rc).Observe(rtt.Seconds())
+	p.updateRtt(rtt)
+
+	return ret, nil
+}
+
+// parseDoHURL parses a https:// destination and returns it in its canonical form, i.e. with an
+// explicit port and with doh.Path as the path when none is given.
+func parseDoHURL(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != transport.HTTPS || u.Hostname() == "" {
+		return "", fmt.Errorf("not a valid DNS-over-HTTPS URL: %q", s)
+	}
+	if u.User != nil || u.Fragment != "" {
+		return "", fmt.Errorf("not a valid DNS-over-HTTPS URL: %q", s)
+	}
+	port := u.Port()
+	if port == "" {
+		port = transport.HTTPSPort
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), port)
+	if u.Path == "" || u.Path == "/" {
+		u.Path = doh.Path
+	}
+	return u.String(), nil
+}
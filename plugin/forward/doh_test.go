@@ -0,0 +1,149 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseDoHURL(t *testing.T) {
+	tests := []struct {
+		in        string
+		expected  string
+		shouldErr bool
+	}{
+		{"https://dns.example.org", "https://dns.example.org:443/dns-query", false},
+		{"https://dns.example.org/", "https://dns.example.org:443/dns-query", false},
+		{"https://dns.example.org:8443/resolve", "https://dns.example.org:8443/resolve", false},
+		{"https://127.0.0.1", "https://127.0.0.1:443/dns-query", false},
+		{"https://[::1]:8443", "https://[::1]:8443/dns-query", false},
+		{"https://user@dns.example.org", "", true},
+		{"https:///dns-query", "", true},
+		{"tls://dns.example.org", "", true},
+	}
+
+	for i, tc := range tests {
+		u, err := parseDoHURL(tc.in)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error for %q, got none", i, tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error for %q, got %s", i, tc.in, err)
+			continue
+		}
+		if u != tc.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, tc.expected, u)
+		}
+	}
+}
+
+func newDoHTestServer(t *testing.T, h dns.HandlerFunc) *httptest.Server {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("Expected HTTP/2 request, got %s", r.Proto)
+		}
+		m, err := doh.RequestToMsg(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		h(rec, m)
+		buf, _ := rec.Msg.Pack()
+		w.Header().Set("content-type", doh.MimeType)
+		w.Write(buf)
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	return s
+}
+
+func TestProxyDoH(t *testing.T) {
+	s := newDoHTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Id != 0 {
+			t.Errorf("Expected message ID 0 on the wire, got %d", r.Id)
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	c := caddy.NewTestController("dns", "forward . "+s.URL+"/dns-query")
+	fs, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f := fs[0]
+	cfg := &tls.Config{InsecureSkipVerify: true}
+	f.proxies[0].SetTLSConfig(cfg)
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	for i := 0; i < 2; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Expected to receive reply, but got: %s", err)
+		}
+		if rec.Msg.Id != m.Id {
+			t.Errorf("Expected message ID %d, got %d", m.Id, rec.Msg.Id)
+		}
+		if x := rec.Msg.Answer[0].Header().Name; x != "example.org." {
+			t.Errorf("Expected %s, got %s", "example.org.", x)
+		}
+	}
+	// The config is shared with other proxies, HTTP/2 must not add its ALPN token to it.
+	if len(cfg.NextProtos) != 0 {
+		t.Errorf("Expected the TLS config to be unchanged, got NextProtos %v", cfg.NextProtos)
+	}
+}
+
+func TestHealthDoH(t *testing.T) {
+	i := uint32(0)
+	s := newDoHTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Name == "." && r.RecursionDesired {
+			atomic.AddUint32(&i, 1)
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	p := NewProxy(s.URL+"/dns-query", transport.HTTPS)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	defer p.finalizer()
+
+	if err := p.health.Check(p); err != nil {
+		t.Fatalf("Expected healthy upstream, got: %s", err)
+	}
+	if x := atomic.LoadUint32(&i); x != 1 {
+		t.Errorf("Expected number of health checks with RecursionDesired==true to be %d, got %d", 1, x)
+	}
+
+	s.Close()
+	if err := p.health.Check(p); err == nil {
+		t.Fatal("Expected unhealthy upstream after server shutdown")
+	}
+	if x := atomic.LoadUint32(&p.fails); x != 1 {
+		t.Errorf("Expected %d fails, got %d", 1, x)
+	}
+}
@@ -0,0 +1,218 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// doqTransport sends DNS messages to a DNS-over-QUIC (RFC 9250) upstream. A single QUIC connection
+// is kept open and every query is sent on a new stream of that connection.
+type doqTransport struct {
+	addr      string
+	tlsConfig *tls.Config
+	expire    time.Duration
+
+	mu      sync.Mutex
+	conn    quic.Connection
+	dialing chan struct{} // closed when the dial in progress is done, nil when there is none
+}
+
+func newDoQTransport(addr string) *doqTransport {
+	return &doqTransport{addr: addr, tlsConfig: doqTLSConfig(new(tls.Config)), expire: defaultExpire}
+}
+
+// doqTLSConfig returns a copy of cfg with the ALPN token "doq" set, see RFC 9250, Section 4.1.1.
+func doqTLSConfig(cfg *tls.Config) *tls.Config {
+	cfg = cfg.Clone()
+	cfg.NextProtos = []string{"doq"}
+	return cfg
+}
+
+// SetTLSConfig sets the TLS config used for the QUIC connections.
+func (d *doqTransport) SetTLSConfig(cfg *tls.Config) {
+	d.mu.Lock()
+	d.tlsConfig = doqTLSConfig(cfg)
+	d.mu.Unlock()
+}
+
+// SetExpire sets the time after which an idle connection is closed.
+func (d *doqTransport) SetExpire(expire time.Duration) {
+	d.mu.Lock()
+	d.expire = expire
+	d.mu.Unlock()
+}
+
+// Stop closes the connection to the upstream.
+func (d *doqTransport) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn != nil {
+		d.conn.CloseWithError(dnsserver.DoQCodeNoError, "")
+		d.conn = nil
+	}
+}
+
+// dial returns the cached connection if it is still usable, otherwise a new connection is set up.
+// The returned bool is true when the connection came from the cache. Only a single connection is dialed
+// at a time, concurrent callers wait for it instead of dialing their own.
+func (d *doqTransport) dial(ctx context.Context) (quic.Connection, bool, error) {
+	d.mu.Lock()
+	for {
+		if d.conn != nil {
+			if d.conn.Context().Err() == nil {
+				conn := d.conn
+				d.mu.Unlock()
+				ConnCacheHitsCount.WithLabelValues(d.addr, transport.QUIC).Add(1)
+				return conn, true, nil
+			}
+			d.conn = nil
+		}
+		if d.dialing == nil {
+			break
+		}
+		dialing := d.dialing
+		d.mu.Unlock()
+		select {
+		case <-dialing:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		d.mu.Lock()
+	}
+	dialing := make(chan struct{})
+	d.dialing = dialing
+	tlsConfig, expire := d.tlsConfig, d.expire
+	d.mu.Unlock()
+
+	ConnCacheMissesCount.WithLabelValues(d.addr, transport.QUIC).Add(1)
+
+	ctx, cancel := context.WithTimeout(ctx, maxDialTimeout)
+	defer cancel()
+
+	conn, err := quic.DialAddr(ctx, d.addr, tlsConfig, &quic.Config{MaxIdleTimeout: expire})
+
+	d.mu.Lock()
+	if err == nil {
+		d.conn = conn
+	}
+	d.dialing = nil
+	d.mu.Unlock()
+	close(dialing)
+
+	if err != nil {
+		return nil, false, err
+	}
+	return conn, false, nil
+}
+
+// drop removes conn from the cache and closes it.
+func (d *doqTransport) drop(conn quic.Connection) {
+	d.mu.Lock()
+	if d.conn == conn {
+		d.conn = nil
+	}
+	d.mu.Unlock()
+	conn.CloseWithError(dnsserver.DoQCodeNoError, "")
+}
+
+// exchange sends m on a new stream and returns the reply. The message ID is set to zero on the
+// wire, as required by RFC 9250, Section 4.2.1.
+func (d *doqTransport) exchange(ctx context.Context, m *dns.Msg, timeout time.Duration) (*dns.Msg, error) {
+	id := m.Id
+	m.Id = 0
+	buf, err := m.Pack()
+	m.Id = id
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, cached, err := d.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil && cached {
+		// The upstream may have closed the connection without us noticing, try once more with a new one.
+		d.drop(conn)
+		if conn, _, err = d.dial(ctx); err != nil {
+			return nil, err
+		}
+		stream, err = conn.OpenStreamSync(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, _ := ctx.Deadline()
+	stream.SetDeadline(deadline)
+
+	// Abort the exchange when ctx is done, i.e. when another upstream won the race.
+	stop := context.AfterFunc(ctx, func() {
+		stream.CancelRead(quic.StreamErrorCode(dnsserver.DoQCodeRequestCancelled))
+		stream.CancelWrite(quic.StreamErrorCode(dnsserver.DoQCodeRequestCancelled))
+	})
+	defer stop()
+
+	// Only a single query is sent per stream, the FIN tells the upstream no more data follows.
+	if _, err := stream.Write(dnsserver.AddPrefix(buf)); err != nil {
+		stream.CancelRead(quic.StreamErrorCode(dnsserver.DoQCodeNoError))
+		return nil, err
+	}
+	stream.Close()
+
+	var length uint16
+	if err := binary.Read(stream, binary.BigEndian, &length); err != nil {
+		stream.CancelRead(quic.StreamErrorCode(dnsserver.DoQCodeNoError))
+		return nil, err
+	}
+	buf = make([]byte, length)
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		stream.CancelRead(quic.StreamErrorCode(dnsserver.DoQCodeNoError))
+		return nil, err
+	}
+
+	ret := new(dns.Msg)
+	if err := ret.Unpack(buf); err != nil {
+		return nil, err
+	}
+	ret.Id = id
+	return ret, nil
+}
+
+// connectDoQ sends the request in state to a DoQ upstream and waits for the response.
+func (p *Proxy) connectDoQ(ctx context.Context, state request.Request) (*dns.Msg, error) {
+	start := time.Now()
+
+	ret, err := p.doq.exchange(ctx, state.Req, readTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, ok := dns.RcodeToString[ret.Rcode]
+	if !ok {
+		rc = strconv.Itoa(ret.Rcode)
+	}
+
+	RequestCount.WithLabelValues(p.addr).Add(1)
+	RcodeCount.WithLabelValues(rc, p.addr).Add(1)
+	rtt := time.Since(start)
+	RequestDuration.WithLabelValues(p.addr, rc).Observe(rtt.Seconds())
+	p.updateRtt(rtt)
+
+	return ret, nil
+}
@@ -0,0 +1,211 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// newDoQTestServer starts a DNS-over-QUIC server that answers every stream with h. The number of
+// connections accepted is counted in conns.
+func newDoQTestServer(t *testing.T, conns *uint32, h dns.HandlerFunc) *quic.Listener {
+	cert, err := tls.LoadX509KeyPair("../tls/test_cert.pem", "../tls/test_key.pem")
+	if err != nil {
+		t.Fatalf("Failed to load test certificate: %s", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: []string{"doq"}}
+
+	l, err := quic.ListenAddr("127.0.0.1:0", cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to start DoQ server: %s", err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			atomic.AddUint32(conns, 1)
+			go func() {
+				for {
+					stream, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					var length uint16
+					if err := binary.Read(stream, binary.BigEndian, &length); err != nil {
+						return
+					}
+					buf := make([]byte, length)
+					if _, err := io.ReadFull(stream, buf); err != nil {
+						return
+					}
+					m := new(dns.Msg)
+					if err := m.Unpack(buf); err != nil {
+						return
+					}
+					rec := dnstest.NewRecorder(&test.ResponseWriter{})
+					h(rec, m)
+					buf, _ = rec.Msg.Pack()
+					stream.Write(dnsserver.AddPrefix(buf))
+					stream.Close()
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+func TestProxyDoQ(t *testing.T) {
+	conns := uint32(0)
+	l := newDoQTestServer(t, &conns, func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Id != 0 {
+			t.Errorf("Expected message ID 0 on the wire, got %d", r.Id)
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer l.Close()
+
+	c := caddy.NewTestController("dns", "forward . quic://"+l.Addr().String())
+	fs, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f := fs[0]
+	f.proxies[0].SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	for i := 0; i < 2; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Expected to receive reply, but got: %s", err)
+		}
+		if rec.Msg.Id != m.Id {
+			t.Errorf("Expected message ID %d, got %d", m.Id, rec.Msg.Id)
+		}
+		if x := rec.Msg.Answer[0].Header().Name; x != "example.org." {
+			t.Errorf("Expected %s, got %s", "example.org.", x)
+		}
+	}
+
+	if x := atomic.LoadUint32(&conns); x != 1 {
+		t.Errorf("Expected the connection to be reused, got %d connections", x)
+	}
+}
+
+func TestDoQConcurrentDial(t *testing.T) {
+	conns := uint32(0)
+	l := newDoQTestServer(t, &conns, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer l.Close()
+
+	d := newDoQTransport(l.Addr().String())
+	d.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	defer d.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := new(dns.Msg)
+			m.SetQuestion("example.org.", dns.TypeA)
+			if _, err := d.exchange(context.TODO(), m, readTimeout); err != nil {
+				t.Errorf("Expected to receive reply, but got: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if x := atomic.LoadUint32(&conns); x != 1 {
+		t.Errorf("Expected a single connection for concurrent queries, got %d connections", x)
+	}
+}
+
+func TestDoQCancel(t *testing.T) {
+	conns := uint32(0)
+	release := make(chan struct{})
+	l := newDoQTestServer(t, &conns, func(w dns.ResponseWriter, r *dns.Msg) {
+		<-release
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer l.Close()
+	defer close(release)
+
+	d := newDoQTransport(l.Addr().String())
+	d.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	defer d.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	start := time.Now()
+	if _, err := d.exchange(ctx, m, 5*time.Second); err == nil {
+		t.Fatal("Expected error for a canceled exchange, got none")
+	}
+	if x := time.Since(start); x > time.Second {
+		t.Errorf("Expected the exchange to be aborted when canceled, took %s", x)
+	}
+}
+
+func TestHealthDoQ(t *testing.T) {
+	i := uint32(0)
+	conns := uint32(0)
+	l := newDoQTestServer(t, &conns, func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Name == "." && r.RecursionDesired {
+			atomic.AddUint32(&i, 1)
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+
+	p := NewProxy(l.Addr().String(), transport.QUIC)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	defer p.finalizer()
+
+	if err := p.health.Check(p); err != nil {
+		t.Fatalf("Expected healthy upstream, got: %s", err)
+	}
+	if x := atomic.LoadUint32(&i); x != 1 {
+		t.Errorf("Expected number of health checks with RecursionDesired==true to be %d, got %d", 1, x)
+	}
+
+	l.Close()
+	p.doq.Stop()
+	if err := p.health.Check(p); err == nil {
+		t.Fatal("Expected unhealthy upstream after server shutdown")
+	}
+	if x := atomic.LoadUint32(&p.fails); x != 1 {
+		t.Errorf("Expected %d fails, got %d", 1, x)
+	}
+}
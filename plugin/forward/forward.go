@@ -42,6 +42,7 @@ type Forward struct {
 	maxfails      uint32
 	expire        time.Duration
 	maxConcurrent int64
+	race          int // number of upstreams to query at once, 0 for one after the other
 
 	opts options // also here for testing
 
@@ -96,12 +97,16 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		}
 	}
 
+	if f.race > 1 {
+		return f.serveRace(ctx, state)
+	}
+
 	fails := 0
 	var span, child ot.Span
 	var upstreamErr error
 	span = ot.SpanFromContext(ctx)
 	i := 0
-	list := f.List()
+	list := f.list(state)
 	deadline := time.Now().Add(defaultTimeout)
 	start := time.Now()
 	for time.Now().Before(deadline) {
@@ -165,6 +170,9 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		upstreamErr = err
 
 		if err != nil {
+			// Count a failed exchange as a slow one, so the fastest policy moves away from this upstream.
+			proxy.updateRtt(readTimeout)
+
 			// Kick off health check to see if *our* upstream is broken.
 			if f.maxfails != 0 {
 				proxy.Healthcheck()
@@ -227,6 +235,15 @@ func (f *Forward) PreferUDP() bool { return f.opts.preferUDP }
 // List returns a set of proxies to be used for this client depending on the policy in f.
 func (f *Forward) List() []*Proxy { return f.p.List(f.proxies) }
 
+// list returns the set of proxies to be used for the query in state. It is List, except for policies
+// that select upstreams based on the query name.
+func (f *Forward) list(state request.Request) []*Proxy {
+	if np, ok := f.p.(namePolicy); ok {
+		return np.ListName(f.proxies, state.Name())
+	}
+	return f.List()
+}
+
 var (
 	// ErrNoHealthy means no healthy proxies left.
 	ErrNoHealthy = errors.New("no healthy proxies")
@@ -1,6 +1,7 @@
 package forward
 
 import (
+	"context"
 	"crypto/tls"
 	"sync/atomic"
 	"time"
@@ -43,6 +44,12 @@ func NewHealthChecker(trans string, recursionDesired bool, domain string) Health
 		c.WriteTimeout = hcWriteTimeout
 
 		return &dnsHc{c: c, recursionDesired: recursionDesired, domain: domain}
+
+	case transport.HTTPS:
+		return &proxyHc{exchange: dohExchange, recursionDesired: recursionDesired, domain: domain}
+
+	case transport.QUIC:
+		return &proxyHc{exchange: doqExchange, recursionDesired: recursionDesired, domain: domain}
 	}
 
 	log.Warningf("No healthchecker for transport %q", trans)
@@ -104,3 +111,56 @@ func (h *dnsHc) send(addr string) error {
 
 	return err
 }
+
+// proxyHc is a health checker for a DNS-over-HTTPS or DNS-over-QUIC endpoint. It sends its queries
+// with exchange over the proxy's own transport, so the TLS config and connections are shared with
+// regular queries.
+type proxyHc struct {
+	exchange         func(ctx context.Context, p *Proxy, m *dns.Msg, timeout time.Duration) (*dns.Msg, error)
+	recursionDesired bool
+	domain           string
+}
+
+func (h *proxyHc) SetTLSConfig(cfg *tls.Config) {}
+
+func (h *proxyHc) SetRecursionDesired(recursionDesired bool) {
+	h.recursionDesired = recursionDesired
+}
+func (h *proxyHc) GetRecursionDesired() bool {
+	return h.recursionDesired
+}
+
+func (h *proxyHc) SetDomain(domain string) {
+	h.domain = domain
+}
+func (h *proxyHc) GetDomain() string {
+	return h.domain
+}
+
+func (h *proxyHc) SetTCPTransport() {}
+
+// Check is used as the up.Func in the up.Probe.
+func (h *proxyHc) Check(p *Proxy) error {
+	ping := new(dns.Msg)
+	ping.SetQuestion(h.domain, dns.TypeNS)
+	ping.MsgHdr.RecursionDesired = h.recursionDesired
+
+	_, err := h.exchange(context.Background(), p, ping, hcReadTimeout)
+	if err != nil {
+		HealthcheckFailureCount.WithLabelValues(p.addr).Add(1)
+		atomic.AddUint32(&p.fails, 1)
+		return err
+	}
+
+	atomic.StoreUint32(&p.fails, 0)
+	return nil
+}
+
+// dohExchange and doqExchange are the exchange funcs of proxyHc for DoH and DoQ.
+func dohExchange(ctx context.Context, p *Proxy, m *dns.Msg, timeout time.Duration) (*dns.Msg, error) {
+	return p.doh.exchange(ctx, m, timeout)
+}
+
+func doqExchange(ctx context.Context, p *Proxy, m *dns.Msg, timeout time.Duration) (*dns.Msg, error) {
+	return p.doq.exchange(ctx, m, timeout)
+}
@@ -58,4 +58,10 @@ var (
 		Name:      "conn_cache_misses_total",
 		Help:      "Counter of connection cache misses per upstream and protocol.",
 	}, []string{"to", "proto"})
+	RaceCancelCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "race_cancels_total",
+		Help:      "Counter of requests per upstream that were cancelled because another upstream answered first.",
+	}, []string{"to"})
 )
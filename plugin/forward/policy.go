@@ -1,6 +1,8 @@
 package forward
 
 import (
+	"hash/fnv"
+	"sort"
 	"sync/atomic"
 	"time"
 
@@ -13,6 +15,11 @@ type Policy interface {
 	String() string
 }
 
+// namePolicy is implemented by policies that select upstreams based on the query name.
+type namePolicy interface {
+	ListName(p []*Proxy, name string) []*Proxy
+}
+
 // random is a policy that implements random upstream selection.
 type random struct{}
 
@@ -65,4 +72,72 @@ func (r *sequential) List(p []*Proxy) []*Proxy {
 	return p
 }
 
+// fastest is a policy that orders hosts by their smoothed round trip time, fastest first. Every
+// fastestExplore queries (on average) a random host is moved to the front instead, so that the
+// round trip time of slower hosts is kept up to date and recovered hosts are noticed.
+type fastest struct{}
+
+func (r *fastest) String() string { return "fastest" }
+
+func (r *fastest) List(p []*Proxy) []*Proxy {
+	if len(p) == 1 {
+		return p
+	}
+
+	fast := make([]*Proxy, len(p))
+	copy(fast, p)
+	sort.SliceStable(fast, func(i, j int) bool { return fast[i].rtt() < fast[j].rtt() })
+
+	if rn.Int()%fastestExplore == 0 {
+		i := 1 + rn.Int()%(len(fast)-1)
+		fast[0], fast[i] = fast[i], fast[0]
+	}
+	return fast
+}
+
+const fastestExplore = 20
+
+// consistentHash is a policy that orders hosts by rendezvous hashing on the query name, so that all
+// queries for a name go to the same host. When that host is down, the next one in the list is used;
+// it is the same for all queries for that name too.
+type consistentHash struct{}
+
+func (r *consistentHash) String() string { return "consistent_hash" }
+
+// List returns p as is, without a query name there is nothing to hash.
+func (r *consistentHash) List(p []*Proxy) []*Proxy { return p }
+
+func (r *consistentHash) ListName(p []*Proxy, name string) []*Proxy {
+	if len(p) == 1 {
+		return p
+	}
+
+	scores := make(map[*Proxy]uint64, len(p))
+	for _, p1 := range p {
+		scores[p1] = rendezvousScore(name, p1.addr)
+	}
+
+	hashed := make([]*Proxy, len(p))
+	copy(hashed, p)
+	sort.SliceStable(hashed, func(i, j int) bool { return scores[hashed[i]] > scores[hashed[j]] })
+	return hashed
+}
+
+// rendezvousScore returns the score of host addr for name. The FNV-1a hash is put through the
+// splitmix64 finalizer, because FNV by itself distributes similar inputs poorly.
+func rendezvousScore(name, addr string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(addr))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
 var rn = rand.New(time.Now().UnixNano())
@@ -0,0 +1,87 @@
+package forward
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFastest(t *testing.T) {
+	proxies := []*Proxy{
+		{addr: "1.1.1.1:53", avgRtt: int64(30 * time.Millisecond)},
+		{addr: "2.2.2.2:53", avgRtt: int64(10 * time.Millisecond)},
+		{addr: "3.3.3.3:53", avgRtt: int64(20 * time.Millisecond)},
+	}
+	f := &fastest{}
+
+	first := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		got := f.List(proxies)
+		if len(got) != len(proxies) {
+			t.Fatalf("Expected %d proxies, got %d", len(proxies), len(got))
+		}
+		first[got[0].addr]++
+		if got[0].addr == "2.2.2.2:53" && (got[1].addr != "3.3.3.3:53" || got[2].addr != "1.1.1.1:53") {
+			t.Fatalf("Expected proxies to be ordered by rtt, got %s, %s, %s", got[0].addr, got[1].addr, got[2].addr)
+		}
+	}
+
+	if first["2.2.2.2:53"] < 900 {
+		t.Errorf("Expected the fastest proxy to be picked first most of the time, got %d out of 1000", first["2.2.2.2:53"])
+	}
+	if first["2.2.2.2:53"] == 1000 {
+		t.Errorf("Expected slower proxies to be explored, but they were never picked first")
+	}
+	if proxies[0].addr != "1.1.1.1:53" {
+		t.Errorf("Expected the proxy list not to be modified")
+	}
+}
+
+func TestFastestUpdateRtt(t *testing.T) {
+	p := &Proxy{addr: "1.1.1.1:53"}
+	for i := 0; i < 20; i++ {
+		p.updateRtt(100 * time.Millisecond)
+	}
+	if rtt := p.rtt(); rtt < 90*time.Millisecond || rtt > 100*time.Millisecond {
+		t.Errorf("Expected smoothed rtt to converge to 100ms, got %s", rtt)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	proxies := []*Proxy{{addr: "1.1.1.1:53"}, {addr: "2.2.2.2:53"}, {addr: "3.3.3.3:53"}}
+	r := &consistentHash{}
+
+	first := map[string]int{}
+	for i := 0; i < 300; i++ {
+		name := fmt.Sprintf("name%d.example.org.", i)
+		got := r.ListName(proxies, name)
+		if len(got) != len(proxies) {
+			t.Fatalf("Expected %d proxies, got %d", len(proxies), len(got))
+		}
+		again := r.ListName(proxies, name)
+		for j := range got {
+			if got[j] != again[j] {
+				t.Fatalf("Expected the same order for %s, got %s and %s at %d", name, got[j].addr, again[j].addr, j)
+			}
+		}
+		first[got[0].addr]++
+
+		// Removing the first host must not change the order of the others.
+		var rest []*Proxy
+		for _, p := range proxies {
+			if p != got[0] {
+				rest = append(rest, p)
+			}
+		}
+		without := r.ListName(rest, name)
+		if without[0] != got[1] {
+			t.Errorf("Expected %s to be first for %s without %s, got %s", got[1].addr, name, got[0].addr, without[0].addr)
+		}
+	}
+
+	for _, p := range proxies {
+		if first[p.addr] < 50 {
+			t.Errorf("Expected names to be spread over all proxies, %s is first for %d out of 300", p.addr, first[p.addr])
+		}
+	}
+}
@@ -6,15 +6,19 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/plugin/pkg/up"
 )
 
 // Proxy defines an upstream host.
 type Proxy struct {
-	fails uint32
-	addr  string
+	avgRtt int64 // smoothed round trip time, see updateRtt
+	fails  uint32
+	addr   string
 
 	transport *Transport
+	doh       *dohTransport // only set for DNS-over-HTTPS upstreams
+	doq       *doqTransport // only set for DNS-over-QUIC upstreams
 
 	// health checking
 	probe  *up.Probe
@@ -24,10 +28,17 @@ type Proxy struct {
 // NewProxy returns a new proxy.
 func NewProxy(addr, trans string) *Proxy {
 	p := &Proxy{
-		addr:      addr,
-		fails:     0,
-		probe:     up.New(),
-		transport: newTransport(addr),
+		addr:  addr,
+		fails: 0,
+		probe: up.New(),
+	}
+	switch trans {
+	case transport.HTTPS:
+		p.doh = newDoHTransport(addr)
+	case transport.QUIC:
+		p.doq = newDoQTransport(addr)
+	default:
+		p.transport = newTransport(addr)
 	}
 	p.health = NewHealthChecker(trans, true, ".")
 	runtime.SetFinalizer(p, (*Proxy).finalizer)
@@ -36,12 +47,28 @@ func NewProxy(addr, trans string) *Proxy {
 
 // SetTLSConfig sets the TLS config in the lower p.transport and in the healthchecking client.
 func (p *Proxy) SetTLSConfig(cfg *tls.Config) {
-	p.transport.SetTLSConfig(cfg)
+	switch {
+	case p.doh != nil:
+		p.doh.SetTLSConfig(cfg)
+	case p.doq != nil:
+		p.doq.SetTLSConfig(cfg)
+	default:
+		p.transport.SetTLSConfig(cfg)
+	}
 	p.health.SetTLSConfig(cfg)
 }
 
 // SetExpire sets the expire duration in the lower p.transport.
-func (p *Proxy) SetExpire(expire time.Duration) { p.transport.SetExpire(expire) }
+func (p *Proxy) SetExpire(expire time.Duration) {
+	switch {
+	case p.doh != nil:
+		p.doh.SetExpire(expire)
+	case p.doq != nil:
+		p.doq.SetExpire(expire)
+	default:
+		p.transport.SetExpire(expire)
+	}
+}
 
 // Healthcheck kicks of a round of health checks for this proxy.
 func (p *Proxy) Healthcheck() {
@@ -65,14 +92,32 @@ func (p *Proxy) Down(maxfails uint32) bool {
 	return fails > maxfails
 }
 
+// updateRtt feeds the observed round trip time d into the smoothed round trip time of this proxy.
+func (p *Proxy) updateRtt(d time.Duration) { averageTimeout(&p.avgRtt, d, cumulativeAvgWeight) }
+
+// rtt returns the smoothed round trip time of this proxy.
+func (p *Proxy) rtt() time.Duration { return time.Duration(atomic.LoadInt64(&p.avgRtt)) }
+
 // close stops the health checking goroutine.
-func (p *Proxy) stop()      { p.probe.Stop() }
-func (p *Proxy) finalizer() { p.transport.Stop() }
+func (p *Proxy) stop() { p.probe.Stop() }
+
+func (p *Proxy) finalizer() {
+	switch {
+	case p.doh != nil:
+		p.doh.Stop()
+	case p.doq != nil:
+		p.doq.Stop()
+	default:
+		p.transport.Stop()
+	}
+}
 
 // start starts the proxy's healthchecking.
 func (p *Proxy) start(duration time.Duration) {
 	p.probe.Start(duration)
-	p.transport.Start()
+	if p.transport != nil {
+		p.transport.Start()
+	}
 }
 
 const (
@@ -0,0 +1,173 @@
+package forward
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/coredns/coredns/plugin/debug"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
+	otext "github.com/opentracing/opentracing-go/ext"
+)
+
+// raceResult is the outcome of a single exchange with an upstream in race mode.
+type raceResult struct {
+	proxy *Proxy
+	ret   *dns.Msg
+	err   error
+}
+
+// serveRace sends the query to f.race upstreams at once and writes the first usable reply. The
+// exchanges with the other upstreams are cancelled when a usable reply is received. A SERVFAIL or
+// REFUSED reply is only written when no better reply arrives before the deadline.
+func (f *Forward) serveRace(ctx context.Context, state request.Request) (int, error) {
+	proxies := f.raceList(state)
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	start := time.Now()
+	// Buffered so the exchanges that lost the race never block.
+	results := make(chan raceResult, len(proxies))
+	for _, proxy := range proxies {
+		go func(proxy *Proxy) {
+			ret, err := f.raceConnect(ctx, proxy, state, start)
+			results <- raceResult{proxy: proxy, ret: ret, err: err}
+		}(proxy)
+	}
+
+	var (
+		fallback    *raceResult
+		upstreamErr error
+	)
+	for range proxies {
+		res := <-results
+		if res.err != nil {
+			upstreamErr = res.err
+			continue
+		}
+
+		// Check if the reply is correct; if not treat it like an error.
+		if !state.Match(res.ret) {
+			debug.Hexdumpf(res.ret, "Wrong reply for id: %d, %s %d", res.ret.Id, state.QName(), state.QType())
+			upstreamErr = errors.New("wrong reply from " + res.proxy.addr)
+			continue
+		}
+
+		if res.ret.Rcode == dns.RcodeServerFailure || res.ret.Rcode == dns.RcodeRefused {
+			if fallback == nil {
+				fallback = &res
+			}
+			continue
+		}
+
+		cancel() // the exchanges that are still in flight lost the race
+		f.raceWrite(ctx, state, res)
+		return 0, nil
+	}
+
+	if fallback != nil {
+		f.raceWrite(ctx, state, *fallback)
+		return 0, nil
+	}
+
+	if upstreamErr != nil {
+		return dns.RcodeServerFailure, upstreamErr
+	}
+	return dns.RcodeServerFailure, ErrNoHealthy
+}
+
+// raceList returns the upstreams to use in race mode: the first f.race healthy ones from the policy's
+// list. When all upstreams are down, a random upstream is used, as in the serial mode.
+func (f *Forward) raceList(state request.Request) []*Proxy {
+	list := f.list(state)
+	proxies := make([]*Proxy, 0, f.race)
+	for _, proxy := range list {
+		if proxy.Down(f.maxfails) {
+			continue
+		}
+		proxies = append(proxies, proxy)
+		if len(proxies) == f.race {
+			break
+		}
+	}
+	if len(proxies) == 0 {
+		// All upstream proxies are dead, assume healthcheck is completely broken and randomly
+		// select an upstream to connect to.
+		r := new(random)
+		proxies = append(proxies, r.List(f.proxies)[0])
+
+		HealthcheckBrokenCount.Add(1)
+	}
+	return proxies
+}
+
+// raceConnect performs the exchange with a single upstream. It works on a copy of the request,
+// because proxy.Connect sets the message ID of the request it sends.
+func (f *Forward) raceConnect(ctx context.Context, proxy *Proxy, state request.Request, start time.Time) (*dns.Msg, error) {
+	state = request.Request{W: state.W, Req: state.Req.Copy()}
+
+	var child ot.Span
+	if span := ot.SpanFromContext(ctx); span != nil {
+		child = span.Tracer().StartSpan("connect", ot.ChildOf(span.Context()))
+		otext.PeerAddress.Set(child, proxy.addr)
+		ctx = ot.ContextWithSpan(ctx, child)
+	}
+
+	var (
+		ret *dns.Msg
+		err error
+	)
+	opts := f.opts
+	for {
+		ret, err = proxy.Connect(ctx, state, opts)
+		if err == ErrCachedClosed { // Remote side closed conn, can only happen with TCP.
+			continue
+		}
+		// Retry with TCP if truncated and prefer_udp configured.
+		if ret != nil && ret.Truncated && !opts.forceTCP && opts.preferUDP {
+			opts.forceTCP = true
+			continue
+		}
+		break
+	}
+
+	if child != nil {
+		child.Finish()
+	}
+
+	if err != nil && ctx.Err() != nil {
+		// We lost the race (or ran out of time); this says nothing about the upstream's health.
+		if errors.Is(ctx.Err(), context.Canceled) {
+			RaceCancelCount.WithLabelValues(proxy.addr).Add(1)
+		}
+		return nil, ctx.Err()
+	}
+
+	if len(f.tapPlugins) != 0 {
+		toDnstap(f, proxy.addr, state, opts, ret, start)
+	}
+
+	if err != nil {
+		// Count a failed exchange as a slow one, so the fastest policy moves away from this upstream.
+		proxy.updateRtt(readTimeout)
+
+		// Kick off health check to see if *our* upstream is broken.
+		if f.maxfails != 0 {
+			proxy.Healthcheck()
+		}
+	}
+	return ret, err
+}
+
+// raceWrite writes the reply in res to the client.
+func (f *Forward) raceWrite(ctx context.Context, state request.Request, res raceResult) {
+	metadata.SetValueFunc(ctx, "forward/upstream", func() string {
+		return res.proxy.addr
+	})
+	state.W.WriteMsg(res.ret)
+}
@@ -0,0 +1,159 @@
+package forward
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// raceHandlers holds the handlers of the servers started with newRaceServer, by port.
+var raceHandlers = struct {
+	sync.Mutex
+	m map[string]dns.HandlerFunc
+}{m: map[string]dns.HandlerFunc{}}
+
+// newRaceServer starts a server that answers with h. dnstest.NewServer registers its handler for all
+// servers, so every server gets a handler that looks up h by the port the query was sent to.
+func newRaceServer(h dns.HandlerFunc) *dnstest.Server {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		_, port, _ := net.SplitHostPort(w.LocalAddr().String())
+		raceHandlers.Lock()
+		h := raceHandlers.m[port]
+		raceHandlers.Unlock()
+		if h != nil {
+			h(w, r)
+		}
+	})
+	_, port, _ := net.SplitHostPort(s.Addr)
+	raceHandlers.Lock()
+	raceHandlers.m[port] = h
+	raceHandlers.Unlock()
+	return s
+}
+
+func TestRaceBlackhole(t *testing.T) {
+	readTimeout = 1 * time.Second
+	defaultTimeout = 5 * time.Second
+	blackhole := newRaceServer(func(w dns.ResponseWriter, r *dns.Msg) {})
+	defer blackhole.Close()
+	s := newRaceServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	c := caddy.NewTestController("dns", "forward . "+blackhole.Addr+" "+s.Addr+" {\npolicy sequential\nrace 2\n}\n")
+	fs, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f := fs[0]
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+
+	start := time.Now()
+	if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected to receive reply, but got: %s", err)
+	}
+	if d := time.Since(start); d >= readTimeout {
+		t.Errorf("Expected reply before the read timeout, took %s", d)
+	}
+	if x := rec.Msg.Answer[0].Header().Name; x != "example.org." {
+		t.Errorf("Expected %s, got %s", "example.org.", x)
+	}
+	if rec.Msg.Id != m.Id {
+		t.Errorf("Expected message ID %d, got %d", m.Id, rec.Msg.Id)
+	}
+
+	// The exchange with the blackhole lost the race and must be canceled; wait for it, so it's done before
+	// the next test changes the timeouts.
+	cancelled := RaceCancelCount.WithLabelValues(blackhole.Addr)
+	for deadline := time.Now().Add(time.Second); testutil.ToFloat64(cancelled) < 1; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the exchange with the blackhole to be canceled")
+		}
+	}
+}
+
+func TestRaceServfailFallback(t *testing.T) {
+	readTimeout = 1 * time.Second
+	defaultTimeout = 5 * time.Second
+	servfail := newRaceServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(ret)
+	})
+	defer servfail.Close()
+	slow := newRaceServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		time.Sleep(100 * time.Millisecond)
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer slow.Close()
+
+	c := caddy.NewTestController("dns", "forward . "+servfail.Addr+" "+slow.Addr+" {\nrace 2\n}\n")
+	fs, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f := fs[0]
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+
+	if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected to receive reply, but got: %s", err)
+	}
+	if rec.Msg.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected rcode %s, got %s", dns.RcodeToString[dns.RcodeSuccess], dns.RcodeToString[rec.Msg.Rcode])
+	}
+}
+
+func TestRaceAllServfail(t *testing.T) {
+	servfail := newRaceServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(ret)
+	})
+	defer servfail.Close()
+
+	c := caddy.NewTestController("dns", "forward . "+servfail.Addr+" "+servfail.Addr+" {\nrace 2\n}\n")
+	fs, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f := fs[0]
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+
+	if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected to receive reply, but got: %s", err)
+	}
+	if rec.Msg.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected rcode %s, got %s", dns.RcodeToString[dns.RcodeServerFailure], dns.RcodeToString[rec.Msg.Rcode])
+	}
+}
@@ -114,19 +114,38 @@ func parseStanza(c *caddy.Controller) (*Forward, error) {
 		return f, c.ArgErr()
 	}
 
-	toHosts, err := parse.HostPortOrFile(to...)
-	if err != nil {
-		return f, err
+	toHosts := []string{}
+	for _, h := range to {
+		// DoH upstreams are URLs and may use a host name instead of an IP address.
+		if trans, _ := parse.Transport(h); trans == transport.HTTPS {
+			u, err := parseDoHURL(h)
+			if err != nil {
+				return f, err
+			}
+			toHosts = append(toHosts, u)
+			continue
+		}
+		hosts, err := parse.HostPortOrFile(h)
+		if err != nil && err != parse.ErrNoNameservers {
+			return f, err
+		}
+		toHosts = append(toHosts, hosts...)
+	}
+	if len(toHosts) == 0 {
+		return f, parse.ErrNoNameservers
 	}
 
 	transports := make([]string, len(toHosts))
-	allowedTrans := map[string]bool{"dns": true, "tls": true}
+	allowedTrans := map[string]bool{"dns": true, "tls": true, "https": true, "quic": true}
 	for i, host := range toHosts {
 		trans, h := parse.Transport(host)
 
 		if !allowedTrans[trans] {
 			return f, fmt.Errorf("'%s' is not supported as a destination protocol in forward: %s", trans, host)
 		}
+		if trans == transport.HTTPS {
+			h = host // the full URL is needed to reach the upstream
+		}
 		p := NewProxy(h, trans)
 		f.proxies = append(f.proxies, p)
 		transports[i] = trans
@@ -148,13 +167,13 @@ func parseStanza(c *caddy.Controller) (*Forward, error) {
 
 	for i := range f.proxies {
 		// Only set this for proxies that need it.
-		if transports[i] == transport.TLS {
+		if transports[i] == transport.TLS || transports[i] == transport.HTTPS || transports[i] == transport.QUIC {
 			f.proxies[i].SetTLSConfig(f.tlsConfig)
 		}
 		f.proxies[i].SetExpire(f.expire)
 		f.proxies[i].health.SetRecursionDesired(f.opts.hcRecursionDesired)
-		// when TLS is used, checks are set to tcp-tls
-		if f.opts.forceTCP && transports[i] != transport.TLS {
+		// when TLS is used, checks are set to tcp-tls; DoH and DoQ health checks use their own transport
+		if f.opts.forceTCP && transports[i] == transport.DNS {
 			f.proxies[i].health.SetTCPTransport()
 		}
 		f.proxies[i].health.SetDomain(f.opts.hcDomain)
@@ -263,6 +282,10 @@ func parseBlock(c *caddy.Controller, f *Forward) error {
 			f.p = &roundRobin{}
 		case "sequential":
 			f.p = &sequential{}
+		case "fastest":
+			f.p = &fastest{}
+		case "consistent_hash":
+			f.p = &consistentHash{}
 		default:
 			return c.Errf("unknown policy '%s'", x)
 		}
@@ -279,6 +302,18 @@ func parseBlock(c *caddy.Controller, f *Forward) error {
 		}
 		f.ErrLimitExceeded = errors.New("concurrent queries exceeded maximum " + c.Val())
 		f.maxConcurrent = int64(n)
+	case "race":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		n, err := strconv.Atoi(c.Val())
+		if err != nil {
+			return err
+		}
+		if n < 2 {
+			return fmt.Errorf("race needs at least 2 upstreams: %d", n)
+		}
+		f.race = n
 
 	default:
 		return c.Errf("unknown property '%s'", c.Val())
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
@@ -12,6 +13,15 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// overloadedThreshold is the duration of the local health request above which the process is overloaded.
+const overloadedThreshold = 1 * time.Second
+
+var isOverloaded atomic.Bool
+
+// Overloaded returns true if the last self health check failed or took longer than a second. Plugins can use
+// this to shed load. It is always false when the health plugin isn't enabled.
+func Overloaded() bool { return isOverloaded.Load() }
+
 // overloaded queries the health end point and updates a metrics showing how long it took.
 func (h *health) overloaded(ctx context.Context) {
 	bypassProxy := &http.Transport{
@@ -44,22 +54,26 @@ func (h *health) overloaded(ctx context.Context) {
 			resp, err := client.Do(req)
 			if err != nil && ctx.Err() == context.Canceled {
 				// request was cancelled by parent goroutine
+				isOverloaded.Store(false)
 				return
 			}
 			if err != nil {
 				HealthDuration.Observe(time.Since(start).Seconds())
 				HealthFailures.Inc()
+				isOverloaded.Store(true)
 				log.Warningf("Local health request to %q failed: %s", url, err)
 				continue
 			}
 			resp.Body.Close()
 			elapsed := time.Since(start)
 			HealthDuration.Observe(elapsed.Seconds())
-			if elapsed > time.Second { // 1s is pretty random, but a *local* scrape taking that long isn't good
+			isOverloaded.Store(elapsed > overloadedThreshold)
+			if elapsed > overloadedThreshold { // 1s is pretty random, but a *local* scrape taking that long isn't good
 				log.Warningf("Local health request to %q took more than 1s: %s", url, elapsed)
 			}
 
 		case <-ctx.Done():
+			isOverloaded.Store(false)
 			return
 		}
 	}
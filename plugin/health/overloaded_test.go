@@ -39,3 +39,32 @@ func Test_health_overloaded_cancellation(t *testing.T) {
 		t.Fatal("overloaded function should have been cancelled")
 	}
 }
+
+func TestOverloaded(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	addr := ts.Listener.Addr().String()
+	ts.Close() // every health request fails
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &health{Addr: addr, stop: cancel}
+
+	stopped := make(chan struct{})
+	go func() {
+		h.overloaded(ctx)
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !Overloaded() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected to be overloaded after a failed health request")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	cancel()
+	<-stopped
+	if Overloaded() {
+		t.Error("Expected not to be overloaded after the health check stopped")
+	}
+}
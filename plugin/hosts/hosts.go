@@ -7,6 +7,7 @@ import (
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/plugin/pkg/fall"
+	"github.com/coredns/coredns/plugin/pkg/views"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -18,6 +19,15 @@ type Hosts struct {
 	*Hostsfile
 
 	Fall fall.F
+
+	// hosts files used instead of Hostsfile for the queries in a view, first match wins.
+	views []hostsView
+}
+
+// hostsView is the hosts file of the queries in a view.
+type hostsView struct {
+	*views.View
+	*Hostsfile
 }
 
 // ServeDNS implements the plugin.Handle interface.
@@ -36,24 +46,26 @@ func (h Hosts) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 		}
 	}
 
+	hf := h.hostsfile(ctx, state)
+
 	switch state.QType() {
 	case dns.TypePTR:
-		names := h.LookupStaticAddr(dnsutil.ExtractAddressFromReverse(qname))
+		names := hf.LookupStaticAddr(dnsutil.ExtractAddressFromReverse(qname))
 		if len(names) == 0 {
 			// If this doesn't match we need to fall through regardless of h.Fallthrough
 			return plugin.NextOrFailure(h.Name(), h.Next, ctx, w, r)
 		}
 		answers = h.ptr(qname, h.options.ttl, names)
 	case dns.TypeA:
-		ips := h.LookupStaticHostV4(qname)
+		ips := hf.LookupStaticHostV4(qname)
 		answers = a(qname, h.options.ttl, ips)
 	case dns.TypeAAAA:
-		ips := h.LookupStaticHostV6(qname)
+		ips := hf.LookupStaticHostV6(qname)
 		answers = aaaa(qname, h.options.ttl, ips)
 	}
 
 	// Only on NXDOMAIN we will fallthrough.
-	if len(answers) == 0 && !h.otherRecordsExist(qname) {
+	if len(answers) == 0 && !hf.otherRecordsExist(qname) {
 		if h.Fall.Through(qname) {
 			return plugin.NextOrFailure(h.Name(), h.Next, ctx, w, r)
 		}
@@ -72,7 +84,7 @@ func (h Hosts) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 	return dns.RcodeSuccess, nil
 }
 
-func (h Hosts) otherRecordsExist(qname string) bool {
+func (h *Hostsfile) otherRecordsExist(qname string) bool {
 	if len(h.LookupStaticHostV4(qname)) > 0 {
 		return true
 	}
@@ -85,6 +97,26 @@ func (h Hosts) otherRecordsExist(qname string) bool {
 // Name implements the plugin.Handle interface.
 func (h Hosts) Name() string { return "hosts" }
 
+// hostsfile returns the hosts file of the first view that state is in, or the default hosts file.
+func (h Hosts) hostsfile(ctx context.Context, state request.Request) *Hostsfile {
+	for _, v := range h.views {
+		if v.Match(ctx, state) {
+			return v.Hostsfile
+		}
+	}
+	return h.Hostsfile
+}
+
+// SelectView implements the views.Selector interface.
+func (h Hosts) SelectView(ctx context.Context, state request.Request) string {
+	for _, v := range h.views {
+		if v.Match(ctx, state) {
+			return v.Name
+		}
+	}
+	return ""
+}
+
 // a takes a slice of net.IPs and returns a slice of A RRs.
 func a(zone string, ttl uint32, ips []net.IP) []dns.RR {
 	answers := make([]dns.RR, len(ips))
@@ -105,6 +105,9 @@ type Hostsfile struct {
 	size  int64
 
 	options *options
+
+	// view is the name of the view these hosts are for, empty for the default hosts file.
+	view string
 }
 
 // readHosts determines if the cached data needs to be updated based on the size and modification time of the hostsfile.
@@ -138,8 +141,10 @@ func (h *Hostsfile) readHosts() {
 	h.mtime = stat.ModTime()
 	h.size = stat.Size()
 
-	hostsEntries.WithLabelValues().Set(float64(h.inline.Len() + h.hmap.Len()))
-	hostsReloadTime.Set(float64(stat.ModTime().UnixNano()) / 1e9)
+	if h.view == "" {
+		hostsEntries.WithLabelValues().Set(float64(h.inline.Len() + h.hmap.Len()))
+		hostsReloadTime.Set(float64(stat.ModTime().UnixNano()) / 1e9)
+	}
 	h.Unlock()
 }
 
@@ -11,6 +11,7 @@ import (
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/views"
 )
 
 var log = clog.NewWithPlugin("hosts")
@@ -33,6 +34,9 @@ func periodicHostsUpdate(h *Hosts) chan bool {
 				return
 			case <-ticker.C:
 				h.readHosts()
+				for _, v := range h.views {
+					v.readHosts()
+				}
 			}
 		}
 	}()
@@ -49,6 +53,9 @@ func setup(c *caddy.Controller) error {
 
 	c.OnStartup(func() error {
 		h.readHosts()
+		for _, v := range h.views {
+			v.readHosts()
+		}
 		return nil
 	})
 
@@ -141,6 +148,29 @@ func hostsParse(c *caddy.Controller) (Hosts, error) {
 					return h, c.Errf("invalid negative duration for reload '%s'", remaining[0])
 				}
 				h.options.reload = reload
+			case "view":
+				remaining := c.RemainingArgs()
+				if len(remaining) < 2 {
+					return h, c.ArgErr()
+				}
+				v, err := views.New(remaining[0], remaining[2:])
+				if err != nil {
+					return h, c.Err(err.Error())
+				}
+				path := remaining[1]
+				if !filepath.IsAbs(path) && config.Root != "" {
+					path = filepath.Join(config.Root, path)
+				}
+				if _, err := os.Stat(path); os.IsNotExist(err) {
+					log.Warningf("File does not exist: %s", path)
+				}
+				h.views = append(h.views, hostsView{View: v, Hostsfile: &Hostsfile{
+					path:    path,
+					hmap:    newMap(),
+					inline:  newMap(),
+					options: h.options,
+					view:    v.Name,
+				}})
 			default:
 				if len(h.Fall.Zones) == 0 {
 					line := strings.Join(append([]string{c.Val()}, c.RemainingArgs()...), " ")
@@ -153,6 +183,10 @@ func hostsParse(c *caddy.Controller) (Hosts, error) {
 	}
 
 	h.initInline(inline)
+	for _, v := range h.views {
+		v.Origins = h.Origins
+		v.inline = h.inline // inline entries are in every view
+	}
 
 	return h, nil
 }
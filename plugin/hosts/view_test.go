@@ -0,0 +1,66 @@
+package hosts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestHostsViews(t *testing.T) {
+	external, rm, err := test.TempFile(".", "192.0.2.1 www.example.org\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+	internal, rm, err := test.TempFile(".", "10.0.0.1 www.example.org\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	h, err := hostsParse(caddy.NewTestController("dns", `hosts `+external+` example.org {
+		view internal `+internal+` net 10.0.0.0/8
+		192.0.2.53 ns.example.org
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Next = test.NextHandler(dns.RcodeNameError, nil)
+	h.readHosts()
+	for _, v := range h.views {
+		v.readHosts()
+	}
+
+	tests := []struct {
+		remoteIP string
+		qname    string
+		expected string
+	}{
+		{"10.1.2.3", "www.example.org.", "10.0.0.1"},
+		{"192.168.1.1", "www.example.org.", "192.0.2.1"},
+		{"10.1.2.3", "ns.example.org.", "192.0.2.53"},
+		{"192.168.1.1", "ns.example.org.", "192.0.2.53"},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.remoteIP})
+		if _, err := h.ServeDNS(context.Background(), rec, m); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if a := rec.Msg.Answer[0].(*dns.A).A.String(); a != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, a)
+		}
+	}
+
+	if _, err := hostsParse(caddy.NewTestController("dns", `hosts {
+		view internal /etc/hosts net 10.0.0.0/33
+	}`)); err == nil {
+		t.Errorf("Expected error for invalid view network")
+	}
+}
@@ -0,0 +1,265 @@
+package loadbalance
+
+import (
+	"context"
+	"math"
+	"net/netip"
+	"sort"
+	"strconv"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+type (
+	// "geo" policy specific data
+	geoPolicy struct {
+		top        int // number of addresses to return, 0 returns all
+		ednsSubnet bool
+		regions    map[string]location
+		addresses  []prefixLocation // where the addresses in answers are, most specific first
+		clients    []prefixLocation // where the clients are, most specific first
+	}
+	// A point on earth, in degrees
+	location struct {
+		lat, lon float64
+	}
+	// The location of the addresses in a prefix
+	prefixLocation struct {
+		prefix netip.Prefix
+		location
+	}
+)
+
+// geoShuffle sorts the A and AAAA records in the answer of res by the distance of their address to the client,
+// and keeps the top ones. Records at the same distance are shuffled. If the client's location is unknown, the
+// records are only shuffled.
+func geoShuffle(ctx context.Context, state request.Request, res *dns.Msg, g *geoPolicy) *dns.Msg {
+	switch res.Question[0].Qtype {
+	case dns.TypeA, dns.TypeAAAA:
+	default:
+		return randomShuffle(res)
+	}
+
+	client, ok := g.clientLocation(ctx, state)
+	if !ok {
+		return randomShuffle(res)
+	}
+
+	cname := []dns.RR{}
+	address := []dns.RR{}
+	rest := []dns.RR{}
+	for _, r := range res.Answer {
+		switch r.Header().Rrtype {
+		case dns.TypeCNAME:
+			cname = append(cname, r)
+		case dns.TypeA, dns.TypeAAAA:
+			address = append(address, r)
+		default:
+			rest = append(rest, r)
+		}
+	}
+
+	roundRobinShuffle(address)
+	distances := make(map[dns.RR]float64, len(address))
+	for _, r := range address {
+		distances[r] = math.Inf(1)
+		if loc, ok := lookup(g.addresses, rrAddr(r)); ok {
+			distances[r] = distance(client, loc)
+		}
+	}
+	sort.SliceStable(address, func(i, j int) bool { return distances[address[i]] < distances[address[j]] })
+	if g.top > 0 && len(address) > g.top {
+		address = address[:g.top]
+	}
+
+	out := append(cname, rest...)
+	res.Answer = append(out, address...)
+	return res
+}
+
+// clientLocation returns the location of the client: from the static client map, or else from the geoip
+// metadata.
+func (g *geoPolicy) clientLocation(ctx context.Context, state request.Request) (location, bool) {
+	if len(g.clients) > 0 {
+		if loc, ok := lookup(g.clients, g.clientAddr(state)); ok {
+			return loc, true
+		}
+	}
+
+	lat, lon := metadata.ValueFunc(ctx, "geoip/latitude"), metadata.ValueFunc(ctx, "geoip/longitude")
+	if lat == nil || lon == nil {
+		return location{}, false
+	}
+	la, err := strconv.ParseFloat(lat(), 64)
+	if err != nil {
+		return location{}, false
+	}
+	lo, err := strconv.ParseFloat(lon(), 64)
+	if err != nil {
+		return location{}, false
+	}
+	return location{la, lo}, true
+}
+
+// clientAddr returns the address of the client, from the EDNS0 subnet option if enabled and present.
+func (g *geoPolicy) clientAddr(state request.Request) netip.Addr {
+	if g.ednsSubnet {
+		if opt := state.Req.IsEdns0(); opt != nil {
+			for _, o := range opt.Option {
+				if e, ok := o.(*dns.EDNS0_SUBNET); ok {
+					if addr, ok := netip.AddrFromSlice(e.Address); ok {
+						return addr.Unmap()
+					}
+				}
+			}
+		}
+	}
+	addr, _ := netip.ParseAddr(state.IP())
+	return addr.Unmap().WithZone("")
+}
+
+// lookup returns the location of the most specific prefix that contains addr.
+func lookup(prefixes []prefixLocation, addr netip.Addr) (location, bool) {
+	for _, p := range prefixes {
+		if p.prefix.Contains(addr) {
+			return p.location, true
+		}
+	}
+	return location{}, false
+}
+
+func rrAddr(r dns.RR) netip.Addr {
+	var addr netip.Addr
+	switch r := r.(type) {
+	case *dns.A:
+		addr, _ = netip.AddrFromSlice(r.A)
+	case *dns.AAAA:
+		addr, _ = netip.AddrFromSlice(r.AAAA)
+	}
+	return addr.Unmap()
+}
+
+// earthRadius is the mean radius of the earth in kilometers.
+const earthRadius = 6371
+
+// distance returns the great-circle distance between a and b in kilometers.
+func distance(a, b location) float64 {
+	rad := math.Pi / 180
+	dLat := (b.lat - a.lat) * rad
+	dLon := (b.lon - a.lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(a.lat*rad)*math.Cos(b.lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func createGeoFuncs(g *geoPolicy) *lbFuncs {
+	return &lbFuncs{
+		contextShuffleFunc: func(ctx context.Context, state request.Request, res *dns.Msg) *dns.Msg {
+			return geoShuffle(ctx, state, res, g)
+		},
+		geo: g,
+	}
+}
+
+// parseGeo parses the block of the geo policy. The health check options are parsed into hc.
+func parseGeo(c *caddy.Controller, hc *healthCheck) (*geoPolicy, error) {
+	g := &geoPolicy{top: 1, regions: make(map[string]location)}
+	type mapping struct {
+		prefix netip.Prefix
+		region string
+		client bool
+	}
+	var mappings []mapping
+
+	for c.NextBlock() {
+		switch c.Val() {
+		case "top":
+			t := c.RemainingArgs()
+			if len(t) != 1 {
+				return nil, c.ArgErr()
+			}
+			n, err := strconv.Atoi(t[0])
+			if err != nil || n < 0 {
+				return nil, c.Errf("invalid top value '%s'", t[0])
+			}
+			g.top = n
+		case "region":
+			t := c.RemainingArgs()
+			if len(t) != 3 {
+				return nil, c.ArgErr()
+			}
+			lat, err := strconv.ParseFloat(t[1], 64)
+			if err != nil || lat < -90 || lat > 90 {
+				return nil, c.Errf("invalid latitude '%s'", t[1])
+			}
+			lon, err := strconv.ParseFloat(t[2], 64)
+			if err != nil || lon < -180 || lon > 180 {
+				return nil, c.Errf("invalid longitude '%s'", t[2])
+			}
+			if _, ok := g.regions[t[0]]; ok {
+				return nil, c.Errf("duplicate region '%s'", t[0])
+			}
+			g.regions[t[0]] = location{lat, lon}
+		case "address", "client":
+			client := c.Val() == "client"
+			t := c.RemainingArgs()
+			if len(t) < 2 {
+				return nil, c.ArgErr()
+			}
+			for _, s := range t[1:] {
+				p, err := parseGeoPrefix(s)
+				if err != nil {
+					return nil, c.Errf("illegal CIDR notation '%s'", s)
+				}
+				mappings = append(mappings, mapping{prefix: p, region: t[0], client: client})
+			}
+		case "edns-subnet":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			g.ednsSubnet = true
+		default:
+			if ok, err := hc.parseOption(c); !ok {
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, m := range mappings {
+		loc, ok := g.regions[m.region]
+		if !ok {
+			return nil, c.Errf("unknown region '%s'", m.region)
+		}
+		if m.client {
+			g.clients = append(g.clients, prefixLocation{m.prefix, loc})
+		} else {
+			g.addresses = append(g.addresses, prefixLocation{m.prefix, loc})
+		}
+	}
+	if len(g.addresses) == 0 {
+		return nil, c.Err("no address locations defined")
+	}
+	mostSpecificFirst := func(p []prefixLocation) func(i, j int) bool {
+		return func(i, j int) bool { return p[i].prefix.Bits() > p[j].prefix.Bits() }
+	}
+	sort.SliceStable(g.addresses, mostSpecificFirst(g.addresses))
+	sort.SliceStable(g.clients, mostSpecificFirst(g.clients))
+	return g, nil
+}
+
+// parseGeoPrefix parses a CIDR or a single address.
+func parseGeoPrefix(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return p.Masked(), nil
+}
@@ -0,0 +1,149 @@
+package loadbalance
+
+import (
+	"context"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+const geoConfig = `loadbalance geo {
+	region ams 52.37 4.89
+	region nyc 40.71 -74.00
+	region sin 1.35 103.82
+	address ams 192.0.2.1 2001:db8:1::/48
+	address nyc 192.0.2.2
+	address sin 192.0.2.3
+	client nyc 10.0.0.0/8
+	client sin 10.1.0.0/16
+	top 2
+	edns-subnet
+}`
+
+func geoHandler() plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{
+			test.CNAME("www.example.org. 300 IN CNAME geo.example.org."),
+			test.A("geo.example.org. 300 IN A 192.0.2.1"),
+			test.A("geo.example.org. 300 IN A 192.0.2.2"),
+			test.A("geo.example.org. 300 IN A 192.0.2.3"),
+			test.A("geo.example.org. 300 IN A 192.0.2.4"),
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestGeoShuffle(t *testing.T) {
+	lb, err := parse(caddy.NewTestController("dns", geoConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm := LoadBalance{Next: geoHandler(), shuffle: lb.shuffleFunc, contextShuffle: lb.contextShuffleFunc}
+
+	geoip := func(lat, lon string) context.Context {
+		ctx := metadata.ContextWithMetadata(context.Background())
+		metadata.SetValueFunc(ctx, "geoip/latitude", func() string { return lat })
+		metadata.SetValueFunc(ctx, "geoip/longitude", func() string { return lon })
+		return ctx
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		remote   string
+		ecs      string
+		expected []string // the addresses in order, nil if only shuffled
+	}{
+		{"static client map", context.Background(), "10.2.3.4", "", []string{"192.0.2.2", "192.0.2.1"}},
+		{"most specific client prefix", context.Background(), "10.1.2.3", "", []string{"192.0.2.3", "192.0.2.1"}},
+		{"edns subnet", context.Background(), "192.168.0.1", "10.1.0.0", []string{"192.0.2.3", "192.0.2.1"}},
+		{"geoip metadata", geoip("48.85", "2.35"), "192.168.0.1", "", []string{"192.0.2.1", "192.0.2.2"}},
+		{"static map before geoip", geoip("48.85", "2.35"), "10.2.3.4", "", []string{"192.0.2.2", "192.0.2.1"}},
+		{"unknown location", context.Background(), "192.168.0.1", "", nil},
+		{"invalid geoip metadata", geoip("north", "2.35"), "192.168.0.1", "", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := new(dns.Msg)
+			req.SetQuestion("www.example.org.", dns.TypeA)
+			if tc.ecs != "" {
+				req.SetEdns0(4096, false)
+				req.IsEdns0().Option = append(req.IsEdns0().Option,
+					&dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 16, Address: net.ParseIP(tc.ecs)})
+			}
+			rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.remote})
+			if _, err := rm.ServeDNS(tc.ctx, rec, req); err != nil {
+				t.Fatalf("Expected no error, got %s", err)
+			}
+
+			if _, ok := rec.Msg.Answer[0].(*dns.CNAME); !ok {
+				t.Errorf("Expected the CNAME first, got %s", rec.Msg.Answer[0])
+			}
+			addrs := []string{}
+			for _, rr := range rec.Msg.Answer[1:] {
+				addrs = append(addrs, rr.(*dns.A).A.String())
+			}
+			if tc.expected == nil {
+				if len(addrs) != 4 {
+					t.Errorf("Expected all 4 addresses, got %v", addrs)
+				}
+				return
+			}
+			if len(addrs) != len(tc.expected) {
+				t.Fatalf("Expected addresses %v, got %v", tc.expected, addrs)
+			}
+			for i := range addrs {
+				if addrs[i] != tc.expected[i] {
+					t.Fatalf("Expected addresses %v, got %v", tc.expected, addrs)
+				}
+			}
+		})
+	}
+}
+
+func TestGeoUnknownAddressesLast(t *testing.T) {
+	lb, err := parse(caddy.NewTestController("dns", `loadbalance geo {
+		region ams 52.37 4.89
+		address ams 192.0.2.3
+		client ams 0.0.0.0/0
+		top 0
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm := LoadBalance{Next: geoHandler(), contextShuffle: lb.contextShuffleFunc}
+
+	req := new(dns.Msg)
+	req.SetQuestion("www.example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rm.ServeDNS(context.Background(), rec, req)
+
+	if len(rec.Msg.Answer) != 5 {
+		t.Fatalf("Expected all records with top 0, got %d", len(rec.Msg.Answer))
+	}
+	if a := rec.Msg.Answer[1].(*dns.A).A.String(); a != "192.0.2.3" {
+		t.Errorf("Expected the located address first, got %s", a)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	ams, nyc := location{52.37, 4.89}, location{40.71, -74.00}
+	if d := distance(ams, nyc); math.Abs(d-5860) > 20 {
+		t.Errorf("Expected distance of about 5860km, got %f", d)
+	}
+	if d := distance(ams, ams); d != 0 {
+		t.Errorf("Expected distance 0, got %f", d)
+	}
+}
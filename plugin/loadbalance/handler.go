@@ -5,19 +5,26 @@ import (
 	"context"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 )
 
 // RoundRobin is a plugin to rewrite responses for "load balancing".
 type LoadBalance struct {
-	Next    plugin.Handler
-	shuffle func(*dns.Msg) *dns.Msg
+	Next           plugin.Handler
+	shuffle        func(*dns.Msg) *dns.Msg
+	contextShuffle func(context.Context, request.Request, *dns.Msg) *dns.Msg
 }
 
 // ServeDNS implements the plugin.Handler interface.
 func (lb LoadBalance) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
-	rw := &LoadBalanceResponseWriter{ResponseWriter: w, shuffle: lb.shuffle}
+	shuffle := lb.shuffle
+	if lb.contextShuffle != nil {
+		state := request.Request{W: w, Req: r}
+		shuffle = func(res *dns.Msg) *dns.Msg { return lb.contextShuffle(ctx, state, res) }
+	}
+	rw := &LoadBalanceResponseWriter{ResponseWriter: w, shuffle: shuffle}
 	return plugin.NextOrFailure(lb.Name(), lb.Next, ctx, rw, r)
 }
 
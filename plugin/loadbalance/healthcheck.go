@@ -0,0 +1,405 @@
+package loadbalance
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+type (
+	// Health checks of the addresses in answers, shared by all queries
+	healthCheck struct {
+		protocol string // "tcp" or "http", empty if health checks are disabled
+		port     string
+		path     string
+		interval time.Duration
+		timeout  time.Duration
+		fails    int            // consecutive failures before an address is unhealthy
+		networks []netip.Prefix // only addresses in these networks are checked, all when empty
+
+		mutex   sync.RWMutex
+		targets map[netip.Addr]*target
+		sem     chan struct{} // limits the number of probes that run at the same time
+
+		probe func(ctx context.Context, addr netip.Addr) error
+		stop  chan struct{}
+	}
+	// Health check state of an address
+	target struct {
+		healthy  atomic.Bool
+		lastSeen atomic.Int64 // unix time in nanoseconds of the last answer with the address
+		probing  atomic.Bool
+		fails    int // only used by the probe of the target
+	}
+)
+
+const (
+	// maxTargets is the maximum number of addresses that are checked. Addresses beyond this are not checked
+	// and are considered healthy.
+	maxTargets = 10000
+	// targetExpire is how long an address is checked after it was last seen in an answer.
+	targetExpire = 1 * time.Hour
+	// maxConcurrentProbes limits the number of probes that run at the same time.
+	maxConcurrentProbes = 32
+)
+
+func newHealthCheck() *healthCheck {
+	return &healthCheck{
+		interval: 10 * time.Second,
+		timeout:  2 * time.Second,
+		fails:    1,
+		targets:  make(map[netip.Addr]*target),
+		sem:      make(chan struct{}, maxConcurrentProbes),
+	}
+}
+
+// enabled returns true if health checks are configured.
+func (hc *healthCheck) enabled() bool { return hc.protocol != "" }
+
+// parseOption parses the health check option c.Val(). It returns false if this is not a health check option.
+func (hc *healthCheck) parseOption(c *caddy.Controller) (bool, error) {
+	switch c.Val() {
+	case "health_check":
+		t := c.RemainingArgs()
+		if len(t) < 2 {
+			return true, c.ArgErr()
+		}
+		port, err := strconv.ParseUint(t[1], 10, 16)
+		if err != nil || port == 0 {
+			return true, c.Errf("invalid health check port '%s'", t[1])
+		}
+		hc.port = t[1]
+		switch t[0] {
+		case "tcp":
+			if len(t) != 2 {
+				return true, c.ArgErr()
+			}
+		case "http":
+			if len(t) > 3 {
+				return true, c.ArgErr()
+			}
+			hc.path = "/"
+			if len(t) == 3 {
+				if !strings.HasPrefix(t[2], "/") {
+					return true, c.Errf("invalid health check path '%s'", t[2])
+				}
+				hc.path = t[2]
+			}
+		default:
+			return true, c.Errf("unknown health check protocol '%s'", t[0])
+		}
+		hc.protocol = t[0]
+	case "health_check_interval", "health_check_timeout":
+		option := c.Val()
+		t := c.RemainingArgs()
+		if len(t) != 1 {
+			return true, c.ArgErr()
+		}
+		d, err := time.ParseDuration(t[0])
+		if err != nil || d <= 0 {
+			return true, c.Errf("invalid %s '%s'", option, t[0])
+		}
+		if option == "health_check_interval" {
+			hc.interval = d
+		} else {
+			hc.timeout = d
+		}
+	case "health_check_networks":
+		t := c.RemainingArgs()
+		if len(t) == 0 {
+			return true, c.ArgErr()
+		}
+		for _, n := range t {
+			prefix, err := netip.ParsePrefix(n)
+			if err != nil {
+				return true, c.Errf("invalid health_check_networks '%s'", n)
+			}
+			hc.networks = append(hc.networks, prefix.Masked())
+		}
+	case "health_check_fails":
+		t := c.RemainingArgs()
+		if len(t) != 1 {
+			return true, c.ArgErr()
+		}
+		n, err := strconv.Atoi(t[0])
+		if err != nil || n < 1 {
+			return true, c.Errf("invalid health_check_fails '%s'", t[0])
+		}
+		hc.fails = n
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// wrap returns lb with unhealthy addresses removed from the answers before they are shuffled, and the health
+// checks started and stopped with the server.
+func (hc *healthCheck) wrap(lb *lbFuncs) *lbFuncs {
+	if hc.probe == nil {
+		hc.probe = hc.defaultProbe()
+	}
+
+	if shuffle := lb.shuffleFunc; shuffle != nil {
+		lb.shuffleFunc = func(res *dns.Msg) *dns.Msg { return shuffle(hc.filter(res)) }
+	}
+	if shuffle := lb.contextShuffleFunc; shuffle != nil {
+		lb.contextShuffleFunc = func(ctx context.Context, state request.Request, res *dns.Msg) *dns.Msg {
+			return shuffle(ctx, state, hc.filter(res))
+		}
+	}
+
+	startUp, shutdown := lb.onStartUpFunc, lb.onShutdownFunc
+	lb.onStartUpFunc = func() error {
+		if startUp != nil {
+			if err := startUp(); err != nil {
+				return err
+			}
+		}
+		hc.start()
+		return nil
+	}
+	lb.onShutdownFunc = func() error {
+		hc.stopChecks()
+		if shutdown != nil {
+			return shutdown()
+		}
+		return nil
+	}
+	lb.health = hc
+	return lb
+}
+
+// filter removes the A and AAAA records with unhealthy addresses from the answer of res. If all of them are
+// unhealthy, res is returned unchanged.
+func (hc *healthCheck) filter(res *dns.Msg) *dns.Msg {
+	if res.Rcode != dns.RcodeSuccess {
+		return res
+	}
+
+	now := time.Now().UnixNano()
+	healthy, unhealthy := 0, 0
+	var keep []bool
+	for i, r := range res.Answer {
+		addr := rrAddr(r)
+		if !addr.IsValid() {
+			continue
+		}
+		t := hc.target(addr, now)
+		if t == nil || t.healthy.Load() {
+			healthy++
+			continue
+		}
+		if keep == nil {
+			keep = make([]bool, len(res.Answer))
+			for j := range keep {
+				keep[j] = true
+			}
+		}
+		keep[i] = false
+		unhealthy++
+	}
+
+	if unhealthy == 0 {
+		return res
+	}
+	if healthy == 0 {
+		HealthCheckFallbackCount.Inc()
+		return res
+	}
+
+	answer := make([]dns.RR, 0, healthy)
+	for i, r := range res.Answer {
+		if keep[i] {
+			answer = append(answer, r)
+		}
+	}
+	res.Answer = answer
+	return res
+}
+
+// checked returns true if addr is in one of the networks that are checked.
+func (hc *healthCheck) checked(addr netip.Addr) bool {
+	if len(hc.networks) == 0 {
+		return true
+	}
+	for _, n := range hc.networks {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// target returns the target of addr, and adds it if it is new. It returns nil if addr is not checked or if
+// there are too many targets.
+func (hc *healthCheck) target(addr netip.Addr, now int64) *target {
+	if !hc.checked(addr) {
+		return nil
+	}
+
+	hc.mutex.RLock()
+	t, ok := hc.targets[addr]
+	hc.mutex.RUnlock()
+	if ok {
+		t.lastSeen.Store(now)
+		return t
+	}
+
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+	if t, ok := hc.targets[addr]; ok {
+		return t
+	}
+	if len(hc.targets) >= maxTargets {
+		return nil
+	}
+	t = &target{}
+	t.healthy.Store(true) // until proven otherwise
+	t.lastSeen.Store(now)
+	hc.targets[addr] = t
+	HealthCheckStatus.WithLabelValues(addr.String()).Set(1)
+
+	// Check new addresses right away, instead of waiting for the next interval.
+	t.probing.Store(true)
+	go func() {
+		hc.sem <- struct{}{}
+		defer func() { <-hc.sem }()
+		hc.probeTarget(addr, t)
+	}()
+	return t
+}
+
+func (hc *healthCheck) start() {
+	stop := make(chan struct{})
+	hc.stop = stop
+	go func() {
+		tick := time.NewTicker(hc.interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				hc.checkAll()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (hc *healthCheck) stopChecks() {
+	if hc.stop != nil {
+		close(hc.stop)
+		hc.stop = nil
+	}
+}
+
+// checkAll checks all targets, and forgets the ones that haven't been seen in an answer for a while.
+func (hc *healthCheck) checkAll() {
+	expired := time.Now().Add(-targetExpire).UnixNano()
+
+	hc.mutex.Lock()
+	addrs := make([]netip.Addr, 0, len(hc.targets))
+	targets := make([]*target, 0, len(hc.targets))
+	for addr, t := range hc.targets {
+		if t.lastSeen.Load() < expired {
+			delete(hc.targets, addr)
+			HealthCheckStatus.DeleteLabelValues(addr.String())
+			HealthCheckFailureCount.DeleteLabelValues(addr.String())
+			continue
+		}
+		addrs = append(addrs, addr)
+		targets = append(targets, t)
+	}
+	hc.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for i := range addrs {
+		hc.sem <- struct{}{}
+		wg.Add(1)
+		go func(addr netip.Addr, t *target) {
+			defer func() { <-hc.sem; wg.Done() }()
+			hc.check(addr, t)
+		}(addrs[i], targets[i])
+	}
+	wg.Wait()
+}
+
+// check probes addr and updates the health of t. If t is already being probed, it does nothing.
+func (hc *healthCheck) check(addr netip.Addr, t *target) {
+	if !t.probing.CompareAndSwap(false, true) {
+		return
+	}
+	hc.probeTarget(addr, t)
+}
+
+// probeTarget probes addr and updates the health of t. The caller must have set t.probing.
+func (hc *healthCheck) probeTarget(addr netip.Addr, t *target) {
+	defer t.probing.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), hc.timeout)
+	defer cancel()
+	err := hc.probe(ctx, addr)
+
+	if err != nil {
+		HealthCheckFailureCount.WithLabelValues(addr.String()).Inc()
+		t.fails++
+		if t.fails >= hc.fails && t.healthy.Swap(false) {
+			log.Warningf("Address %s is unhealthy: %s", addr, err)
+			HealthCheckStatus.WithLabelValues(addr.String()).Set(0)
+		}
+		return
+	}
+	t.fails = 0
+	if !t.healthy.Swap(true) {
+		log.Infof("Address %s is healthy again", addr)
+		HealthCheckStatus.WithLabelValues(addr.String()).Set(1)
+	}
+}
+
+// defaultProbe returns the probe for the configured protocol.
+func (hc *healthCheck) defaultProbe() func(ctx context.Context, addr netip.Addr) error {
+	if hc.protocol == "http" {
+		client := &http.Client{
+			Transport: &http.Transport{Proxy: nil, DisableKeepAlives: true},
+			// Don't follow redirects, a redirect is a healthy response.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		return func(ctx context.Context, addr netip.Addr) error {
+			url := "http://" + net.JoinHostPort(addr.String(), hc.port) + hc.path
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 400 {
+				return fmt.Errorf("unexpected status: %s", resp.Status)
+			}
+			return nil
+		}
+	}
+
+	return func(ctx context.Context, addr netip.Addr) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(addr.String(), hc.port))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
@@ -0,0 +1,232 @@
+package loadbalance
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// fakeProbe fails the probes of the addresses in down.
+type fakeProbe struct {
+	sync.Mutex
+	down map[netip.Addr]bool
+}
+
+func (f *fakeProbe) probe(_ context.Context, addr netip.Addr) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.down[addr] {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (f *fakeProbe) set(addr string, down bool) {
+	f.Lock()
+	defer f.Unlock()
+	f.down[netip.MustParseAddr(addr)] = down
+}
+
+func TestHealthCheckFilter(t *testing.T) {
+	lb, err := parse(caddy.NewTestController("dns", `loadbalance {
+		health_check tcp 80
+		health_check_fails 2
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeProbe{down: map[netip.Addr]bool{}}
+	lb.health.probe = f.probe
+	rm := LoadBalance{Next: geoHandler(), shuffle: lb.shuffleFunc}
+
+	addresses := func() []string {
+		t.Helper()
+		req := new(dns.Msg)
+		req.SetQuestion("www.example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := rm.ServeDNS(context.Background(), rec, req); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		addrs := []string{}
+		for _, rr := range rec.Msg.Answer {
+			if a, ok := rr.(*dns.A); ok {
+				addrs = append(addrs, a.A.String())
+			}
+		}
+		return addrs
+	}
+	checkAll := func() {
+		lb.health.checkAll()
+	}
+
+	// New addresses are healthy until checked.
+	if addrs := addresses(); len(addrs) != 4 {
+		t.Fatalf("Expected 4 addresses, got %v", addrs)
+	}
+	waitForProbes(lb.health)
+
+	f.set("192.0.2.2", true)
+	checkAll()
+	if addrs := addresses(); len(addrs) != 4 {
+		t.Fatalf("Expected 4 addresses after a single failure, got %v", addrs)
+	}
+	checkAll()
+	addrs := addresses()
+	if len(addrs) != 3 || strings.Contains(strings.Join(addrs, " "), "192.0.2.2") {
+		t.Fatalf("Expected 192.0.2.2 to be removed, got %v", addrs)
+	}
+
+	for _, a := range []string{"192.0.2.1", "192.0.2.3", "192.0.2.4"} {
+		f.set(a, true)
+	}
+	checkAll()
+	checkAll()
+	if addrs := addresses(); len(addrs) != 4 {
+		t.Fatalf("Expected all addresses when all are unhealthy, got %v", addrs)
+	}
+
+	f.set("192.0.2.2", false)
+	checkAll()
+	if addrs := addresses(); len(addrs) != 1 || addrs[0] != "192.0.2.2" {
+		t.Fatalf("Expected only 192.0.2.2 after it recovered, got %v", addrs)
+	}
+}
+
+// waitForProbes waits until the probes of new targets are done.
+func waitForProbes(hc *healthCheck) {
+	for {
+		probing := false
+		hc.mutex.RLock()
+		for _, t := range hc.targets {
+			probing = probing || t.probing.Load()
+		}
+		hc.mutex.RUnlock()
+		if !probing {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHealthCheckExpire(t *testing.T) {
+	hc := newHealthCheck()
+	hc.probe = func(context.Context, netip.Addr) error { return nil }
+
+	old := time.Now().Add(-2 * targetExpire).UnixNano()
+	hc.target(netip.MustParseAddr("192.0.2.1"), old)
+	hc.target(netip.MustParseAddr("192.0.2.2"), time.Now().UnixNano())
+	waitForProbes(hc)
+	hc.checkAll()
+
+	hc.mutex.RLock()
+	defer hc.mutex.RUnlock()
+	if len(hc.targets) != 1 {
+		t.Fatalf("Expected 1 target, got %d", len(hc.targets))
+	}
+	if _, ok := hc.targets[netip.MustParseAddr("192.0.2.2")]; !ok {
+		t.Errorf("Expected 192.0.2.2 to be kept")
+	}
+}
+
+func TestHealthCheckConcurrentProbes(t *testing.T) {
+	hc := newHealthCheck()
+	var mu sync.Mutex
+	running, most := 0, 0
+	hc.probe = func(context.Context, netip.Addr) error {
+		mu.Lock()
+		running++
+		most = max(most, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+
+	now := time.Now().UnixNano()
+	for i := 0; i < 4*maxConcurrentProbes; i++ {
+		hc.target(netip.AddrFrom4([4]byte{192, 0, 2, byte(i)}), now)
+	}
+	waitForProbes(hc)
+
+	if most > maxConcurrentProbes {
+		t.Errorf("Expected at most %d concurrent probes, got %d", maxConcurrentProbes, most)
+	}
+}
+
+func TestHealthCheckNetworks(t *testing.T) {
+	hc := newHealthCheck()
+	hc.probe = func(context.Context, netip.Addr) error { return nil }
+	hc.networks = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+
+	now := time.Now().UnixNano()
+	if hc.target(netip.MustParseAddr("192.0.2.1"), now) == nil {
+		t.Error("Expected 192.0.2.1 to be checked")
+	}
+	if hc.target(netip.MustParseAddr("198.51.100.1"), now) != nil {
+		t.Error("Expected 198.51.100.1 not to be checked")
+	}
+	waitForProbes(hc)
+
+	hc.mutex.RLock()
+	defer hc.mutex.RUnlock()
+	if len(hc.targets) != 1 {
+		t.Errorf("Expected 1 target, got %d", len(hc.targets))
+	}
+}
+
+func TestHealthCheckProbes(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ok.Close()
+	addr := netip.MustParseAddrPort(strings.TrimPrefix(ok.URL, "http://"))
+
+	// A port without a listener.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := netip.MustParseAddrPort(l.Addr().String())
+	l.Close()
+
+	tests := []struct {
+		protocol string
+		port     uint16
+		path     string
+		healthy  bool
+	}{
+		{"tcp", addr.Port(), "", true},
+		{"tcp", closed.Port(), "", false},
+		{"http", addr.Port(), "/healthz", true},
+		{"http", addr.Port(), "/", false},
+		{"http", closed.Port(), "/healthz", false},
+	}
+	for i, tc := range tests {
+		hc := newHealthCheck()
+		hc.protocol, hc.port, hc.path = tc.protocol, strconv.Itoa(int(tc.port)), tc.path
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := hc.defaultProbe()(ctx, addr.Addr())
+		cancel()
+		if healthy := err == nil; healthy != tc.healthy {
+			t.Errorf("Test %d: expected healthy %t, got error %v", i, tc.healthy, err)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 const (
 	ramdomShufflePolicy      = "round_robin"
 	weightedRoundRobinPolicy = "weighted"
+	geoPolicyName            = "geo"
 )
 
 // LoadBalanceResponseWriter is a response writer that shuffles A, AAAA and MX records.
@@ -0,0 +1,32 @@
+package loadbalance
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// HealthCheckStatus is the health of the addresses that are checked, 1 if healthy and 0 if not.
+	HealthCheckStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "loadbalance",
+		Name:      "health_check_healthy",
+		Help:      "Gauge of the health of the addresses in answers, 1 if healthy and 0 if not.",
+	}, []string{"address"})
+	// HealthCheckFailureCount is the number of failed health checks per address.
+	HealthCheckFailureCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "loadbalance",
+		Name:      "health_check_failures_total",
+		Help:      "Counter of failed health checks per address.",
+	}, []string{"address"})
+	// HealthCheckFallbackCount is the number of answers in which all addresses were unhealthy.
+	HealthCheckFallbackCount = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "loadbalance",
+		Name:      "health_check_fallbacks_total",
+		Help:      "Counter of answers in which all addresses were unhealthy, and all were returned.",
+	})
+)
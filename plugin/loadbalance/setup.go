@@ -1,6 +1,7 @@
 package loadbalance
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 )
@@ -20,10 +22,13 @@ var errOpen = errors.New("Weight file open error")
 func init() { plugin.Register("loadbalance", setup) }
 
 type lbFuncs struct {
-	shuffleFunc    func(*dns.Msg) *dns.Msg
-	onStartUpFunc  func() error
-	onShutdownFunc func() error
-	weighted       *weightedRR // used in unit tests only
+	shuffleFunc        func(*dns.Msg) *dns.Msg
+	contextShuffleFunc func(context.Context, request.Request, *dns.Msg) *dns.Msg // used instead of shuffleFunc if set
+	onStartUpFunc      func() error
+	onShutdownFunc     func() error
+	weighted           *weightedRR  // used in unit tests only
+	geo                *geoPolicy   // used in unit tests only
+	health             *healthCheck // used in unit tests only
 }
 
 func setup(c *caddy.Controller) error {
@@ -40,7 +45,7 @@ func setup(c *caddy.Controller) error {
 	}
 
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
-		return LoadBalance{Next: next, shuffle: lb.shuffleFunc}
+		return LoadBalance{Next: next, shuffle: lb.shuffleFunc, contextShuffle: lb.contextShuffleFunc}
 	})
 
 	return nil
@@ -48,56 +53,89 @@ func setup(c *caddy.Controller) error {
 
 // func parse(c *caddy.Controller) (string, *weightedRR, error) {
 func parse(c *caddy.Controller) (*lbFuncs, error) {
+	for c.Next() {
+		hc := newHealthCheck()
+		lb, err := parsePolicy(c, hc)
+		if err != nil {
+			return nil, err
+		}
+		if hc.enabled() {
+			lb = hc.wrap(lb)
+		}
+		return lb, nil
+	}
+	return nil, c.ArgErr()
+}
+
+// parsePolicy parses the policy and its block. The health check options are parsed into hc.
+func parsePolicy(c *caddy.Controller, hc *healthCheck) (*lbFuncs, error) {
 	config := dnsserver.GetConfig(c)
 
-	for c.Next() {
-		args := c.RemainingArgs()
-		if len(args) == 0 {
-			return &lbFuncs{shuffleFunc: randomShuffle}, nil
+	args := c.RemainingArgs()
+	if len(args) == 0 {
+		args = []string{ramdomShufflePolicy}
+	}
+	switch args[0] {
+	case ramdomShufflePolicy:
+		if len(args) > 1 {
+			return nil, c.Errf("unknown property for %s", args[0])
 		}
-		switch args[0] {
-		case ramdomShufflePolicy:
-			if len(args) > 1 {
-				return nil, c.Errf("unknown property for %s", args[0])
-			}
-			return &lbFuncs{shuffleFunc: randomShuffle}, nil
-		case weightedRoundRobinPolicy:
-			if len(args) < 2 {
-				return nil, c.Err("missing weight file argument")
+		for c.NextBlock() {
+			if ok, err := hc.parseOption(c); !ok {
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			} else if err != nil {
+				return nil, err
 			}
+		}
+		return &lbFuncs{shuffleFunc: randomShuffle}, nil
+	case weightedRoundRobinPolicy:
+		if len(args) < 2 {
+			return nil, c.Err("missing weight file argument")
+		}
 
-			if len(args) > 2 {
-				return nil, c.Err("unexpected argument(s)")
-			}
+		if len(args) > 2 {
+			return nil, c.Err("unexpected argument(s)")
+		}
 
-			weightFileName := args[1]
-			if !filepath.IsAbs(weightFileName) && config.Root != "" {
-				weightFileName = filepath.Join(config.Root, weightFileName)
-			}
-			reload := 30 * time.Second // default reload period
-			for c.NextBlock() {
-				switch c.Val() {
-				case "reload":
-					t := c.RemainingArgs()
-					if len(t) < 1 {
-						return nil, c.Err("reload duration value is missing")
-					}
-					if len(t) > 1 {
-						return nil, c.Err("unexpected argument")
-					}
-					var err error
-					reload, err = time.ParseDuration(t[0])
-					if err != nil {
-						return nil, c.Errf("invalid reload duration '%s'", t[0])
-					}
-				default:
+		weightFileName := args[1]
+		if !filepath.IsAbs(weightFileName) && config.Root != "" {
+			weightFileName = filepath.Join(config.Root, weightFileName)
+		}
+		reload := 30 * time.Second // default reload period
+		for c.NextBlock() {
+			switch c.Val() {
+			case "reload":
+				t := c.RemainingArgs()
+				if len(t) < 1 {
+					return nil, c.Err("reload duration value is missing")
+				}
+				if len(t) > 1 {
+					return nil, c.Err("unexpected argument")
+				}
+				var err error
+				reload, err = time.ParseDuration(t[0])
+				if err != nil {
+					return nil, c.Errf("invalid reload duration '%s'", t[0])
+				}
+			default:
+				if ok, err := hc.parseOption(c); !ok {
 					return nil, c.Errf("unknown property '%s'", c.Val())
+				} else if err != nil {
+					return nil, err
 				}
 			}
-			return createWeightedFuncs(weightFileName, reload), nil
-		default:
-			return nil, fmt.Errorf("unknown policy: %s", args[0])
 		}
+		return createWeightedFuncs(weightFileName, reload), nil
+	case geoPolicyName:
+		if len(args) > 1 {
+			return nil, c.Errf("unknown property for %s", args[0])
+		}
+		g, err := parseGeo(c, hc)
+		if err != nil {
+			return nil, err
+		}
+		return createGeoFuncs(g), nil
+	default:
+		return nil, fmt.Errorf("unknown policy: %s", args[0])
 	}
-	return nil, c.ArgErr()
 }
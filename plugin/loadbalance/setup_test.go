@@ -49,6 +49,90 @@ func TestSetup(t *testing.T) {
 		{`loadbalance weighted wfile {
                                                     reload 30s  a
                                                  } `, true, "", "unexpected argument", -1},
+		// geo
+		{`loadbalance geo {
+			region eu 52.37 4.89
+			region us 40.71 -74.00
+			address eu 192.0.2.0/25 2001:db8:1::/48
+			address us 192.0.2.128/25
+			client us 10.0.0.0/8
+			top 2
+			edns-subnet
+		}`, false, "geo", "", -1},
+		{`loadbalance geo a`, true, "", "unknown property", -1},
+		{`loadbalance geo`, true, "", "no address locations defined", -1},
+		{`loadbalance geo {
+			address eu 192.0.2.0/25
+		}`, true, "", "unknown region", -1},
+		{`loadbalance geo {
+			region eu 91 4.89
+		}`, true, "", "invalid latitude", -1},
+		{`loadbalance geo {
+			region eu 52.37 east
+		}`, true, "", "invalid longitude", -1},
+		{`loadbalance geo {
+			region eu 52.37 4.89
+			region eu 52.37 4.89
+		}`, true, "", "duplicate region", -1},
+		{`loadbalance geo {
+			region eu 52.37 4.89
+			address eu 192.0.2.0/33
+		}`, true, "", "illegal CIDR notation", -1},
+		{`loadbalance geo {
+			region eu 52.37 4.89
+			address eu 192.0.2.0/25
+			top -1
+		}`, true, "", "invalid top value", -1},
+		// health checks
+		{`loadbalance {
+			health_check tcp 80
+		}`, false, "round_robin", "", -1},
+		{`loadbalance round_robin {
+			health_check http 8080 /healthz
+			health_check_interval 5s
+			health_check_timeout 1s
+			health_check_fails 3
+		}`, false, "round_robin", "", -1},
+		{`loadbalance weighted wfile {
+			reload 10s
+			health_check tcp 443
+		}`, false, "weighted", "", -1},
+		{`loadbalance geo {
+			region eu 52.37 4.89
+			address eu 192.0.2.0/25
+			health_check tcp 443
+		}`, false, "geo", "", -1},
+		{`loadbalance {
+			health_check tcp 80
+			health_check_networks 10.0.0.0/8 2001:db8::/32
+		}`, false, "round_robin", "", -1},
+		{`loadbalance {
+			health_check tcp 80
+			health_check_networks 10.0.0.1
+		}`, true, "", "invalid health_check_networks", -1},
+		{`loadbalance {
+			health_check udp 53
+		}`, true, "", "unknown health check protocol", -1},
+		{`loadbalance {
+			health_check tcp 0
+		}`, true, "", "invalid health check port", -1},
+		{`loadbalance {
+			health_check tcp 80 /healthz
+		}`, true, "", "Wrong argument count", -1},
+		{`loadbalance {
+			health_check http 80 healthz
+		}`, true, "", "invalid health check path", -1},
+		{`loadbalance {
+			health_check tcp 80
+			health_check_interval 0s
+		}`, true, "", "invalid health_check_interval", -1},
+		{`loadbalance {
+			health_check tcp 80
+			health_check_fails 0
+		}`, true, "", "invalid health_check_fails", -1},
+		{`loadbalance round_robin {
+			reload 10s
+		}`, true, "", "unknown property", -1},
 	}
 
 	for i, test := range tests {
@@ -81,6 +165,12 @@ func TestSetup(t *testing.T) {
 		if lb.weighted != nil {
 			policy = weightedRoundRobinPolicy
 		}
+		if lb.geo != nil {
+			policy = geoPolicyName
+		}
+		if strings.Contains(test.input, "health_check ") && lb.health == nil {
+			t.Errorf("Test %d: Expected health checks for input %s", i, test.input)
+		}
 		if policy != test.expectedPolicy {
 			t.Errorf("Test %d: Expected policy %s but got %s for input %s", i,
 				test.expectedPolicy, policy, test.input)
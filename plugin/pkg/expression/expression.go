@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"net"
+	"strings"
 
+	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/request"
 )
 
@@ -43,5 +46,15 @@ func DefaultEnv(ctx context.Context, state *request.Request) map[string]interfac
 		"bufsize":     state.Size,
 		"server_ip":   state.LocalIP,
 		"server_port": state.LocalPort,
+		"transport": func() string {
+			srv, ok := ctx.Value(dnsserver.Key{}).(*dnsserver.Server)
+			if !ok {
+				return transport.DNS
+			}
+			if scheme, _, ok := strings.Cut(srv.Addr, "://"); ok {
+				return scheme
+			}
+			return transport.DNS
+		},
 	}
 }
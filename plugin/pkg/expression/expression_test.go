@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin/metadata"
 	"github.com/coredns/coredns/request"
 )
@@ -71,3 +72,21 @@ func TestMetadata(t *testing.T) {
 		}
 	}
 }
+
+func TestTransport(t *testing.T) {
+	cases := []struct {
+		ctx      context.Context
+		expected string
+	}{
+		{context.Background(), "dns"},
+		{context.WithValue(context.Background(), dnsserver.Key{}, &dnsserver.Server{Addr: "dns://:53"}), "dns"},
+		{context.WithValue(context.Background(), dnsserver.Key{}, &dnsserver.Server{Addr: "tls://:853"}), "tls"},
+	}
+
+	for i, c := range cases {
+		f := DefaultEnv(c.ctx, &request.Request{})["transport"]
+		if r := f.(func() string)(); r != c.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, c.expected, r)
+		}
+	}
+}
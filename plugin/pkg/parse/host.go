@@ -57,6 +57,8 @@ func HostPortOrFile(s ...string) ([]string, error) {
 				ss = transport.GRPC + "://" + net.JoinHostPort(host, transport.GRPCPort)
 			case transport.HTTPS:
 				ss = transport.HTTPS + "://" + net.JoinHostPort(host, transport.HTTPSPort)
+			case transport.QUIC:
+				ss = transport.QUIC + "://" + net.JoinHostPort(host, transport.QUICPort)
 			}
 			servers = append(servers, ss)
 			continue
@@ -3,36 +3,74 @@ package parse
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
 )
 
-// TransferIn parses transfer statements: 'transfer from [address...]'.
-func TransferIn(c *caddy.Controller) (froms []string, err error) {
+// Key is a TSIG key to sign outgoing requests with.
+type Key struct {
+	Name      string // normalized key name, empty if no key is used
+	Algorithm string // fully qualified algorithm name, such as dns.HmacSHA256
+}
+
+// tsigAlgorithms are the TSIG algorithms that can be used to sign outgoing requests.
+var tsigAlgorithms = map[string]string{
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha224": dns.HmacSHA224,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha384": dns.HmacSHA384,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// TransferIn parses transfer statements: 'transfer from [address...] [key NAME [ALGORITHM]]'. The returned
+// key is the TSIG key to sign the requests with, its name is empty if there is none.
+func TransferIn(c *caddy.Controller) (froms []string, key Key, err error) {
 	if !c.NextArg() {
-		return nil, c.ArgErr()
+		return nil, Key{}, c.ArgErr()
 	}
 	value := c.Val()
 	switch value {
 	default:
-		return nil, c.Errf("unknown property %s", value)
+		return nil, Key{}, c.Errf("unknown property %s", value)
 	case "from":
-		froms = c.RemainingArgs()
+		froms, key, err = TransferKey(c.RemainingArgs())
+		if err != nil {
+			return nil, Key{}, err
+		}
 		if len(froms) == 0 {
-			return nil, c.ArgErr()
+			return nil, Key{}, c.ArgErr()
 		}
 		for i := range froms {
 			if froms[i] != "*" {
 				normalized, err := HostPort(froms[i], transport.Port)
 				if err != nil {
-					return nil, err
+					return nil, Key{}, err
 				}
 				froms[i] = normalized
 			} else {
-				return nil, fmt.Errorf("can't use '*' in transfer from")
+				return nil, Key{}, fmt.Errorf("can't use '*' in transfer from")
 			}
 		}
 	}
-	return froms, nil
+	return froms, key, nil
+}
+
+// TransferKey splits the optional 'key NAME [ALGORITHM]' off the end of args. It returns the remaining args
+// and the TSIG key, whose name is empty if there is none. The algorithm defaults to hmac-sha256.
+func TransferKey(args []string) ([]string, Key, error) {
+	switch {
+	case len(args) >= 3 && args[len(args)-3] == "key":
+		algorithm, ok := tsigAlgorithms[strings.TrimSuffix(strings.ToLower(args[len(args)-1]), ".")]
+		if !ok {
+			return nil, Key{}, fmt.Errorf("unknown TSIG algorithm: %s", args[len(args)-1])
+		}
+		return args[:len(args)-3], Key{Name: strings.ToLower(dns.Fqdn(args[len(args)-2])), Algorithm: algorithm}, nil
+	case len(args) >= 2 && args[len(args)-2] == "key":
+		return args[:len(args)-2], Key{Name: strings.ToLower(dns.Fqdn(args[len(args)-1])), Algorithm: dns.HmacSHA256}, nil
+	}
+	return args, Key{}, nil
 }
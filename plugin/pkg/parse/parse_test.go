@@ -11,36 +11,58 @@ func TestTransferIn(t *testing.T) {
 		inputFileRules string
 		shouldErr      bool
 		expectedFrom   []string
+		expectedKey    string
+		expectedAlg    string
 	}{
 		{
 			`from 127.0.0.1`,
-			false, []string{"127.0.0.1:53"},
+			false, []string{"127.0.0.1:53"}, "", "",
 		},
 		// OK transfer froms
 		{
 			`from 127.0.0.1 127.0.0.2`,
-			false, []string{"127.0.0.1:53", "127.0.0.2:53"},
+			false, []string{"127.0.0.1:53", "127.0.0.2:53"}, "", "",
+		},
+		// TSIG key
+		{
+			`from 127.0.0.1 127.0.0.2 key Example.Key`,
+			false, []string{"127.0.0.1:53", "127.0.0.2:53"}, "example.key.", "hmac-sha256.",
+		},
+		// TSIG key with algorithm
+		{
+			`from 127.0.0.1 key example.key HMAC-SHA512`,
+			false, []string{"127.0.0.1:53"}, "example.key.", "hmac-sha512.",
+		},
+		// Bad TSIG algorithm
+		{
+			`from 127.0.0.1 key example.key hmac-md4`,
+			true, []string{}, "", "",
+		},
+		// Bad transfer from only a key
+		{
+			`from key example.key`,
+			true, []string{}, "", "",
 		},
 		// Bad transfer from garbage
 		{
 			`from !@#$%^&*()`,
-			true, []string{},
+			true, []string{}, "", "",
 		},
 		// Bad transfer from no args
 		{
 			`from`,
-			true, []string{},
+			true, []string{}, "", "",
 		},
 		// Bad transfer from *
 		{
 			`from *`,
-			true, []string{},
+			true, []string{}, "", "",
 		},
 	}
 
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.inputFileRules)
-		froms, err := TransferIn(c)
+		froms, key, err := TransferIn(c)
 
 		if err == nil && test.shouldErr {
 			t.Fatalf("Test %d expected errors, but got no error %+v %+v", i, err, test)
@@ -48,6 +70,13 @@ func TestTransferIn(t *testing.T) {
 			t.Fatalf("Test %d expected no errors, but got '%v'", i, err)
 		}
 
+		if key.Name != test.expectedKey {
+			t.Fatalf("Test %d expected key %q, got %q", i, test.expectedKey, key.Name)
+		}
+		if key.Algorithm != test.expectedAlg {
+			t.Fatalf("Test %d expected algorithm %q, got %q", i, test.expectedAlg, key.Algorithm)
+		}
+
 		if test.expectedFrom != nil {
 			for j, got := range froms {
 				if got != test.expectedFrom[j] {
@@ -0,0 +1,98 @@
+// Package views selects the view of a query, for plugins that answer queries from different data depending on
+// the client.
+package views
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/expression"
+	"github.com/coredns/coredns/request"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+)
+
+// View is a named set of queries, selected by the network of the client or by an expression.
+type View struct {
+	Name string
+	nets []netip.Prefix
+	prog *vm.Program
+}
+
+// Selector is implemented by plugins that answer queries from per view data. Plugins that store answers, like
+// cache, use it to keep the answers of different views apart.
+type Selector interface {
+	// SelectView returns the name of the view of the query, or the empty string if there is none.
+	SelectView(ctx context.Context, state request.Request) string
+}
+
+// New returns the view name that selects the queries described by args, which are either "net CIDR..." or
+// "expr EXPRESSION".
+func New(name string, args []string) (*View, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("view %q requires net or expr", name)
+	}
+	v := &View{Name: name}
+	switch args[0] {
+	case "net":
+		for _, a := range args[1:] {
+			p, err := parsePrefix(a)
+			if err != nil {
+				return nil, fmt.Errorf("illegal CIDR notation %q", a)
+			}
+			v.nets = append(v.nets, p)
+		}
+	case "expr":
+		prog, err := expr.Compile(strings.Join(args[1:], " "), expr.Env(expression.DefaultEnv(context.Background(), nil)), expr.AsBool())
+		if err != nil {
+			return nil, err
+		}
+		v.prog = prog
+	default:
+		return nil, fmt.Errorf("unknown view selector '%s'", args[0])
+	}
+	return v, nil
+}
+
+// Match returns true if the query is in the view.
+func (v *View) Match(ctx context.Context, state request.Request) bool {
+	if v.prog != nil {
+		result, err := expr.Run(v.prog, expression.DefaultEnv(ctx, &state))
+		if err != nil {
+			return false
+		}
+		b, ok := result.(bool)
+		return ok && b
+	}
+
+	addr, err := netip.ParseAddr(state.IP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, p := range v.nets {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrefix parses a CIDR or a single address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return p.Masked(), nil
+}
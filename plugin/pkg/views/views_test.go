@@ -0,0 +1,67 @@
+package views
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		args      []string
+		shouldErr bool
+	}{
+		{[]string{"net", "10.0.0.0/8", "192.168.0.1", "fd00::/8"}, false},
+		{[]string{"expr", "incidr(client_ip(),", "'10.0.0.0/8')"}, false},
+		{[]string{"net"}, true},
+		{[]string{"net", "10.0.0.0/33"}, true},
+		{[]string{"expr", "client_ip()"}, true},
+		{[]string{"zone", "example.org"}, true},
+	}
+	for i, tc := range tests {
+		_, err := New("internal", tc.args)
+		if (err != nil) != tc.shouldErr {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.shouldErr, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	nets, err := New("internal", []string{"net", "10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	country, err := New("de", []string{"expr", "metadata('geoip/country/code')", "==", "'DE'"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := metadata.ContextWithMetadata(context.Background())
+	metadata.SetValueFunc(ctx, "geoip/country/code", func() string { return "DE" })
+
+	tests := []struct {
+		view     *View
+		ctx      context.Context
+		remoteIP string
+		expected bool
+	}{
+		{nets, context.Background(), "10.1.2.3", true},
+		{nets, context.Background(), "::1", true},
+		{nets, context.Background(), "192.168.1.1", false},
+		{country, ctx, "192.168.1.1", true},
+		{country, context.Background(), "192.168.1.1", false},
+	}
+	for i, tc := range tests {
+		r := new(dns.Msg)
+		r.SetQuestion("example.org.", dns.TypeA)
+		state := request.Request{W: &test.ResponseWriter{RemoteIP: tc.remoteIP}, Req: r}
+		if m := tc.view.Match(tc.ctx, state); m != tc.expected {
+			t.Errorf("Test %d: expected %t, got %t", i, tc.expected, m)
+		}
+	}
+}
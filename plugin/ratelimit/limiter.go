@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket: it is refilled with rate tokens per second, up to burst, and a query takes a token.
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// numShards is the number of shards of a limiter, to reduce lock contention.
+const numShards = 64
+
+// limiter holds the buckets of a quota, by key.
+type limiter struct {
+	shards  [numShards]shard
+	maxSize int // per shard
+}
+
+// shard holds a part of the buckets, with the least recently used bucket at the back of lru.
+type shard struct {
+	sync.Mutex
+	buckets map[string]*list.Element
+	lru     list.List
+}
+
+func newLimiter(maxSize int) *limiter {
+	l := &limiter{maxSize: maxSize / numShards}
+	if l.maxSize < 1 {
+		l.maxSize = 1
+	}
+	for i := range l.shards {
+		l.shards[i].buckets = make(map[string]*list.Element)
+	}
+	return l
+}
+
+// allow takes a token from the bucket of key, and returns false if there was none. When the shard of key
+// is full, its least recently used bucket is removed to make room.
+func (l *limiter) allow(key string, now time.Time, rate, burst float64) bool {
+	s := &l.shards[hash(key)%numShards]
+
+	s.Lock()
+	defer s.Unlock()
+
+	var b *bucket
+	if e, ok := s.buckets[key]; ok {
+		b = e.Value.(*bucket)
+		s.lru.MoveToFront(e)
+	} else {
+		if len(s.buckets) >= l.maxSize {
+			s.remove(s.lru.Back())
+		}
+		b = &bucket{key: key, tokens: burst, last: now}
+		s.buckets[key] = s.lru.PushFront(b)
+	}
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// full returns true if b has been refilled completely since it was last used, so it can be forgotten.
+func full(b *bucket, now time.Time, rate, burst float64) bool {
+	return now.Sub(b.last).Seconds()*rate >= burst
+}
+
+// remove removes the bucket in e from s. The caller must hold the lock of s.
+func (s *shard) remove(e *list.Element) {
+	delete(s.buckets, e.Value.(*bucket).key)
+	s.lru.Remove(e)
+}
+
+// cleanup removes the full buckets. The buckets are checked from the least recently used one, until one
+// that is not full.
+func (l *limiter) cleanup(now time.Time, rate, burst float64) {
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.Lock()
+		for e := s.lru.Back(); e != nil && full(e.Value.(*bucket), now, rate, burst); e = s.lru.Back() {
+			s.remove(e)
+		}
+		s.Unlock()
+	}
+}
+
+// len returns the number of buckets.
+func (l *limiter) len() int {
+	n := 0
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.Lock()
+		n += len(s.buckets)
+		s.Unlock()
+	}
+	return n
+}
+
+// hash returns the FNV-1a hash of key, to select its shard.
+func hash(key string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h = (h ^ uint32(key[i])) * 16777619
+	}
+	return h
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newLimiter(100)
+
+	for i := 0; i < 4; i++ {
+		if !l.allow("a", now, 2, 4) {
+			t.Fatalf("Expected query %d to be allowed by the burst", i)
+		}
+	}
+	if l.allow("a", now, 2, 4) {
+		t.Fatal("Expected query to be refused after the burst")
+	}
+	if !l.allow("b", now, 2, 4) {
+		t.Fatal("Expected query for another key to be allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if !l.allow("a", now, 2, 4) {
+		t.Fatal("Expected query to be allowed after a refill")
+	}
+	if l.allow("a", now, 2, 4) {
+		t.Fatal("Expected query to be refused")
+	}
+
+	now = now.Add(time.Minute)
+	l.cleanup(now, 2, 4)
+	if n := l.len(); n != 0 {
+		t.Errorf("Expected full buckets to be removed, got %d", n)
+	}
+}
+
+func TestLimiterMaxSize(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newLimiter(2 * numShards)
+	for i := 0; i < 1000; i++ {
+		l.allow(strconv.Itoa(i), now, 1, 1)
+	}
+	if n := l.len(); n > 2*numShards {
+		t.Errorf("Expected at most %d buckets, got %d", 2*numShards, n)
+	}
+}
+
+func TestLimiterLRU(t *testing.T) {
+	// Find three keys in the same shard, which holds two buckets.
+	var keys []string
+	for i := 0; len(keys) < 3; i++ {
+		if k := strconv.Itoa(i); hash(k)%numShards == 0 {
+			keys = append(keys, k)
+		}
+	}
+	now := time.Unix(0, 0)
+	l := newLimiter(2 * numShards)
+
+	l.allow(keys[0], now, 1, 1)
+	l.allow(keys[1], now, 1, 1)
+	// keys[0] is refused, which makes keys[1] the least recently used bucket.
+	if l.allow(keys[0], now, 1, 1) {
+		t.Fatal("Expected query to be refused")
+	}
+	l.allow(keys[2], now, 1, 1)
+
+	if !l.allow(keys[1], now, 1, 1) {
+		t.Error("Expected the least recently used bucket to be evicted")
+	}
+	if l.allow(keys[2], now, 1, 1) {
+		t.Error("Expected the recently used bucket to be kept")
+	}
+}
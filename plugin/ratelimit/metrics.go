@@ -0,0 +1,16 @@
+package ratelimit
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// RefusedCount is the number of queries that were refused because they exceeded a quota.
+var RefusedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "ratelimit",
+	Name:      "refused_requests_total",
+	Help:      "Counter of DNS requests refused because they exceeded a quota.",
+}, []string{"server", "zone", "view", "quota"})
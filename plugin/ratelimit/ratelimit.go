@@ -0,0 +1,181 @@
+// Package ratelimit implements a plugin that enforces query rate quotas.
+package ratelimit
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/health"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// quotaKind is what a quota is applied to.
+type quotaKind int
+
+const (
+	// quotaClient applies to every client (prefix) separately.
+	quotaClient quotaKind = iota
+	// quotaNet applies to all clients in a network together.
+	quotaNet
+	// quotaZone applies to every zone of the plugin separately.
+	quotaZone
+	// quotaMetadata applies to every value of a metadata label separately.
+	quotaMetadata
+)
+
+var kindNames = map[quotaKind]string{quotaClient: "client", quotaNet: "net", quotaZone: "zone", quotaMetadata: "metadata"}
+
+func (k quotaKind) String() string { return kindNames[k] }
+
+// quota is a number of queries per second.
+type quota struct {
+	kind  quotaKind
+	rate  float64 // queries per second
+	burst float64 // queries that can be made at once
+
+	ipv4Mask int          // quotaClient
+	ipv6Mask int          // quotaClient
+	net      netip.Prefix // quotaNet
+	label    string       // quotaMetadata
+
+	limiter *limiter
+}
+
+// Ratelimit refuses queries that exceed one of its quotas.
+type Ratelimit struct {
+	Next  plugin.Handler
+	Zones []string
+
+	quotas     []*quota
+	exempt     []netip.Prefix
+	overloaded float64 // factor applied to the quotas while the process is overloaded, zero is disabled
+	maxSize    int
+
+	now  func() time.Time
+	stop chan struct{}
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rl *Ratelimit) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	zone := plugin.Zones(rl.Zones).Matches(state.Name())
+	if zone == "" {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	addr, err := netip.ParseAddr(state.IP())
+	if err != nil {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, p := range rl.exempt {
+		if p.Contains(addr) {
+			return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+		}
+	}
+
+	factor := 1.0
+	if rl.overloaded > 0 && health.Overloaded() {
+		factor = rl.overloaded
+	}
+	now := rl.now()
+
+	for _, q := range rl.quotas {
+		key, ok := q.key(ctx, addr, zone)
+		if !ok {
+			continue
+		}
+		burst := q.burst * factor
+		if burst < 1 {
+			burst = 1
+		}
+		if q.limiter.allow(key, now, q.rate*factor, burst) {
+			continue
+		}
+
+		RefusedCount.WithLabelValues(metrics.WithServer(ctx), zone, metrics.WithView(ctx), q.kind.String()).Inc()
+		m := new(dns.Msg).SetRcode(r, dns.RcodeRefused)
+		// The extended error can only be given to clients that use EDNS0.
+		if opt := r.IsEdns0(); opt != nil {
+			m.SetEdns0(opt.UDPSize(), opt.Do())
+			ede := dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeProhibited, ExtraText: "rate limit exceeded"}
+			m.IsEdns0().Option = append(m.IsEdns0().Option, &ede)
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+
+	return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+}
+
+// key returns the key of the bucket of q for a query from addr in zone. It returns false if q doesn't apply.
+func (q *quota) key(ctx context.Context, addr netip.Addr, zone string) (string, bool) {
+	switch q.kind {
+	case quotaClient:
+		mask := q.ipv6Mask
+		if addr.Is4() {
+			mask = q.ipv4Mask
+		}
+		p, err := addr.Prefix(mask)
+		if err != nil {
+			return "", false
+		}
+		return p.Addr().String(), true
+	case quotaNet:
+		return "", q.net.Contains(addr)
+	case quotaZone:
+		return zone, true
+	case quotaMetadata:
+		f := metadata.ValueFunc(ctx, q.label)
+		if f == nil {
+			return "", false
+		}
+		v := f()
+		return v, v != ""
+	}
+	return "", false
+}
+
+// Name implements the plugin.Handler interface.
+func (rl *Ratelimit) Name() string { return "ratelimit" }
+
+// cleanupInterval is how often the buckets that are full are removed.
+const cleanupInterval = 1 * time.Minute
+
+// OnStartup starts removing the buckets that are full.
+func (rl *Ratelimit) OnStartup() error {
+	stop := make(chan struct{})
+	rl.stop = stop
+	go func() {
+		tick := time.NewTicker(cleanupInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				now := rl.now()
+				for _, q := range rl.quotas {
+					q.limiter.cleanup(now, q.rate, q.burst)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// OnShutdown stops removing the buckets.
+func (rl *Ratelimit) OnShutdown() error {
+	if rl.stop != nil {
+		close(rl.stop)
+		rl.stop = nil
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func newTestRatelimit(t *testing.T, config string, keys ...string) *Ratelimit {
+	t.Helper()
+	c := caddy.NewTestController("dns", config)
+	c.ServerBlockKeys = keys
+	rl, err := ratelimitParse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	now := time.Unix(0, 0)
+	rl.now = func() time.Time { return now }
+	return rl
+}
+
+// query sends n queries for qname from addr, and returns the number of refused queries.
+func query(ctx context.Context, rl *Ratelimit, qname, addr string, n int) int {
+	refused := 0
+	for i := 0; i < n; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: addr})
+		rl.ServeDNS(ctx, rec, m)
+		if rec.Rcode == dns.RcodeRefused {
+			refused++
+		}
+	}
+	return refused
+}
+
+func TestRatelimitClient(t *testing.T) {
+	rl := newTestRatelimit(t, "ratelimit {\n client 5\n exempt 10.0.1.0/24\n}", "example.org.")
+	ctx := context.Background()
+
+	if n := query(ctx, rl, "example.org.", "10.0.0.1", 10); n != 5 {
+		t.Errorf("Expected 5 refused queries, got %d", n)
+	}
+	if n := query(ctx, rl, "example.org.", "10.0.0.2", 5); n != 0 {
+		t.Errorf("Expected no refused queries for another client, got %d", n)
+	}
+	if n := query(ctx, rl, "example.net.", "10.0.0.1", 5); n != 0 {
+		t.Errorf("Expected no refused queries outside the zones, got %d", n)
+	}
+	if n := query(ctx, rl, "example.org.", "10.0.1.1", 10); n != 0 {
+		t.Errorf("Expected no refused queries for an exempt client, got %d", n)
+	}
+}
+
+func TestRatelimitClientPrefix(t *testing.T) {
+	rl := newTestRatelimit(t, "ratelimit {\n client 5\n ipv4-prefix-length 24\n}", "example.org.")
+	ctx := context.Background()
+
+	query(ctx, rl, "example.org.", "10.0.0.1", 5)
+	if n := query(ctx, rl, "example.org.", "10.0.0.2", 5); n != 5 {
+		t.Errorf("Expected the prefix to share the quota, got %d refused", n)
+	}
+}
+
+func TestRatelimitNetAndZone(t *testing.T) {
+	rl := newTestRatelimit(t, "ratelimit example.org example.net {\n net 10.0.0.0/8 10 20\n zone 30\n}")
+	ctx := context.Background()
+
+	// 10.0.0.0/8 shares a burst of 20.
+	if n := query(ctx, rl, "a.example.org.", "10.0.0.1", 10); n != 0 {
+		t.Errorf("Expected no refused queries, got %d", n)
+	}
+	if n := query(ctx, rl, "a.example.org.", "10.1.0.1", 15); n != 5 {
+		t.Errorf("Expected 5 refused queries, got %d", n)
+	}
+	// example.org has 30 queries in total, 20 of which were allowed.
+	if n := query(ctx, rl, "b.example.org.", "192.0.2.1", 15); n != 5 {
+		t.Errorf("Expected 5 refused queries for the zone, got %d", n)
+	}
+	if n := query(ctx, rl, "example.net.", "192.0.2.1", 15); n != 0 {
+		t.Errorf("Expected no refused queries for another zone, got %d", n)
+	}
+}
+
+func TestRatelimitMetadata(t *testing.T) {
+	rl := newTestRatelimit(t, "ratelimit {\n metadata kubernetes/client-namespace 2\n}", ".")
+
+	tenant := func(name string) context.Context {
+		ctx := metadata.ContextWithMetadata(context.Background())
+		metadata.SetValueFunc(ctx, "kubernetes/client-namespace", func() string { return name })
+		return ctx
+	}
+
+	if n := query(tenant("noisy"), rl, "example.org.", "10.0.0.1", 10); n != 8 {
+		t.Errorf("Expected 8 refused queries, got %d", n)
+	}
+	if n := query(tenant("quiet"), rl, "example.org.", "10.0.0.1", 2); n != 0 {
+		t.Errorf("Expected no refused queries for another tenant, got %d", n)
+	}
+	// Without the label the quota doesn't apply.
+	if n := query(context.Background(), rl, "example.org.", "10.0.0.1", 10); n != 0 {
+		t.Errorf("Expected no refused queries without metadata, got %d", n)
+	}
+}
+
+func TestRatelimitResponse(t *testing.T) {
+	rl := newTestRatelimit(t, "ratelimit {\n client 1\n}", ".")
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetEdns0(1232, false)
+	for i := 0; i < 2; i++ {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rl.ServeDNS(context.Background(), rec, m)
+		if i == 0 {
+			continue
+		}
+		if rec.Rcode != dns.RcodeRefused {
+			t.Fatalf("Expected REFUSED, got %s", dns.RcodeToString[rec.Rcode])
+		}
+		opt := rec.Msg.IsEdns0()
+		if opt == nil || len(opt.Option) != 1 {
+			t.Fatal("Expected an extended DNS error")
+		}
+		if ede, ok := opt.Option[0].(*dns.EDNS0_EDE); !ok || ede.InfoCode != dns.ExtendedErrorCodeProhibited {
+			t.Errorf("Expected Prohibited extended error, got %v", opt.Option[0])
+		}
+	}
+
+	// Without EDNS0 in the query, there is none in the reply.
+	m = new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rl.ServeDNS(context.Background(), rec, m)
+	if rec.Rcode != dns.RcodeRefused {
+		t.Fatalf("Expected REFUSED, got %s", dns.RcodeToString[rec.Rcode])
+	}
+	if rec.Msg.IsEdns0() != nil {
+		t.Errorf("Expected no OPT record in the reply, got %v", rec.Msg.IsEdns0())
+	}
+}
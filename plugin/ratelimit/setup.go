@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+)
+
+func init() { plugin.Register("ratelimit", setup) }
+
+func setup(c *caddy.Controller) error {
+	rl, err := ratelimitParse(c)
+	if err != nil {
+		return plugin.Error("ratelimit", err)
+	}
+
+	c.OnStartup(rl.OnStartup)
+	c.OnShutdown(rl.OnShutdown)
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rl.Next = next
+		return rl
+	})
+
+	return nil
+}
+
+func ratelimitParse(c *caddy.Controller) (*Ratelimit, error) {
+	rl := &Ratelimit{maxSize: 100000, now: time.Now}
+	ipv4Mask, ipv6Mask := 32, 128
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		rl.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "client", "zone":
+				q := &quota{kind: quotaClient}
+				if c.Val() == "zone" {
+					q.kind = quotaZone
+				}
+				if err := parseRate(c, q, c.RemainingArgs()); err != nil {
+					return nil, err
+				}
+				rl.quotas = append(rl.quotas, q)
+			case "net":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				p, err := parsePrefix(args[0])
+				if err != nil {
+					return nil, c.Errf("illegal CIDR notation %q", args[0])
+				}
+				q := &quota{kind: quotaNet, net: p}
+				if err := parseRate(c, q, args[1:]); err != nil {
+					return nil, err
+				}
+				rl.quotas = append(rl.quotas, q)
+			case "metadata":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				if !metadata.IsLabel(args[0]) {
+					return nil, c.Errf("invalid metadata label %q", args[0])
+				}
+				q := &quota{kind: quotaMetadata, label: args[0]}
+				if err := parseRate(c, q, args[1:]); err != nil {
+					return nil, err
+				}
+				rl.quotas = append(rl.quotas, q)
+			case "ipv4-prefix-length", "ipv6-prefix-length":
+				option := c.Val()
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				maxLen := 32
+				if option == "ipv6-prefix-length" {
+					maxLen = 128
+				}
+				l, err := strconv.Atoi(args[0])
+				if err != nil || l < 1 || l > maxLen {
+					return nil, c.Errf("invalid %s '%s'", option, args[0])
+				}
+				if maxLen == 32 {
+					ipv4Mask = l
+				} else {
+					ipv6Mask = l
+				}
+			case "exempt":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					p, err := parsePrefix(a)
+					if err != nil {
+						return nil, c.Errf("illegal CIDR notation %q", a)
+					}
+					rl.exempt = append(rl.exempt, p)
+				}
+			case "overloaded":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				f, err := strconv.ParseFloat(args[0], 64)
+				if err != nil || f <= 0 || f > 1 {
+					return nil, c.Errf("invalid overloaded factor '%s'", args[0])
+				}
+				rl.overloaded = f
+			case "max-table-size":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				s, err := strconv.Atoi(args[0])
+				if err != nil || s < 1 {
+					return nil, c.Errf("invalid max-table-size '%s'", args[0])
+				}
+				rl.maxSize = s
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	if len(rl.quotas) == 0 {
+		return nil, c.Err("at least one quota is required")
+	}
+	for _, q := range rl.quotas {
+		q.ipv4Mask, q.ipv6Mask = ipv4Mask, ipv6Mask
+		q.limiter = newLimiter(rl.maxSize)
+	}
+	return rl, nil
+}
+
+// parseRate parses the QPS [BURST] arguments of a quota.
+func parseRate(c *caddy.Controller, q *quota, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return c.ArgErr()
+	}
+	rate, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || rate <= 0 {
+		return c.Errf("invalid rate '%s'", args[0])
+	}
+	q.rate, q.burst = rate, rate
+	if len(args) == 2 {
+		burst, err := strconv.Atoi(args[1])
+		if err != nil || burst < 1 {
+			return c.Errf("invalid burst '%s'", args[1])
+		}
+		q.burst = float64(burst)
+	}
+	if q.burst < 1 {
+		q.burst = 1
+	}
+	return nil
+}
+
+// parsePrefix parses a CIDR or a single address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return p.Masked(), nil
+}
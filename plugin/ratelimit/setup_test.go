@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"github.com/coredns/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input          string
+		shouldErr      bool
+		expectedQuotas int
+	}{
+		{"ratelimit {\n client 10\n}", false, 1},
+		{"ratelimit example.org {\n client 10 20\n net 10.0.0.0/8 100\n net 192.0.2.1 5\n zone 1000\n metadata view/name 50 100\n}", false, 5},
+		{"ratelimit {\n client 0.5\n ipv4-prefix-length 24\n ipv6-prefix-length 64\n exempt 127.0.0.1 ::1 10.0.0.0/8\n overloaded 0.5\n max-table-size 1000\n}", false, 1},
+		// fails
+		{"ratelimit", true, 0},
+		{"ratelimit {\n exempt 10.0.0.0/8\n}", true, 0},
+		{"ratelimit {\n client\n}", true, 0},
+		{"ratelimit {\n client 0\n}", true, 0},
+		{"ratelimit {\n client 10 0\n}", true, 0},
+		{"ratelimit {\n client 10 20 30\n}", true, 0},
+		{"ratelimit {\n net 10.0.0.0/8\n}", true, 0},
+		{"ratelimit {\n net 10.0.0.0/33 10\n}", true, 0},
+		{"ratelimit {\n metadata tenant 10\n}", true, 0},
+		{"ratelimit {\n client 10\n ipv4-prefix-length 33\n}", true, 0},
+		{"ratelimit {\n client 10\n exempt\n}", true, 0},
+		{"ratelimit {\n client 10\n overloaded 2\n}", true, 0},
+		{"ratelimit {\n client 10\n max-table-size 0\n}", true, 0},
+		{"ratelimit {\n qps 10\n}", true, 0},
+		{"ratelimit {\n client 10\n}\nratelimit {\n client 10\n}", true, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		rl, err := ratelimitParse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, tc.input, err)
+			continue
+		}
+		if len(rl.quotas) != tc.expectedQuotas {
+			t.Errorf("Test %d: expected %d quotas, got %d", i, tc.expectedQuotas, len(rl.quotas))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package rewrite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/expression"
+	"github.com/coredns/coredns/request"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+)
+
+// IfCondition separates a rule from the expression that must be true for the rule to apply.
+const IfCondition = "if"
+
+// conditionalRule applies a rule only to the queries for which an expression evaluates to true.
+type conditionalRule struct {
+	Rule
+	prog *vm.Program
+}
+
+// Rewrite rewrites the current request if the condition is true.
+func (rule *conditionalRule) Rewrite(ctx context.Context, state request.Request) (ResponseRules, Result) {
+	result, err := expr.Run(rule.prog, expression.DefaultEnv(ctx, &state))
+	if err != nil {
+		return nil, RewriteIgnored
+	}
+	if b, ok := result.(bool); !ok || !b {
+		return nil, RewriteIgnored
+	}
+	return rule.Rule.Rewrite(ctx, state)
+}
+
+// splitCondition splits args at the first IfCondition, into the rule and the expression. It returns false if
+// there is no condition.
+func splitCondition(args []string) ([]string, string, bool) {
+	for i, a := range args {
+		if i > 0 && strings.ToLower(a) == IfCondition {
+			return args[:i], strings.Join(args[i+1:], " "), true
+		}
+	}
+	return args, "", false
+}
+
+// newConditionalRule returns rule that only applies if the expression cond is true.
+func newConditionalRule(rule Rule, cond string) (Rule, error) {
+	if cond == "" {
+		return nil, fmt.Errorf("missing expression after %q", IfCondition)
+	}
+	prog, err := expr.Compile(cond, expr.Env(expression.DefaultEnv(context.Background(), nil)), expr.AsBool())
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s expression %q: %s", IfCondition, cond, err)
+	}
+	return &conditionalRule{Rule: rule, prog: prog}, nil
+}
@@ -0,0 +1,87 @@
+package rewrite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestNewConditionalRule(t *testing.T) {
+	tests := []struct {
+		args         []string
+		expectedFail bool
+	}{
+		{[]string{"name", "a.com", "b.com", "if", "incidr(client_ip(),", "'10.0.0.0/8')"}, false},
+		{[]string{"continue", "ttl", "a.com", "10", "if", "proto()", "==", "'udp'"}, false},
+		{[]string{"name", "regex", "(.*)\\.a\\.com", "{1}.b.com", "answer", "auto", "IF", "type()", "==", "'A'"}, false},
+		{[]string{"name", "a.com", "b.com", "if"}, true},
+		{[]string{"name", "a.com", "b.com", "if", "client_ip()"}, true},
+		{[]string{"name", "a.com", "b.com", "if", "nope()"}, true},
+		{[]string{"name", "a.com", "if", "true"}, true},
+	}
+	for i, tc := range tests {
+		rule, err := newRule(tc.args...)
+		if failed := err != nil; failed != tc.expectedFail {
+			t.Errorf("Test %d: expected fail=%t, got error %v for %s", i, tc.expectedFail, err, tc.args)
+			continue
+		}
+		if err == nil {
+			if _, ok := rule.(*conditionalRule); !ok {
+				t.Errorf("Test %d: expected a conditional rule, got %T", i, rule)
+			}
+		}
+	}
+}
+
+func TestConditionalRewrite(t *testing.T) {
+	rules := []Rule{}
+	for _, args := range [][]string{
+		{"continue", "name", "a.example.org", "b.example.org", "if", "incidr(client_ip(),", "'10.0.0.0/8')"},
+		{"continue", "name", "c.example.org", "b.example.org", "if", "metadata('geoip/country/code')", "==", "'DE'"},
+		{"continue", "name", "d.example.org", "b.example.org", "if", "transport()", "==", "'dns'", "&&", "proto()", "==", "'tcp'"},
+	} {
+		r, err := newRule(args...)
+		if err != nil {
+			t.Fatalf("Rule %s: %s", args, err)
+		}
+		rules = append(rules, r)
+	}
+	rw := Rewrite{Next: plugin.HandlerFunc(msgPrinter), Rules: rules, RevertPolicy: NoRevertPolicy()}
+
+	withCountry := func(country string) context.Context {
+		ctx := metadata.ContextWithMetadata(context.Background())
+		metadata.SetValueFunc(ctx, "geoip/country/code", func() string { return country })
+		return ctx
+	}
+
+	tests := []struct {
+		ctx      context.Context
+		from     string
+		remoteIP string
+		tcp      bool
+		to       string
+	}{
+		{context.Background(), "a.example.org.", "10.1.2.3", false, "b.example.org."},
+		{context.Background(), "a.example.org.", "192.168.1.1", false, "a.example.org."},
+		{withCountry("DE"), "c.example.org.", "192.168.1.1", false, "b.example.org."},
+		{withCountry("NL"), "c.example.org.", "192.168.1.1", false, "c.example.org."},
+		{context.Background(), "c.example.org.", "192.168.1.1", false, "c.example.org."},
+		{context.Background(), "d.example.org.", "192.168.1.1", true, "b.example.org."},
+		{context.Background(), "d.example.org.", "192.168.1.1", false, "d.example.org."},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.from, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.remoteIP, TCP: tc.tcp})
+		rw.ServeDNS(tc.ctx, rec, m)
+		if name := rec.Msg.Question[0].Name; name != tc.to {
+			t.Errorf("Test %d: expected name %s, got %s", i, tc.to, name)
+		}
+	}
+}
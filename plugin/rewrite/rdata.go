@@ -0,0 +1,286 @@
+package rewrite
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Actions of the rdata rule.
+const (
+	// AddressAction translates the addresses of A and AAAA records from one network to another
+	AddressAction = "address"
+	// TargetAction rewrites the target names of records by regex pattern
+	TargetAction = "target"
+	// DropAction removes records from the response
+	DropAction = "drop"
+)
+
+// rdataRule is a rule that rewrites the records in the response, for queries with a matching name.
+type rdataRule struct {
+	nextAction string
+	match      func(name string) bool
+	response   ResponseRule
+}
+
+// Rewrite rewrites the current request when the name matches.
+func (rule *rdataRule) Rewrite(ctx context.Context, state request.Request) (ResponseRules, Result) {
+	if rule.match(state.Name()) {
+		return ResponseRules{rule.response}, RewriteDone
+	}
+	return nil, RewriteIgnored
+}
+
+// Mode returns the processing nextAction
+func (rule *rdataRule) Mode() string { return rule.nextAction }
+
+// addressResponseRule translates the addresses in from to the same host in to, like 1:1 NAT.
+type addressResponseRule struct {
+	from, to netip.Prefix
+}
+
+func (r *addressResponseRule) RewriteResponse(rr dns.RR) {
+	switch rr := rr.(type) {
+	case *dns.A:
+		addr, ok := netip.AddrFromSlice(rr.A.To4())
+		if !ok {
+			return
+		}
+		if addr, ok = r.translate(addr); ok {
+			rr.A = addr.AsSlice()
+		}
+	case *dns.AAAA:
+		addr, ok := netip.AddrFromSlice(rr.AAAA)
+		// An IPv4-mapped address is left alone, as its translation must still fit in the AAAA record.
+		if !ok || addr.Is4In6() {
+			return
+		}
+		if addr, ok = r.translate(addr); ok {
+			rr.AAAA = addr.AsSlice()
+		}
+	}
+}
+
+// translate replaces the network bits of addr by the ones of r.to, if addr is in r.from.
+func (r *addressResponseRule) translate(addr netip.Addr) (netip.Addr, bool) {
+	if !r.from.Contains(addr) {
+		return netip.Addr{}, false
+	}
+	host, network := addr.AsSlice(), r.to.Addr().AsSlice()
+	bits := r.to.Bits()
+	for i := range host {
+		switch {
+		case bits >= 8:
+			host[i] = network[i]
+			bits -= 8
+		case bits > 0:
+			mask := byte(0xff) << (8 - bits)
+			host[i] = network[i]&mask | host[i]&^mask
+			bits = 0
+		}
+	}
+	addr, _ = netip.AddrFromSlice(host)
+	return addr, true
+}
+
+// targetResponseRule rewrites the target names of the records of a type.
+type targetResponseRule struct {
+	rrtype uint16
+	stringRewriter
+}
+
+func (r *targetResponseRule) RewriteResponse(rr dns.RR) {
+	if rr.Header().Rrtype != r.rrtype {
+		return
+	}
+	value := getRecordValueForRewrite(rr)
+	new := dns.Fqdn(r.rewriteString(value))
+	if new == value {
+		return
+	}
+	if _, ok := dns.IsDomainName(new); !ok {
+		return
+	}
+	setRewrittenRecordValue(rr, new)
+}
+
+// dropResponseRule removes the records of a type whose data matches a pattern, or the A and AAAA records with an
+// address in one of the networks.
+type dropResponseRule struct {
+	rrtype   uint16 // dns.TypeANY for all types
+	pattern  *regexp.Regexp
+	networks []netip.Prefix
+}
+
+// RewriteResponse does nothing, records are removed by DropResponse.
+func (r *dropResponseRule) RewriteResponse(rr dns.RR) {}
+
+func (r *dropResponseRule) DropResponse(rr dns.RR) bool {
+	if rr.Header().Rrtype == dns.TypeOPT {
+		return false
+	}
+	if r.networks != nil {
+		var ip []byte
+		switch rr := rr.(type) {
+		case *dns.A:
+			ip = rr.A
+		case *dns.AAAA:
+			ip = rr.AAAA
+		default:
+			return false
+		}
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			return false
+		}
+		for _, n := range r.networks {
+			if n.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if r.rrtype != dns.TypeANY && rr.Header().Rrtype != r.rrtype {
+		return false
+	}
+	if r.pattern == nil {
+		return true
+	}
+	rdata := strings.TrimPrefix(rr.String(), rr.Header().String())
+	return r.pattern.MatchString(rdata)
+}
+
+// targetTypes are the record types whose target can be rewritten.
+var targetTypes = map[uint16]bool{
+	dns.TypeCNAME: true,
+	dns.TypeDNAME: true,
+	dns.TypeMX:    true,
+	dns.TypeNAPTR: true,
+	dns.TypeNS:    true,
+	dns.TypePTR:   true,
+	dns.TypeSRV:   true,
+}
+
+// newRdataRule creates a rule that rewrites the records in responses to queries with a matching name.
+func newRdataRule(nextAction string, args ...string) (Rule, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("too few (%d) arguments for a rdata rule", len(args))
+	}
+	matchType := ExactMatch
+	switch strings.ToLower(args[0]) {
+	case ExactMatch, PrefixMatch, SuffixMatch, SubstringMatch, RegexMatch:
+		matchType = strings.ToLower(args[0])
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return nil, fmt.Errorf("too few (%d) arguments for a rdata rule", len(args))
+	}
+
+	rule := &rdataRule{nextAction: nextAction}
+	switch matchType {
+	case ExactMatch:
+		from := plugin.Name(args[0]).Normalize()
+		rule.match = func(name string) bool { return name == from }
+	case PrefixMatch:
+		prefix := plugin.Name(args[0]).Normalize()
+		rule.match = func(name string) bool { return strings.HasPrefix(name, prefix) }
+	case SuffixMatch:
+		suffix := plugin.Name(args[0]).Normalize()
+		rule.match = func(name string) bool { return strings.HasSuffix(name, suffix) }
+	case SubstringMatch:
+		substring := plugin.Name(args[0]).Normalize()
+		rule.match = func(name string) bool { return strings.Contains(name, substring) }
+	case RegexMatch:
+		pattern, err := regexp.Compile(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern in a rdata rule: %s", args[0])
+		}
+		rule.match = pattern.MatchString
+	}
+
+	response, err := newRdataResponseRule(args[1], args[2:])
+	if err != nil {
+		return nil, err
+	}
+	rule.response = response
+	return rule, nil
+}
+
+// newRdataResponseRule creates the response rule of the action with args.
+func newRdataResponseRule(action string, args []string) (ResponseRule, error) {
+	switch strings.ToLower(action) {
+	case AddressAction:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s action requires exactly two networks", AddressAction)
+		}
+		from, err := netip.ParsePrefix(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid network '%s' for a rdata rule", args[0])
+		}
+		to, err := netip.ParsePrefix(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid network '%s' for a rdata rule", args[1])
+		}
+		if from.Addr().Is4() != to.Addr().Is4() || from.Bits() != to.Bits() {
+			return nil, fmt.Errorf("networks %s and %s must be of the same family and size", from, to)
+		}
+		return &addressResponseRule{from: from.Masked(), to: to.Masked()}, nil
+	case TargetAction:
+		if len(args) != 3 {
+			return nil, fmt.Errorf("%s action requires a type, a pattern and a replacement", TargetAction)
+		}
+		rrtype, ok := dns.StringToType[strings.ToUpper(args[0])]
+		if !ok || !targetTypes[rrtype] {
+			return nil, fmt.Errorf("invalid type '%s' for a %s action", args[0], TargetAction)
+		}
+		pattern, err := isValidRegexPattern(args[1], args[2])
+		if err != nil {
+			return nil, err
+		}
+		return &targetResponseRule{rrtype: rrtype, stringRewriter: newStringRewriter(pattern, args[2])}, nil
+	case DropAction:
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s action requires a type", DropAction)
+		}
+		if strings.ToLower(args[0]) == AddressAction {
+			if len(args) == 1 {
+				return nil, fmt.Errorf("%s action requires at least one network", DropAction)
+			}
+			r := &dropResponseRule{rrtype: dns.TypeANY}
+			for _, a := range args[1:] {
+				n, err := netip.ParsePrefix(a)
+				if err != nil {
+					return nil, fmt.Errorf("invalid network '%s' for a rdata rule", a)
+				}
+				r.networks = append(r.networks, n.Masked())
+			}
+			return r, nil
+		}
+		if len(args) > 2 {
+			return nil, fmt.Errorf("too many arguments for a %s action", DropAction)
+		}
+		rrtype, ok := dns.StringToType[strings.ToUpper(args[0])]
+		if !ok {
+			return nil, fmt.Errorf("invalid type '%s' for a %s action", args[0], DropAction)
+		}
+		r := &dropResponseRule{rrtype: rrtype}
+		if len(args) == 2 {
+			pattern, err := regexp.Compile(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex pattern in a rdata rule: %s", args[1])
+			}
+			r.pattern = pattern
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("invalid action '%s' for a rdata rule", action)
+	}
+}
@@ -0,0 +1,162 @@
+package rewrite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestNewRdataRule(t *testing.T) {
+	tests := []struct {
+		args         []string
+		expectedFail bool
+	}{
+		{[]string{"example.org", "address", "10.0.0.0/24", "192.168.0.0/24"}, false},
+		{[]string{"suffix", "example.org", "address", "2001:db8::/64", "2001:db8:1::/64"}, false},
+		{[]string{"regex", `.*\.example\.org`, "target", "CNAME", `(.*)\.internal\.`, "{1}.example.org."}, false},
+		{[]string{"example.org", "target", "srv", `(.*)\.internal\.`, "{1}.example.org."}, false},
+		{[]string{"example.org", "drop", "TXT"}, false},
+		{[]string{"example.org", "drop", "ANY", "^v=spf1"}, false},
+		{[]string{"example.org", "drop", "address", "10.0.0.0/8", "fd00::/8"}, false},
+		{[]string{"example.org"}, true},
+		{[]string{"example.org", "address", "10.0.0.0/24"}, true},
+		{[]string{"example.org", "address", "10.0.0.0/24", "192.168.0.0/16"}, true},
+		{[]string{"example.org", "address", "10.0.0.0/24", "2001:db8::/24"}, true},
+		{[]string{"example.org", "address", "10.0.0.0/24", "bad"}, true},
+		{[]string{"example.org", "target", "A", "(.*)", "{1}"}, true},
+		{[]string{"example.org", "target", "CNAME", "(.*)", "{1}.{2}"}, true},
+		{[]string{"example.org", "drop"}, true},
+		{[]string{"example.org", "drop", "FOO"}, true},
+		{[]string{"example.org", "drop", "address"}, true},
+		{[]string{"example.org", "drop", "address", "10.0.0.0/33"}, true},
+		{[]string{"example.org", "drop", "TXT", "(", ")"}, true},
+		{[]string{"regex", "(", "drop", "TXT"}, true},
+		{[]string{"example.org", "replace", "TXT"}, true},
+	}
+	for i, tc := range tests {
+		_, err := newRule(append([]string{"continue", "rdata"}, tc.args...)...)
+		if failed := err != nil; failed != tc.expectedFail {
+			t.Errorf("Test %d: expected fail=%t, got error %v for %s", i, tc.expectedFail, err, tc.args)
+		}
+	}
+}
+
+func rdataHandler() plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{
+			test.CNAME("www.example.org. 300 IN CNAME web.internal."),
+			test.A("web.internal. 300 IN A 10.0.0.17"),
+			test.A("web.internal. 300 IN A 10.1.0.1"),
+			test.AAAA("web.internal. 300 IN AAAA 2001:db8::17"),
+			test.TXT(`web.internal. 300 IN TXT "v=spf1 -all"`),
+			test.MX("web.internal. 300 IN MX 10 mail.internal."),
+		}
+		m.Extra = []dns.RR{test.A("mail.internal. 300 IN A 10.0.0.25")}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestRdataRewrite(t *testing.T) {
+	rules := []Rule{}
+	for _, args := range [][]string{
+		{"continue", "rdata", "suffix", "example.org", "address", "10.0.0.0/24", "192.168.1.0/24"},
+		{"continue", "rdata", "suffix", "example.org", "address", "2001:db8::/64", "2001:db8:ff::/64"},
+		{"continue", "rdata", "suffix", "example.org", "target", "CNAME", `(.*)\.internal\.`, "{1}.example.org."},
+		{"continue", "rdata", "suffix", "example.org", "target", "MX", `(.*)\.internal\.`, "{1}.example.org"},
+		{"continue", "rdata", "suffix", "example.org", "drop", "TXT", "spf1"},
+		{"continue", "rdata", "suffix", "example.org", "drop", "address", "10.1.0.0/16"},
+		{"continue", "rdata", "example.net", "drop", "ANY"},
+	} {
+		r, err := newRule(args...)
+		if err != nil {
+			t.Fatalf("Rule %s: %s", args, err)
+		}
+		rules = append(rules, r)
+	}
+	rw := Rewrite{Next: rdataHandler(), Rules: rules, RevertPolicy: NoRestorePolicy()}
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rw.ServeDNS(context.TODO(), rec, m)
+
+	expected := []dns.RR{
+		test.CNAME("www.example.org. 300 IN CNAME web.example.org."),
+		test.A("web.internal. 300 IN A 192.168.1.17"),
+		test.AAAA("web.internal. 300 IN AAAA 2001:db8:ff::17"),
+		test.MX("web.internal. 300 IN MX 10 mail.example.org."),
+	}
+	if err := test.Section(test.Case{Answer: expected}, test.Answer, rec.Msg.Answer); err != nil {
+		t.Error(err)
+	}
+	if err := test.Section(test.Case{Extra: []dns.RR{test.A("mail.internal. 300 IN A 192.168.1.25")}}, test.Extra, rec.Msg.Extra); err != nil {
+		t.Error(err)
+	}
+
+	// Other names are not rewritten.
+	m = new(dns.Msg)
+	m.SetQuestion("www.example.com.", dns.TypeA)
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	rw.ServeDNS(context.TODO(), rec, m)
+	if len(rec.Msg.Answer) != 6 || rec.Msg.Answer[1].(*dns.A).A.String() != "10.0.0.17" {
+		t.Errorf("Expected the answer to be unchanged, got %v", rec.Msg.Answer)
+	}
+
+	// All records are dropped.
+	m = new(dns.Msg)
+	m.SetQuestion("example.net.", dns.TypeA)
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	rw.ServeDNS(context.TODO(), rec, m)
+	if len(rec.Msg.Answer) != 0 || len(rec.Msg.Extra) != 0 {
+		t.Errorf("Expected all records to be dropped, got %v %v", rec.Msg.Answer, rec.Msg.Extra)
+	}
+}
+
+func TestAddressTranslate(t *testing.T) {
+	rule, err := newRdataResponseRule("address", []string{"10.0.0.0/20", "192.168.16.0/20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		from, to string
+	}{
+		{"10.0.0.1", "192.168.16.1"},
+		{"10.0.15.254", "192.168.31.254"},
+		{"10.0.16.1", "10.0.16.1"},
+		{"11.0.0.1", "11.0.0.1"},
+	}
+	for i, tc := range tests {
+		rr := test.A("a.example.org. 300 IN A " + tc.from)
+		rule.RewriteResponse(rr)
+		if a := rr.A.String(); a != tc.to {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.to, a)
+		}
+	}
+}
+
+func TestAddressTranslateMapped(t *testing.T) {
+	rule, err := newRdataResponseRule("address", []string{"10.0.0.0/24", "192.168.1.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An IPv4-mapped address in an AAAA record is not translated by an IPv4 mapping.
+	m := new(dns.Msg)
+	m.SetQuestion("a.example.org.", dns.TypeAAAA)
+	m.Answer = []dns.RR{test.AAAA("a.example.org. 300 IN AAAA ::ffff:10.0.0.5")}
+	rule.RewriteResponse(m.Answer[0])
+
+	if a := m.Answer[0].(*dns.AAAA).AAAA; len(a) != 16 || a.String() != "10.0.0.5" {
+		t.Errorf("Expected the mapped address to be unchanged, got %s", a)
+	}
+	if _, err := m.Pack(); err != nil {
+		t.Errorf("Expected the response to pack, got %s", err)
+	}
+}
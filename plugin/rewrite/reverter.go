@@ -44,6 +44,13 @@ type ResponseRule interface {
 	RewriteResponse(rr dns.RR)
 }
 
+// ResponseFilter is a ResponseRule that also removes records from the response.
+type ResponseFilter interface {
+	ResponseRule
+	// DropResponse returns true if rr must be removed from the response.
+	DropResponse(rr dns.RR) bool
+}
+
 // ResponseRules describes an ordered list of response rules to apply
 // after a name rewrite
 type ResponseRules = []ResponseRule
@@ -76,6 +83,9 @@ func (r *ResponseReverter) WriteMsg(res1 *dns.Msg) error {
 		res.Question[0] = r.originalQuestion
 	}
 	if len(r.ResponseRules) > 0 {
+		res.Ns = r.filterResourceRecords(res.Ns)
+		res.Answer = r.filterResourceRecords(res.Answer)
+		res.Extra = r.filterResourceRecords(res.Extra)
 		for _, rr := range res.Ns {
 			r.rewriteResourceRecord(res, rr)
 		}
@@ -95,6 +105,35 @@ func (r *ResponseReverter) rewriteResourceRecord(res *dns.Msg, rr dns.RR) {
 	}
 }
 
+// filterResourceRecords removes the records that a ResponseFilter drops. It is applied before the records are
+// rewritten.
+func (r *ResponseReverter) filterResourceRecords(rrs []dns.RR) []dns.RR {
+	var filters []ResponseFilter
+	for _, rule := range r.ResponseRules {
+		if f, ok := rule.(ResponseFilter); ok {
+			filters = append(filters, f)
+		}
+	}
+	if len(filters) == 0 {
+		return rrs
+	}
+
+	out := rrs[:0]
+	for _, rr := range rrs {
+		drop := false
+		for _, f := range filters {
+			if f.DropResponse(rr) {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
 // Write is a wrapper that records the size of the message that gets written.
 func (r *ResponseReverter) Write(buf []byte) (int, error) {
 	n, err := r.ResponseWriter.Write(buf)
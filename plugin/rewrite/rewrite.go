@@ -89,6 +89,15 @@ type Rule interface {
 }
 
 func newRule(args ...string) (Rule, error) {
+	args, cond, ok := splitCondition(args)
+	rule, err := newUnconditionalRule(args...)
+	if err != nil || !ok {
+		return rule, err
+	}
+	return newConditionalRule(rule, cond)
+}
+
+func newUnconditionalRule(args ...string) (Rule, error) {
 	if len(args) == 0 {
 		return nil, fmt.Errorf("no rule type specified for rewrite")
 	}
@@ -139,6 +148,8 @@ func newRule(args ...string) (Rule, error) {
 		return newEdns0Rule(mode, args[startArg:]...)
 	case "ttl":
 		return newTTLRule(mode, args[startArg:]...)
+	case "rdata":
+		return newRdataRule(mode, args[startArg:]...)
 	default:
 		return nil, fmt.Errorf("invalid rule type %q", args[0])
 	}
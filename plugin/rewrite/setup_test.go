@@ -31,6 +31,10 @@ func TestParse(t *testing.T) {
     answer name bar foo
     name regex foo bar
 }`, true, "must begin with a name rule"},
+		{`rewrite continue {
+    rdata suffix example.org target CNAME (.*)\.internal\. {1}.example.org.
+}`, false, ""},
+		{`rewrite continue rdata example.org address 10.0.0.0/24 192.168.0.0/16`, true, "same family and size"},
 		{`rewrite stop`, true, ""},
 		{`rewrite continue`, true, ""},
 	}
@@ -0,0 +1,16 @@
+package rpz
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// HitsCount is the number of queries that triggered a policy rule.
+var HitsCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rpz",
+	Name:      "hits_total",
+	Help:      "Counter of queries that triggered a response policy rule.",
+}, []string{"server", "policy", "trigger", "action"})
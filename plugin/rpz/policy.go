@@ -0,0 +1,342 @@
+package rpz
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/file/tree"
+
+	"github.com/miekg/dns"
+)
+
+// action is what is done with a query that triggers a rule.
+type action int
+
+const (
+	// actionNXDOMAIN answers with NXDOMAIN, encoded as "CNAME .".
+	actionNXDOMAIN action = iota
+	// actionNODATA answers with an empty NOERROR reply, encoded as "CNAME *.".
+	actionNODATA
+	// actionPassthru leaves the query alone and stops looking for other rules, encoded as "CNAME rpz-passthru.".
+	actionPassthru
+	// actionDrop doesn't answer the query at all, encoded as "CNAME rpz-drop.".
+	actionDrop
+	// actionLocalData answers with the records of the rule, for a CNAME the target is resolved.
+	actionLocalData
+)
+
+func (a action) String() string {
+	switch a {
+	case actionNXDOMAIN:
+		return "nxdomain"
+	case actionNODATA:
+		return "nodata"
+	case actionPassthru:
+		return "passthru"
+	case actionDrop:
+		return "drop"
+	case actionLocalData:
+		return "local-data"
+	}
+	return "unknown"
+}
+
+// The kinds of triggers, as used in metrics and metadata.
+const (
+	triggerClientIP = "client-ip"
+	triggerQName    = "qname"
+	triggerIP       = "ip"
+	triggerNSDName  = "nsdname"
+)
+
+// rule is a single policy from a policy zone.
+type rule struct {
+	owner  string // owner name in the policy zone, which encodes the trigger
+	action action
+	data   []dns.RR // local data, for actionLocalData
+}
+
+// nameTrigger holds the rules triggered by a domain name.
+type nameTrigger struct {
+	exact    map[string]*rule
+	wildcard map[string]*rule // keyed by the name without the leading "*."
+}
+
+func newNameTrigger() nameTrigger {
+	return nameTrigger{exact: map[string]*rule{}, wildcard: map[string]*rule{}}
+}
+
+func (t nameTrigger) insert(name string, r *rule) {
+	if strings.HasPrefix(name, "*.") {
+		t.wildcard[name[2:]] = r
+		return
+	}
+	t.exact[name] = r
+}
+
+// match returns the rule for name: an exact match, or else the wildcard rule for the closest ancestor.
+// A wildcard never matches the name it is rooted at.
+func (t nameTrigger) match(name string) *rule {
+	if r, ok := t.exact[name]; ok {
+		return r
+	}
+	if len(t.wildcard) == 0 {
+		return nil
+	}
+	for off, end := dns.NextLabel(name, 0); !end; off, end = dns.NextLabel(name, off) {
+		if r, ok := t.wildcard[name[off:]]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// ipTrigger holds the rules triggered by an address, keyed by prefix.
+type ipTrigger struct {
+	rules map[netip.Prefix]*rule
+	bits  []int // the prefix lengths present in rules, longest first
+}
+
+func newIPTrigger() ipTrigger {
+	return ipTrigger{rules: map[netip.Prefix]*rule{}}
+}
+
+func (t *ipTrigger) insert(p netip.Prefix, r *rule) {
+	if _, ok := t.rules[p]; !ok {
+		i := sort.Search(len(t.bits), func(i int) bool { return t.bits[i] <= p.Bits() })
+		if i == len(t.bits) || t.bits[i] != p.Bits() {
+			t.bits = append(t.bits, 0)
+			copy(t.bits[i+1:], t.bits[i:])
+			t.bits[i] = p.Bits()
+		}
+	}
+	t.rules[p] = r
+}
+
+// match returns the rule with the longest prefix that contains addr.
+func (t ipTrigger) match(addr netip.Addr) *rule {
+	if len(t.rules) == 0 || !addr.IsValid() {
+		return nil
+	}
+	addr = addr.Unmap()
+	for _, bits := range t.bits {
+		if bits > addr.BitLen() {
+			continue
+		}
+		p, _ := addr.Prefix(bits)
+		if r, ok := t.rules[p]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// index holds the rules of a policy zone, by trigger.
+type index struct {
+	tree *tree.Tree // the zone contents this index was built from
+	soa  *dns.SOA
+
+	clientIP ipTrigger
+	qname    nameTrigger
+	ip       ipTrigger
+	nsdname  nameTrigger
+}
+
+// policyZone is a response policy zone. Its contents are loaded and kept up to date by file.Zone, either
+// from a file or by zone transfers.
+type policyZone struct {
+	*file.Zone
+	name string
+
+	idx atomic.Pointer[index]
+	mu  sync.Mutex // serializes rebuilding idx
+}
+
+func newPolicyZone(z *file.Zone, name string) *policyZone {
+	return &policyZone{Zone: z, name: name}
+}
+
+// index returns the rules of the zone. When the contents of the zone changed, because it was reloaded or
+// transferred, the index is rebuilt. While that happens, other callers get the previous index.
+func (p *policyZone) index() *index {
+	p.RLock()
+	t := p.Tree
+	p.RUnlock()
+
+	idx := p.idx.Load()
+	if idx != nil && idx.tree == t {
+		return idx
+	}
+	if idx != nil && !p.mu.TryLock() {
+		return idx
+	}
+	if idx == nil {
+		p.mu.Lock()
+	}
+	defer p.mu.Unlock()
+
+	if idx = p.idx.Load(); idx != nil && idx.tree == t {
+		return idx
+	}
+	idx = p.build()
+	p.idx.Store(idx)
+	return idx
+}
+
+// build creates the index from the current contents of the zone.
+func (p *policyZone) build() *index {
+	p.RLock()
+	defer p.RUnlock()
+
+	idx := &index{
+		tree:     p.Tree,
+		soa:      p.Apex.SOA,
+		clientIP: newIPTrigger(),
+		qname:    newNameTrigger(),
+		ip:       newIPTrigger(),
+		nsdname:  newNameTrigger(),
+	}
+
+	skipped := 0
+	for _, e := range p.Tree.All() {
+		if err := idx.insert(p.name, e.Name(), e.All()); err != nil {
+			skipped++
+			log.Debugf("Skipping %s in policy zone %s: %s", e.Name(), p.name, err)
+		}
+	}
+	if skipped > 0 {
+		log.Warningf("Skipped %d unsupported or malformed rules in policy zone %s", skipped, p.name)
+	}
+	return idx
+}
+
+// insert adds the rule for the records rrs at owner to the index.
+func (idx *index) insert(origin, owner string, rrs []dns.RR) error {
+	if !dns.IsSubDomain(origin, owner) || owner == origin {
+		return nil
+	}
+	name := owner[:len(owner)-len(origin)] // keeps the trailing dot
+
+	r, err := newRule(owner, rrs)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".rpz-client-ip."):
+		pfx, err := parsePrefix(strings.TrimSuffix(name, ".rpz-client-ip."))
+		if err != nil {
+			return err
+		}
+		idx.clientIP.insert(pfx, r)
+	case strings.HasSuffix(name, ".rpz-ip."):
+		pfx, err := parsePrefix(strings.TrimSuffix(name, ".rpz-ip."))
+		if err != nil {
+			return err
+		}
+		idx.ip.insert(pfx, r)
+	case strings.HasSuffix(name, ".rpz-nsdname."):
+		idx.nsdname.insert(dns.Fqdn(strings.TrimSuffix(name, ".rpz-nsdname.")), r)
+	case strings.HasSuffix(name, ".rpz-nsip."):
+		return fmt.Errorf("NSIP triggers are not supported")
+	default:
+		if strings.Contains(name, ".rpz-") {
+			return fmt.Errorf("unknown trigger")
+		}
+		idx.qname.insert(name, r)
+	}
+	return nil
+}
+
+// newRule returns the rule encoded in the records rrs at owner.
+func newRule(owner string, rrs []dns.RR) (*rule, error) {
+	for _, rr := range rrs {
+		cname, ok := rr.(*dns.CNAME)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(cname.Target) {
+		case ".":
+			return &rule{owner: owner, action: actionNXDOMAIN}, nil
+		case "*.":
+			return &rule{owner: owner, action: actionNODATA}, nil
+		case "rpz-passthru.":
+			return &rule{owner: owner, action: actionPassthru}, nil
+		case "rpz-drop.":
+			return &rule{owner: owner, action: actionDrop}, nil
+		case "rpz-tcp-only.":
+			return nil, fmt.Errorf("the TCP-only action is not supported")
+		}
+		return &rule{owner: owner, action: actionLocalData, data: []dns.RR{cname}}, nil
+	}
+
+	data := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		switch rr.Header().Rrtype {
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNS:
+			continue
+		}
+		data = append(data, rr)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no action or local data")
+	}
+	return &rule{owner: owner, action: actionLocalData, data: data}, nil
+}
+
+// parsePrefix parses the encoding of a prefix in the owner name of an IP trigger: the prefix length followed
+// by the address in reverse order, e.g. "24.0.2.0.192" for 192.0.2.0/24, or "48.zz.db8.2001" for
+// 2001:db8::/48, where "zz" stands for the longest run of zero groups.
+func parsePrefix(s string) (netip.Prefix, error) {
+	labels := dns.SplitDomainName(s)
+	if len(labels) < 2 {
+		return netip.Prefix{}, fmt.Errorf("invalid address trigger: %s", s)
+	}
+	bits, err := strconv.Atoi(labels[0])
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix length in address trigger: %s", s)
+	}
+
+	parts := labels[1:]
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+
+	var addr netip.Addr
+	if len(parts) == 4 && !strings.Contains(s, "zz") {
+		addr, err = netip.ParseAddr(strings.Join(parts, "."))
+	} else {
+		addr, err = netip.ParseAddr(expandZZ(strings.Join(parts, ":")))
+	}
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address in address trigger: %s", s)
+	}
+
+	p, err := addr.Prefix(bits)
+	if err != nil || bits < 1 {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix length in address trigger: %s", s)
+	}
+	if p.Addr() != addr {
+		return netip.Prefix{}, fmt.Errorf("address trigger has host bits set: %s", s)
+	}
+	return p, nil
+}
+
+// expandZZ replaces the "zz" group in s with the empty group it stands for.
+func expandZZ(s string) string {
+	switch {
+	case s == "zz":
+		return "::"
+	case strings.HasPrefix(s, "zz:"):
+		return ":" + s[2:]
+	case strings.HasSuffix(s, ":zz"):
+		return s[:len(s)-2] + ":"
+	}
+	return strings.Replace(s, ":zz:", "::", 1)
+}
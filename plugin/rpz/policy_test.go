@@ -0,0 +1,130 @@
+package rpz
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in        string
+		expected  string
+		shouldErr bool
+	}{
+		{"32.1.0.0.10", "10.0.0.1/32", false},
+		{"24.0.2.0.192", "192.0.2.0/24", false},
+		{"128.1.zz.db8.2001", "2001:db8::1/128", false},
+		{"48.zz.db8.2001", "2001:db8::/48", false},
+		{"128.1.zz", "::1/128", false},
+		{"64.zz.2.1.db8.2001", "2001:db8:1:2::/64", false},
+		{"24.1.2.0.192", "", true}, // host bits set
+		{"33.1.0.0.10", "", true},
+		{"0.0.0.0.0", "", true},
+		{"x.1.0.0.10", "", true},
+		{"32.1.0.10", "", true},
+		{"32", "", true},
+	}
+	for i, tc := range tests {
+		p, err := parsePrefix(tc.in)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error for %s, got %s", i, tc.in, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error for %s, got %s", i, tc.in, err)
+			continue
+		}
+		if p.String() != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, p)
+		}
+	}
+}
+
+func TestNameTrigger(t *testing.T) {
+	nt := newNameTrigger()
+	exact, wild, deeper := &rule{owner: "exact"}, &rule{owner: "wild"}, &rule{owner: "deeper"}
+	nt.insert("example.com.", exact)
+	nt.insert("*.example.com.", wild)
+	nt.insert("*.a.example.com.", deeper)
+
+	tests := []struct {
+		name     string
+		expected *rule
+	}{
+		{"example.com.", exact},
+		{"www.example.com.", wild},
+		{"a.example.com.", wild},
+		{"b.a.example.com.", deeper},
+		{"example.net.", nil},
+		{"com.", nil},
+	}
+	for i, tc := range tests {
+		if r := nt.match(tc.name); r != tc.expected {
+			t.Errorf("Test %d: expected %v for %s, got %v", i, tc.expected, tc.name, r)
+		}
+	}
+}
+
+func TestIPTrigger(t *testing.T) {
+	it := newIPTrigger()
+	wide, narrow, v6 := &rule{owner: "wide"}, &rule{owner: "narrow"}, &rule{owner: "v6"}
+	it.insert(netip.MustParsePrefix("10.0.0.0/8"), wide)
+	it.insert(netip.MustParsePrefix("10.1.0.0/16"), narrow)
+	it.insert(netip.MustParsePrefix("2001:db8::/32"), v6)
+
+	tests := []struct {
+		addr     string
+		expected *rule
+	}{
+		{"10.2.3.4", wide},
+		{"10.1.3.4", narrow},
+		{"::ffff:10.1.3.4", narrow},
+		{"11.0.0.1", nil},
+		{"2001:db8::1", v6},
+		{"2001:db9::1", nil},
+	}
+	for i, tc := range tests {
+		if r := it.match(netip.MustParseAddr(tc.addr)); r != tc.expected {
+			t.Errorf("Test %d: expected %v for %s, got %v", i, tc.expected, tc.addr, r)
+		}
+	}
+}
+
+func TestNewRule(t *testing.T) {
+	tests := []struct {
+		rrs       []dns.RR
+		action    action
+		shouldErr bool
+	}{
+		{[]dns.RR{test.CNAME("a.rpz. 60 IN CNAME .")}, actionNXDOMAIN, false},
+		{[]dns.RR{test.CNAME("a.rpz. 60 IN CNAME *.")}, actionNODATA, false},
+		{[]dns.RR{test.CNAME("a.rpz. 60 IN CNAME rpz-passthru.")}, actionPassthru, false},
+		{[]dns.RR{test.CNAME("a.rpz. 60 IN CNAME rpz-drop.")}, actionDrop, false},
+		{[]dns.RR{test.CNAME("a.rpz. 60 IN CNAME example.net.")}, actionLocalData, false},
+		{[]dns.RR{test.A("a.rpz. 60 IN A 192.0.2.1")}, actionLocalData, false},
+		{[]dns.RR{test.CNAME("a.rpz. 60 IN CNAME rpz-tcp-only.")}, 0, true},
+		{[]dns.RR{test.NS("a.rpz. 60 IN NS ns.example.net.")}, 0, true},
+	}
+	for i, tc := range tests {
+		r, err := newRule("a.rpz.", tc.rrs)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if r.action != tc.action {
+			t.Errorf("Test %d: expected action %s, got %s", i, tc.action, r.action)
+		}
+	}
+}
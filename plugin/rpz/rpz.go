@@ -0,0 +1,220 @@
+// Package rpz implements Response Policy Zones (RPZ).
+package rpz
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// RPZ rewrites the responses to queries that trigger a rule in one of its policy zones.
+type RPZ struct {
+	Next  plugin.Handler
+	Zones []string // the zones the policies apply to
+
+	policies []*policyZone // in order of precedence
+	upstream *upstream.Upstream
+}
+
+// hit is a rule that is triggered by a query.
+type hit struct {
+	*rule
+	policy  *policyZone
+	soa     *dns.SOA
+	trigger string
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (r *RPZ) ServeDNS(ctx context.Context, w dns.ResponseWriter, m *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: m}
+	if plugin.Zones(r.Zones).Matches(state.Name()) == "" {
+		return plugin.NextOrFailure(r.Name(), r.Next, ctx, w, m)
+	}
+
+	// Policy zones are checked in order, the first rule triggered wins. The query is passed on to the next
+	// plugin when the first zone with response triggers is reached, and its response is kept for the zones
+	// after it.
+	var (
+		res      *dns.Msg
+		resolved bool
+		rcode    int
+		err      error
+	)
+	for _, p := range r.policies {
+		idx := p.index()
+		if h := matchQuery(state, p, idx); h != nil {
+			return r.apply(ctx, state, h, res)
+		}
+		if !idx.hasResponseTriggers() {
+			continue
+		}
+		if !resolved {
+			nw := nonwriter.New(w)
+			rcode, err = plugin.NextOrFailure(r.Name(), r.Next, ctx, nw, m)
+			res, resolved = nw.Msg, true
+		}
+		if res == nil {
+			continue
+		}
+		if h := matchResponse(res, p, idx); h != nil {
+			return r.apply(ctx, state, h, res)
+		}
+	}
+
+	if !resolved {
+		return plugin.NextOrFailure(r.Name(), r.Next, ctx, w, m)
+	}
+	if res != nil {
+		w.WriteMsg(res)
+	}
+	return rcode, err
+}
+
+// Name implements the plugin.Handler interface.
+func (r *RPZ) Name() string { return "rpz" }
+
+// matchQuery returns the rule of policy zone p triggered by the client address or the query name. Client
+// address rules take precedence over query name rules.
+func matchQuery(state request.Request, p *policyZone, idx *index) *hit {
+	client, _ := netip.ParseAddr(stripZone(state.IP()))
+	if ru := idx.clientIP.match(client); ru != nil {
+		return &hit{rule: ru, policy: p, soa: idx.soa, trigger: triggerClientIP}
+	}
+	if ru := idx.qname.match(state.Name()); ru != nil {
+		return &hit{rule: ru, policy: p, soa: idx.soa, trigger: triggerQName}
+	}
+	return nil
+}
+
+// matchResponse returns the rule of policy zone p triggered by an address in the answer, or by the name of a
+// name server in the response. Address rules take precedence over name server rules.
+func matchResponse(res *dns.Msg, p *policyZone, idx *index) *hit {
+	for _, rr := range res.Answer {
+		var addr netip.Addr
+		switch x := rr.(type) {
+		case *dns.A:
+			addr, _ = netip.AddrFromSlice(x.A.To4())
+		case *dns.AAAA:
+			addr, _ = netip.AddrFromSlice(x.AAAA)
+		default:
+			continue
+		}
+		if ru := idx.ip.match(addr); ru != nil {
+			return &hit{rule: ru, policy: p, soa: idx.soa, trigger: triggerIP}
+		}
+	}
+	for _, rrs := range [][]dns.RR{res.Answer, res.Ns} {
+		for _, rr := range rrs {
+			ns, ok := rr.(*dns.NS)
+			if !ok {
+				continue
+			}
+			if ru := idx.nsdname.match(strings.ToLower(ns.Ns)); ru != nil {
+				return &hit{rule: ru, policy: p, soa: idx.soa, trigger: triggerNSDName}
+			}
+		}
+	}
+	return nil
+}
+
+// hasResponseTriggers returns true if the index has rules that are triggered by responses.
+func (idx *index) hasResponseTriggers() bool {
+	return len(idx.ip.rules) > 0 || len(idx.nsdname.exact) > 0 || len(idx.nsdname.wildcard) > 0
+}
+
+// apply carries out the action of the rule in h. If the query was already passed on, res holds the response.
+func (r *RPZ) apply(ctx context.Context, state request.Request, h *hit, res *dns.Msg) (int, error) {
+	server := metrics.WithServer(ctx)
+	HitsCount.WithLabelValues(server, h.policy.name, h.trigger, h.action.String()).Inc()
+	metadata.SetValueFunc(ctx, "rpz/zone", func() string { return h.policy.name })
+	metadata.SetValueFunc(ctx, "rpz/trigger", func() string { return h.trigger })
+	metadata.SetValueFunc(ctx, "rpz/rule", func() string { return h.owner })
+	metadata.SetValueFunc(ctx, "rpz/action", func() string { return h.action.String() })
+
+	switch h.action {
+	case actionPassthru:
+		if res != nil {
+			state.W.WriteMsg(res)
+			return dns.RcodeSuccess, nil
+		}
+		return plugin.NextOrFailure(r.Name(), r.Next, ctx, state.W, state.Req)
+
+	case actionDrop:
+		// Nothing is written, the client times out.
+		return dns.RcodeSuccess, nil
+
+	case actionNXDOMAIN, actionNODATA:
+		m := new(dns.Msg)
+		m.SetReply(state.Req)
+		m.RecursionAvailable = true
+		if h.action == actionNXDOMAIN {
+			m.Rcode = dns.RcodeNameError
+		}
+		if h.soa != nil {
+			m.Ns = []dns.RR{h.soa}
+		}
+		state.W.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+
+	// actionLocalData
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.RecursionAvailable = true
+	m.Answer = r.localData(ctx, state, h)
+	if len(m.Answer) == 0 && h.soa != nil {
+		m.Ns = []dns.RR{h.soa}
+	}
+	state.W.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// localData returns the answer built from the local data of the rule in h. A CNAME is resolved, unless
+// the query was for the CNAME itself.
+func (r *RPZ) localData(ctx context.Context, state request.Request, h *hit) []dns.RR {
+	qname, qtype := state.QName(), state.QType()
+
+	var answer []dns.RR
+	for _, rr := range h.data {
+		if rr.Header().Rrtype != qtype && rr.Header().Rrtype != dns.TypeCNAME {
+			continue
+		}
+		rr = dns.Copy(rr)
+		rr.Header().Name = qname
+		if cname, ok := rr.(*dns.CNAME); ok && strings.HasPrefix(cname.Target, "*.") {
+			// "CNAME *.example.net." rewrites the query name to a name below example.net.
+			cname.Target = dns.Fqdn(strings.TrimSuffix(qname, ".") + cname.Target[1:])
+		}
+		answer = append(answer, rr)
+	}
+
+	if len(answer) != 1 || qtype == dns.TypeCNAME {
+		return answer
+	}
+	cname, ok := answer[0].(*dns.CNAME)
+	if !ok {
+		return answer
+	}
+	res, err := r.upstream.Lookup(ctx, state, cname.Target, qtype)
+	if err != nil || res == nil {
+		return answer
+	}
+	return append(answer, res.Answer...)
+}
+
+// stripZone removes the zone from an IPv6 address, such as "fe80::1%eth0".
+func stripZone(ip string) string {
+	if i := strings.IndexByte(ip, '%'); i >= 0 {
+		return ip[:i]
+	}
+	return ip
+}
@@ -0,0 +1,221 @@
+package rpz
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+const policyZoneData = `$ORIGIN rpz.example.
+$TTL 60
+@                               IN SOA  ns.rpz.example. admin.rpz.example. 1 3600 600 86400 60
+                                IN NS   ns.rpz.example.
+bad.example.com                 IN CNAME .
+*.bad.example.com               IN CNAME .
+empty.example.com               IN CNAME *.
+good.bad.example.com            IN CNAME rpz-passthru.
+drop.example.com                IN CNAME rpz-drop.
+walled.example.com              IN CNAME garden.example.net.
+*.walled.example.net            IN CNAME *.garden.example.net.
+local.example.com               IN A    192.0.2.53
+                                IN TXT  "blocked"
+32.1.0.0.10.rpz-client-ip       IN CNAME rpz-drop.
+24.0.100.51.198.rpz-ip          IN CNAME .
+48.zz.db8.2001.rpz-ip           IN CNAME *.
+ns.evil.example.rpz-nsdname     IN CNAME .
+`
+
+func newTestRPZ(t *testing.T, next plugin.Handler) *RPZ {
+	z, err := file.Parse(strings.NewReader(policyZoneData), "rpz.example.", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse policy zone: %s", err)
+	}
+	return &RPZ{Next: next, Zones: []string{"."}, policies: []*policyZone{newPolicyZone(z, "rpz.example.")}}
+}
+
+// backend answers every query with the address 203.0.113.1, unless the query name is one of the names below.
+func backend() plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		state := request.Request{W: w, Req: r}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		switch state.Name() {
+		case "sinkholed.example.org.":
+			m.Answer = []dns.RR{test.A("sinkholed.example.org. 300 IN A 198.51.100.7")}
+		case "v6.example.org.":
+			m.Answer = []dns.RR{test.AAAA("v6.example.org. 300 IN AAAA 2001:db8::1")}
+		case "delegated.example.org.":
+			m.Ns = []dns.RR{test.NS("example.org. 300 IN NS ns.evil.example.")}
+		default:
+			m.Answer = []dns.RR{test.A(state.Name() + " 300 IN A 203.0.113.1")}
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestRPZ(t *testing.T) {
+	r := newTestRPZ(t, backend())
+
+	tests := []struct {
+		qname   string
+		qtype   uint16
+		client  string
+		noReply bool
+		rcode   int
+		answer  []dns.RR
+		ns      int
+	}{
+		// Not in the policy zone.
+		{qname: "example.org.", qtype: dns.TypeA, answer: []dns.RR{test.A("example.org. 300 IN A 203.0.113.1")}},
+		// QNAME triggers.
+		{qname: "bad.example.com.", qtype: dns.TypeA, rcode: dns.RcodeNameError, ns: 1},
+		{qname: "a.b.bad.example.com.", qtype: dns.TypeA, rcode: dns.RcodeNameError, ns: 1},
+		{qname: "empty.example.com.", qtype: dns.TypeA, rcode: dns.RcodeSuccess, ns: 1},
+		{qname: "good.bad.example.com.", qtype: dns.TypeA, answer: []dns.RR{test.A("good.bad.example.com. 300 IN A 203.0.113.1")}},
+		{qname: "drop.example.com.", qtype: dns.TypeA, noReply: true},
+		{qname: "local.example.com.", qtype: dns.TypeA, answer: []dns.RR{test.A("local.example.com. 60 IN A 192.0.2.53")}},
+		{qname: "local.example.com.", qtype: dns.TypeTXT, answer: []dns.RR{test.TXT(`local.example.com. 60 IN TXT "blocked"`)}},
+		{qname: "local.example.com.", qtype: dns.TypeMX, rcode: dns.RcodeSuccess, ns: 1},
+		{qname: "walled.example.com.", qtype: dns.TypeCNAME, answer: []dns.RR{test.CNAME("walled.example.com. 60 IN CNAME garden.example.net.")}},
+		{qname: "x.walled.example.net.", qtype: dns.TypeCNAME, answer: []dns.RR{test.CNAME("x.walled.example.net. 60 IN CNAME x.walled.example.net.garden.example.net.")}},
+		// CLIENT-IP trigger.
+		{qname: "example.org.", qtype: dns.TypeA, client: "10.0.0.1", noReply: true},
+		// IP triggers.
+		{qname: "sinkholed.example.org.", qtype: dns.TypeA, rcode: dns.RcodeNameError, ns: 1},
+		{qname: "v6.example.org.", qtype: dns.TypeAAAA, rcode: dns.RcodeSuccess, ns: 1},
+		// NSDNAME trigger.
+		{qname: "delegated.example.org.", qtype: dns.TypeA, rcode: dns.RcodeNameError, ns: 1},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		tw := &test.ResponseWriter{}
+		if tc.client != "" {
+			tw.RemoteIP = tc.client
+		}
+		rec := dnstest.NewRecorder(tw)
+
+		if _, err := r.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if tc.noReply {
+			if rec.Msg != nil {
+				t.Errorf("Test %d: expected no reply, got %s", i, rec.Msg)
+			}
+			continue
+		}
+		if rec.Msg == nil {
+			t.Errorf("Test %d: expected a reply", i)
+			continue
+		}
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if len(rec.Msg.Answer) != len(tc.answer) {
+			t.Errorf("Test %d: expected %d answers, got %d", i, len(tc.answer), len(rec.Msg.Answer))
+		} else if err := test.Section(test.Case{Qname: tc.qname, Qtype: tc.qtype, Answer: tc.answer}, test.Answer, rec.Msg.Answer); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+		if len(rec.Msg.Ns) != tc.ns {
+			t.Errorf("Test %d: expected %d records in the authority section, got %d", i, tc.ns, len(rec.Msg.Ns))
+		}
+	}
+}
+
+func TestRPZZones(t *testing.T) {
+	r := newTestRPZ(t, backend())
+	r.Zones = []string{"example.org."}
+
+	m := new(dns.Msg)
+	m.SetQuestion("bad.example.com.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	r.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected the policy not to apply outside of the zones, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+}
+
+func TestRPZOrder(t *testing.T) {
+	// Zone 1 has an IP rule that matches the response, zone 2 a QNAME rule that matches the query. The first
+	// zone wins, even though QNAME rules are checked before the query is passed on.
+	zones := []string{
+		`$ORIGIN one.rpz.example.
+@                               IN SOA  ns.rpz.example. admin.rpz.example. 1 3600 600 86400 60
+                                IN NS   ns.rpz.example.
+32.1.113.0.203.rpz-ip           IN CNAME .
+`,
+		`$ORIGIN two.rpz.example.
+@                               IN SOA  ns.rpz.example. admin.rpz.example. 1 3600 600 86400 60
+                                IN NS   ns.rpz.example.
+example.org                     IN CNAME *.
+`,
+	}
+	r := &RPZ{Next: backend(), Zones: []string{"."}}
+	for i, name := range []string{"one.rpz.example.", "two.rpz.example."} {
+		z, err := file.Parse(strings.NewReader(zones[i]), name, "stdin", 0)
+		if err != nil {
+			t.Fatalf("Failed to parse policy zone: %s", err)
+		}
+		r.policies = append(r.policies, newPolicyZone(z, name))
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := r.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if rec.Msg.Rcode != dns.RcodeNameError {
+		t.Errorf("Expected the IP rule of the first zone to win with NXDOMAIN, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+
+	// Reversed, the QNAME rule wins and the query is not passed on.
+	r.policies[0], r.policies[1] = r.policies[1], r.policies[0]
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := r.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if rec.Msg.Rcode != dns.RcodeSuccess || len(rec.Msg.Answer) != 0 {
+		t.Errorf("Expected the QNAME rule of the first zone to win with NODATA, got %s", rec.Msg)
+	}
+}
+
+func TestRPZReload(t *testing.T) {
+	r := newTestRPZ(t, backend())
+	p := r.policies[0]
+
+	idx := p.index()
+	if p.index() != idx {
+		t.Fatal("Expected the index to be reused")
+	}
+
+	z, _ := file.Parse(strings.NewReader(policyZoneData+"new.example.com IN CNAME .\n"), "rpz.example.", "stdin", 0)
+	p.Lock()
+	p.Tree = z.Tree
+	p.Unlock()
+
+	if p.index() == idx {
+		t.Fatal("Expected the index to be rebuilt")
+	}
+	if p.index().qname.match("new.example.com.") == nil {
+		t.Error("Expected the new rule to be in the index")
+	}
+}
+
+func TestStripZone(t *testing.T) {
+	if ip := stripZone("fe80::1%eth0"); net.ParseIP(ip) == nil {
+		t.Errorf("Expected an address, got %s", ip)
+	}
+}
@@ -0,0 +1,168 @@
+package rpz
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/parse"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+)
+
+var log = clog.NewWithPlugin("rpz")
+
+func init() { plugin.Register("rpz", setup) }
+
+func setup(c *caddy.Controller) error {
+	r, err := rpzParse(c)
+	if err != nil {
+		return plugin.Error("rpz", err)
+	}
+
+	for _, p := range r.policies {
+		p, z := p, p.Zone
+		if len(z.TransferFrom) > 0 {
+			c.OnStartup(func() error {
+				if err := z.SetTsigSecret(dnsserver.GetConfig(c).TsigSecret); err != nil {
+					return plugin.Error("rpz", err)
+				}
+				z.StartupOnce.Do(func() { go transferIn(z, p.name) })
+				return nil
+			})
+			continue
+		}
+		c.OnStartup(func() error {
+			z.StartupOnce.Do(func() { z.Reload(nil) })
+			return nil
+		})
+		c.OnShutdown(z.OnShutdown)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		r.Next = next
+		return r
+	})
+
+	return nil
+}
+
+// transferIn retrieves the policy zone z from its primaries and keeps it up to date, as the secondary
+// plugin does.
+func transferIn(z *file.Zone, name string) {
+	dur := time.Millisecond * 250
+	step := time.Duration(2)
+	max := time.Second * 10
+	for {
+		err := z.TransferIn()
+		if err == nil {
+			break
+		}
+		log.Warningf("All '%s' primaries failed to transfer, retrying in %s: %s", name, dur.String(), err)
+		time.Sleep(dur)
+		dur = step * dur
+		if dur > max {
+			dur = max
+		}
+	}
+	z.Update()
+}
+
+func rpzParse(c *caddy.Controller) (*RPZ, error) {
+	r := &RPZ{upstream: upstream.New()}
+	config := dnsserver.GetConfig(c)
+	seen := map[string]bool{}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		r.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+
+		for c.NextBlock() {
+			what := c.Val()
+			if !c.NextArg() {
+				return nil, c.ArgErr()
+			}
+			name := plugin.Name(c.Val()).Normalize()
+			if seen[name] {
+				return nil, c.Errf("duplicate policy zone '%s'", name)
+			}
+			seen[name] = true
+
+			switch what {
+			case "file":
+				// file NAME FILE [RELOAD]
+				args := c.RemainingArgs()
+				if len(args) < 1 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				fileName := args[0]
+				if !filepath.IsAbs(fileName) && config.Root != "" {
+					fileName = filepath.Join(config.Root, fileName)
+				}
+				reload := 1 * time.Minute
+				if len(args) > 1 {
+					d, err := time.ParseDuration(args[1])
+					if err != nil {
+						return nil, err
+					}
+					reload = d
+				}
+
+				z, err := parseFile(name, fileName, reload)
+				if err != nil {
+					return nil, err
+				}
+				z.ReloadInterval = reload
+				r.policies = append(r.policies, newPolicyZone(z, name))
+
+			case "transfer":
+				// transfer NAME from ADDRESS... [key NAME [ALGORITHM]]
+				from, key, err := parse.TransferIn(c)
+				if err != nil {
+					return nil, err
+				}
+				z := file.NewZone(name, "stdin")
+				z.TransferFrom = from
+				if key.Name != "" {
+					z.TransferKeys = make(map[string]parse.Key, len(from))
+					for _, f := range from {
+						z.TransferKeys[f] = key
+					}
+				}
+				r.policies = append(r.policies, newPolicyZone(z, name))
+
+			default:
+				return nil, c.Errf("unknown property '%s'", what)
+			}
+		}
+	}
+
+	if len(r.policies) == 0 {
+		return nil, c.Err("at least one policy zone is required")
+	}
+	return r, nil
+}
+
+// parseFile loads the policy zone name from fileName. A file that can't be opened is only an error if
+// it won't be reloaded.
+func parseFile(name, fileName string, reload time.Duration) (*file.Zone, error) {
+	reader, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		if reload == 0 {
+			return nil, err
+		}
+		log.Warningf("Failed to open %q: trying again in %s", err, reload)
+		return file.NewZone(name, fileName), nil
+	}
+	defer reader.Close()
+	return file.Parse(reader, name, fileName, 0)
+}
@@ -0,0 +1,66 @@
+package rpz
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	dir := t.TempDir()
+	zoneFile := filepath.Join(dir, "db.rpz")
+	if err := os.WriteFile(zoneFile, []byte(policyZoneData), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input            string
+		shouldErr        bool
+		expectedPolicies []string
+		expectedZones    []string
+	}{
+		{"rpz {\n file rpz.example " + zoneFile + "\n}", false, []string{"rpz.example."}, nil},
+		{"rpz example.org {\n file rpz.example " + zoneFile + " 0\n transfer feed.example from 10.0.0.1\n}", false,
+			[]string{"rpz.example.", "feed.example."}, []string{"example.org."}},
+		{"rpz {\n file missing.example " + filepath.Join(dir, "missing") + "\n}", false, []string{"missing.example."}, nil},
+		// fails
+		{"rpz", true, nil, nil},
+		{"rpz {\n file rpz.example\n}", true, nil, nil},
+		{"rpz {\n file missing.example " + filepath.Join(dir, "missing") + " 0\n}", true, nil, nil},
+		{"rpz {\n file rpz.example " + zoneFile + " fast\n}", true, nil, nil},
+		{"rpz {\n transfer feed.example 10.0.0.1\n}", true, nil, nil},
+		{"rpz {\n transfer feed.example from\n}", true, nil, nil},
+		{"rpz {\n file rpz.example " + zoneFile + "\n transfer rpz.example from 10.0.0.1\n}", true, nil, nil},
+		{"rpz {\n blocklist rpz.example\n}", true, nil, nil},
+		{"rpz {\n file rpz.example " + zoneFile + "\n}\nrpz {\n file rpz.example " + zoneFile + "\n}", true, nil, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		r, err := rpzParse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(r.policies) != len(tc.expectedPolicies) {
+			t.Errorf("Test %d: expected %d policy zones, got %d", i, len(tc.expectedPolicies), len(r.policies))
+			continue
+		}
+		for j, p := range r.policies {
+			if p.name != tc.expectedPolicies[j] {
+				t.Errorf("Test %d: expected policy zone %s, got %s", i, tc.expectedPolicies[j], p.name)
+			}
+		}
+		if len(r.Zones) != len(tc.expectedZones) || (len(r.Zones) > 0 && r.Zones[0] != tc.expectedZones[0]) {
+			t.Errorf("Test %d: expected zones %v, got %v", i, tc.expectedZones, r.Zones)
+		}
+	}
+}
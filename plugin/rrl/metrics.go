@@ -0,0 +1,32 @@
+package rrl
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// exceededCount is the number of responses that were over the limit.
+	exceededCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "rrl",
+		Name:      "responses_exceeded_total",
+		Help:      "Counter of responses that exceeded the rate limit, including those in log-only mode.",
+	}, []string{"server", "zone", "view", "class"})
+	// droppedCount is the number of responses that were dropped.
+	droppedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "rrl",
+		Name:      "responses_dropped_total",
+		Help:      "Counter of responses that were dropped because they exceeded the rate limit.",
+	}, []string{"server", "zone", "view", "class"})
+	// slippedCount is the number of responses that were replaced by a truncated response.
+	slippedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "rrl",
+		Name:      "responses_slipped_total",
+		Help:      "Counter of responses that were truncated because they exceeded the rate limit.",
+	}, []string{"server", "zone", "view", "class"})
+)
@@ -0,0 +1,224 @@
+// Package rrl implements Response Rate Limiting, to prevent a server from being used as an amplifier in
+// reflection attacks.
+package rrl
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// class is the class of a response, every class has its own rate.
+type class uint8
+
+const (
+	classResponse class = iota // a positive answer
+	classNodata                // an empty answer
+	classNXDomain              // NXDOMAIN
+	classReferral              // a delegation to another server
+	classError                 // any other rcode
+	numClasses
+)
+
+var classNames = [numClasses]string{"response", "nodata", "nxdomain", "referral", "error"}
+
+func (c class) String() string { return classNames[c] }
+
+// RRL limits the rate of the responses to clients.
+type RRL struct {
+	Next  plugin.Handler
+	Zones []string
+
+	rates     [numClasses]float64 // responses per second, zero is unlimited
+	ipv4Mask  int
+	ipv6Mask  int
+	window    time.Duration
+	slipRatio int
+	logOnly   bool
+	maxSize   int
+
+	table *table
+	stop  chan struct{}
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rl *RRL) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	// Responses over TCP can't be used in reflection attacks, they are never limited.
+	zone := plugin.Zones(rl.Zones).Matches(state.Name())
+	if zone == "" || state.Proto() == "tcp" {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	rw := &ResponseWriter{ResponseWriter: w, rl: rl, state: state, server: metrics.WithServer(ctx), zone: zone, view: metrics.WithView(ctx)}
+	return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, rw, r)
+}
+
+// Name implements the plugin.Handler interface.
+func (rl *RRL) Name() string { return "rrl" }
+
+// ResponseWriter debits the responses from the client's account, and drops or slips them when they are over
+// the limit.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	rl     *RRL
+	state  request.Request
+	server string
+	zone   string
+	view   string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	rl := w.rl
+	c := classify(res)
+	rate := rl.rates[c]
+	if rate == 0 {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	k, ok := rl.key(w.state, res, c)
+	if !ok {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+	allowed, start, slip := rl.table.debit(k, rate, rl.slipRatio)
+	if allowed {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	exceededCount.WithLabelValues(w.server, w.zone, w.view, c.String()).Inc()
+	if start {
+		mode := ""
+		if rl.logOnly {
+			mode = " (log-only)"
+		}
+		log.Infof("Limiting %s responses to %s/%d for %s%s", c, k.prefix, rl.mask(k.prefix), w.state.Name(), mode)
+	}
+	if rl.logOnly {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	if !slip {
+		droppedCount.WithLabelValues(w.server, w.zone, w.view, c.String()).Inc()
+		return nil
+	}
+
+	slippedCount.WithLabelValues(w.server, w.zone, w.view, c.String()).Inc()
+	m := new(dns.Msg)
+	m.SetReply(w.state.Req)
+	m.Rcode = res.Rcode
+	m.Authoritative = res.Authoritative
+	m.RecursionAvailable = res.RecursionAvailable
+	m.Truncated = true
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// Write implements the dns.ResponseWriter interface. It is not rate limited.
+func (w *ResponseWriter) Write(buf []byte) (int, error) {
+	log.Warning("RRL called with Write: not rate limiting reply")
+	return w.ResponseWriter.Write(buf)
+}
+
+// key returns the key of the account for the response res, of class c, to state's client.
+func (rl *RRL) key(state request.Request, res *dns.Msg, c class) (key, bool) {
+	addr, err := netip.ParseAddr(state.IP())
+	if err != nil {
+		return key{}, false
+	}
+	addr = addr.Unmap()
+	prefix, err := addr.Prefix(rl.mask(addr))
+	if err != nil {
+		return key{}, false
+	}
+
+	k := key{prefix: prefix.Addr(), class: c}
+	switch c {
+	case classResponse:
+		k.name = strings.ToLower(state.Name())
+		k.qtype = state.QType()
+	case classNodata, classNXDomain, classReferral:
+		// Key on the zone, so random names don't get a fresh account each.
+		k.name = strings.ToLower(authority(res, state.Name()))
+	}
+	return k, true
+}
+
+// mask returns the prefix length for addr.
+func (rl *RRL) mask(addr netip.Addr) int {
+	if addr.Is4() {
+		return rl.ipv4Mask
+	}
+	return rl.ipv6Mask
+}
+
+// classify returns the class of the response m.
+func classify(m *dns.Msg) class {
+	switch m.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return classNXDomain
+	default:
+		return classError
+	}
+	if len(m.Answer) > 0 {
+		return classResponse
+	}
+	soa, ns := false, false
+	for _, rr := range m.Ns {
+		switch rr.Header().Rrtype {
+		case dns.TypeSOA:
+			soa = true
+		case dns.TypeNS:
+			ns = true
+		}
+	}
+	if ns && !soa && !m.Authoritative {
+		return classReferral
+	}
+	return classNodata
+}
+
+// authority returns the owner name of the first record in the authority section of m, which is the zone
+// for negative answers and the delegated name for referrals. It returns qname if there are none.
+func authority(m *dns.Msg, qname string) string {
+	if len(m.Ns) > 0 {
+		return m.Ns[0].Header().Name
+	}
+	return qname
+}
+
+// OnStartup starts removing the idle accounts.
+func (rl *RRL) OnStartup() error {
+	stop := make(chan struct{})
+	rl.stop = stop
+	go func() {
+		tick := time.NewTicker(rl.window)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				rl.table.cleanup()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// OnShutdown stops removing the idle accounts.
+func (rl *RRL) OnShutdown() error {
+	if rl.stop != nil {
+		close(rl.stop)
+		rl.stop = nil
+	}
+	return nil
+}
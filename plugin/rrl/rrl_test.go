@@ -0,0 +1,144 @@
+package rrl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// answer is a plugin that answers every query with rcode and the authority records ns. A successful answer
+// without ns gets an A record.
+func answer(rcode int, ns ...dns.RR) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		m.Authoritative = true
+		if rcode == dns.RcodeSuccess && len(ns) == 0 {
+			m.Answer = []dns.RR{test.A(r.Question[0].Name + " 300 IN A 192.0.2.1")}
+		}
+		m.Ns = ns
+		w.WriteMsg(m)
+		return rcode, nil
+	})
+}
+
+func newTestRRL(t *testing.T, config string, next plugin.Handler) *RRL {
+	t.Helper()
+	rl, err := rrlParse(caddy.NewTestController("dns", config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl.Next = next
+	return rl
+}
+
+// query sends n queries for qname from addr, and returns the number of responses and truncated responses.
+func query(rl *RRL, qname, addr string, tcp bool, n int) (responses, truncated int) {
+	for i := 0; i < n; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: addr, TCP: tcp})
+		rl.ServeDNS(context.Background(), rec, m)
+		if rec.Msg == nil {
+			continue
+		}
+		responses++
+		if rec.Msg.Truncated {
+			truncated++
+		}
+	}
+	return responses, truncated
+}
+
+func TestRRL(t *testing.T) {
+	rl := newTestRRL(t, "rrl . {\n responses-per-second 5\n slip-ratio 0\n}", answer(dns.RcodeSuccess))
+
+	if n, _ := query(rl, "example.org.", "10.0.0.1", false, 20); n != 5 {
+		t.Errorf("Expected 5 responses, got %d", n)
+	}
+	// Same prefix, same name.
+	if n, _ := query(rl, "example.org.", "10.0.0.2", false, 5); n != 0 {
+		t.Errorf("Expected no responses for the same prefix, got %d", n)
+	}
+	// Different name.
+	if n, _ := query(rl, "www.example.org.", "10.0.0.1", false, 5); n != 5 {
+		t.Errorf("Expected 5 responses for another name, got %d", n)
+	}
+	// Different prefix.
+	if n, _ := query(rl, "example.org.", "10.0.1.1", false, 5); n != 5 {
+		t.Errorf("Expected 5 responses for another prefix, got %d", n)
+	}
+	// TCP is never limited.
+	if n, _ := query(rl, "example.org.", "10.0.0.1", true, 10); n != 10 {
+		t.Errorf("Expected 10 responses over TCP, got %d", n)
+	}
+}
+
+func TestRRLSlip(t *testing.T) {
+	rl := newTestRRL(t, "rrl . {\n responses-per-second 1\n}", answer(dns.RcodeSuccess))
+
+	n, tc := query(rl, "example.org.", "10.0.0.1", false, 11)
+	if n != 6 || tc != 5 {
+		t.Errorf("Expected 6 responses of which 5 truncated, got %d and %d", n, tc)
+	}
+}
+
+func TestRRLLogOnly(t *testing.T) {
+	rl := newTestRRL(t, "rrl . {\n responses-per-second 1\n log-only\n}", answer(dns.RcodeSuccess))
+
+	if n, tc := query(rl, "example.org.", "10.0.0.1", false, 10); n != 10 || tc != 0 {
+		t.Errorf("Expected 10 responses in log-only mode, got %d (%d truncated)", n, tc)
+	}
+}
+
+func TestRRLNXDomain(t *testing.T) {
+	soa := test.SOA("example.org. 300 IN SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 300")
+	rl := newTestRRL(t, "rrl . {\n responses-per-second 100\n nxdomains-per-second 2\n slip-ratio 0\n}", answer(dns.RcodeNameError, soa))
+
+	// NXDOMAINs for random names in a zone share an account.
+	responses := 0
+	for _, name := range []string{"a.example.org.", "b.example.org.", "c.example.org.", "d.example.org."} {
+		n, _ := query(rl, name, "10.0.0.1", false, 1)
+		responses += n
+	}
+	if responses != 2 {
+		t.Errorf("Expected 2 responses, got %d", responses)
+	}
+}
+
+func TestRRLUnlimitedClass(t *testing.T) {
+	rl := newTestRRL(t, "rrl . {\n errors-per-second 1\n}", answer(dns.RcodeSuccess))
+
+	if n, _ := query(rl, "example.org.", "10.0.0.1", false, 10); n != 10 {
+		t.Errorf("Expected 10 responses, got %d", n)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	ns := test.NS("sub.example.org. 300 IN NS ns.sub.example.org.")
+	soa := test.SOA("example.org. 300 IN SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 300")
+
+	tests := []struct {
+		msg   *dns.Msg
+		class class
+	}{
+		{&dns.Msg{Answer: []dns.RR{test.A("example.org. 300 IN A 192.0.2.1")}}, classResponse},
+		{&dns.Msg{Ns: []dns.RR{soa}}, classNodata},
+		{&dns.Msg{Ns: []dns.RR{ns}}, classReferral},
+		{&dns.Msg{MsgHdr: dns.MsgHdr{Authoritative: true}, Ns: []dns.RR{ns}}, classNodata},
+		{&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeNameError}, Ns: []dns.RR{soa}}, classNXDomain},
+		{&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeRefused}}, classError},
+		{&dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeServerFailure}}, classError},
+	}
+	for i, tc := range tests {
+		if c := classify(tc.msg); c != tc.class {
+			t.Errorf("Test %d: expected class %s, got %s", i, tc.class, c)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package rrl
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+)
+
+var log = clog.NewWithPlugin("rrl")
+
+func init() { plugin.Register("rrl", setup) }
+
+func setup(c *caddy.Controller) error {
+	rl, err := rrlParse(c)
+	if err != nil {
+		return plugin.Error("rrl", err)
+	}
+
+	c.OnStartup(rl.OnStartup)
+	c.OnShutdown(rl.OnShutdown)
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rl.Next = next
+		return rl
+	})
+
+	return nil
+}
+
+// rateOptions maps the rate properties to the class they set.
+var rateOptions = map[string]class{
+	"responses-per-second": classResponse,
+	"nodata-per-second":    classNodata,
+	"nxdomains-per-second": classNXDomain,
+	"referrals-per-second": classReferral,
+	"errors-per-second":    classError,
+}
+
+func rrlParse(c *caddy.Controller) (*RRL, error) {
+	rl := &RRL{
+		ipv4Mask:  24,
+		ipv6Mask:  56,
+		window:    15 * time.Second,
+		slipRatio: 2,
+		maxSize:   100000,
+	}
+	// The rates of the other classes default to the responses-per-second rate.
+	var rates [numClasses]*float64
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		rl.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+
+		for c.NextBlock() {
+			option := c.Val()
+			if cl, ok := rateOptions[option]; ok {
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				rate, err := strconv.ParseFloat(args[0], 64)
+				if err != nil || rate < 0 {
+					return nil, c.Errf("invalid rate for %s '%s'", option, args[0])
+				}
+				rates[cl] = &rate
+				continue
+			}
+
+			switch option {
+			case "window":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				w, err := strconv.Atoi(args[0])
+				if err != nil || w < 1 || w > 3600 {
+					return nil, c.Errf("invalid window '%s'", args[0])
+				}
+				rl.window = time.Duration(w) * time.Second
+			case "ipv4-prefix-length", "ipv6-prefix-length":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				maxLen := 32
+				if option == "ipv6-prefix-length" {
+					maxLen = 128
+				}
+				l, err := strconv.Atoi(args[0])
+				if err != nil || l < 1 || l > maxLen {
+					return nil, c.Errf("invalid %s '%s'", option, args[0])
+				}
+				if maxLen == 32 {
+					rl.ipv4Mask = l
+				} else {
+					rl.ipv6Mask = l
+				}
+			case "slip-ratio":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				s, err := strconv.Atoi(args[0])
+				if err != nil || s < 0 || s > 10 {
+					return nil, c.Errf("invalid slip-ratio '%s'", args[0])
+				}
+				rl.slipRatio = s
+			case "max-table-size":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				s, err := strconv.Atoi(args[0])
+				if err != nil || s < 1 {
+					return nil, c.Errf("invalid max-table-size '%s'", args[0])
+				}
+				rl.maxSize = s
+			case "log-only":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				rl.logOnly = true
+			default:
+				return nil, c.Errf("unknown property '%s'", option)
+			}
+		}
+	}
+
+	for cl := range rl.rates {
+		switch {
+		case rates[cl] != nil:
+			rl.rates[cl] = *rates[cl]
+		case rates[classResponse] != nil:
+			rl.rates[cl] = *rates[classResponse]
+		}
+	}
+
+	rl.table = newTable(rl.window, rl.maxSize)
+	return rl, nil
+}
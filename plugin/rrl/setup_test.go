@@ -0,0 +1,69 @@
+package rrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input          string
+		shouldErr      bool
+		expectedRates  [numClasses]float64
+		expectedWindow time.Duration
+		expectedSlip   int
+		expectedIPv4   int
+		expectedIPv6   int
+		expectedLog    bool
+	}{
+		{"rrl", false, [numClasses]float64{}, 15 * time.Second, 2, 24, 56, false},
+		{"rrl {\n responses-per-second 10\n}", false, [numClasses]float64{10, 10, 10, 10, 10}, 15 * time.Second, 2, 24, 56, false},
+		{"rrl example.org {\n responses-per-second 10\n nxdomains-per-second 2.5\n errors-per-second 0\n window 5\n slip-ratio 0\n ipv4-prefix-length 32\n ipv6-prefix-length 64\n log-only\n}", false,
+			[numClasses]float64{10, 10, 2.5, 10, 0}, 5 * time.Second, 0, 32, 64, true},
+		{"rrl {\n referrals-per-second 1\n max-table-size 10\n}", false, [numClasses]float64{0, 0, 0, 1, 0}, 15 * time.Second, 2, 24, 56, false},
+		// fails
+		{"rrl {\n responses-per-second\n}", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+		{"rrl {\n responses-per-second -1\n}", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+		{"rrl {\n responses-per-second many\n}", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+		{"rrl {\n window 0\n}", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+		{"rrl {\n slip-ratio 11\n}", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+		{"rrl {\n ipv4-prefix-length 33\n}", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+		{"rrl {\n ipv6-prefix-length 0\n}", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+		{"rrl {\n max-table-size 0\n}", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+		{"rrl {\n log-only yes\n}", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+		{"rrl {\n requests-per-second 10\n}", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+		{"rrl\nrrl", true, [numClasses]float64{}, 0, 0, 0, 0, false},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		rl, err := rrlParse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, tc.input, err)
+			continue
+		}
+		if rl.rates != tc.expectedRates {
+			t.Errorf("Test %d: expected rates %v, got %v", i, tc.expectedRates, rl.rates)
+		}
+		if rl.window != tc.expectedWindow {
+			t.Errorf("Test %d: expected window %s, got %s", i, tc.expectedWindow, rl.window)
+		}
+		if rl.slipRatio != tc.expectedSlip {
+			t.Errorf("Test %d: expected slip-ratio %d, got %d", i, tc.expectedSlip, rl.slipRatio)
+		}
+		if rl.ipv4Mask != tc.expectedIPv4 || rl.ipv6Mask != tc.expectedIPv6 {
+			t.Errorf("Test %d: expected prefix lengths %d/%d, got %d/%d", i, tc.expectedIPv4, tc.expectedIPv6, rl.ipv4Mask, rl.ipv6Mask)
+		}
+		if rl.logOnly != tc.expectedLog {
+			t.Errorf("Test %d: expected log-only %t, got %t", i, tc.expectedLog, rl.logOnly)
+		}
+	}
+}
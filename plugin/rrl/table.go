@@ -0,0 +1,164 @@
+package rrl
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// key identifies the responses that share an account: the responses of a class for a client prefix.
+// Positive answers are also keyed on the query, negative answers and referrals on the zone they come from,
+// so a client that legitimately asks for many different names isn't limited.
+type key struct {
+	prefix netip.Addr // masked client address
+	class  class
+	qtype  uint16
+	name   string
+}
+
+// bucket is the account of a key. Its balance is credited with rate responses per second, up to rate,
+// and debited with every response. A response is over the limit when the balance is negative; the balance
+// can go down to -rate*window, so a client has to slow down for a while before its responses are sent again.
+type bucket struct {
+	key     key
+	balance float64
+	last    time.Time
+	limited bool // balance was negative at the last response
+	slip    int  // number of over-limit responses since the last slipped one
+}
+
+// numShards is the number of shards of a table, to reduce lock contention.
+const numShards = 64
+
+// table holds the buckets.
+type table struct {
+	shards  [numShards]shard
+	window  time.Duration
+	maxSize int // per shard
+
+	now func() time.Time
+}
+
+// shard holds a part of the buckets, with the least recently used bucket at the back of lru.
+type shard struct {
+	sync.Mutex
+	buckets map[key]*list.Element
+	lru     list.List
+}
+
+func newTable(window time.Duration, maxSize int) *table {
+	t := &table{window: window, maxSize: maxSize / numShards, now: time.Now}
+	if t.maxSize < 1 {
+		t.maxSize = 1
+	}
+	for i := range t.shards {
+		t.shards[i].buckets = make(map[key]*list.Element)
+	}
+	return t
+}
+
+// debit debits a response for k from its bucket, which is credited with rate responses per second. It returns
+// false if the response is over the limit, and whether this response starts a period of limiting. Every
+// slipRatio-th over-limit response is slipped; zero never slips. When the shard of k is full, its least
+// recently used bucket is removed to make room.
+func (t *table) debit(k key, rate float64, slipRatio int) (ok, start, slip bool) {
+	s := &t.shards[k.hash()%numShards]
+	now := t.now()
+
+	s.Lock()
+	defer s.Unlock()
+
+	var b *bucket
+	if e, ok := s.buckets[k]; ok {
+		b = e.Value.(*bucket)
+		s.lru.MoveToFront(e)
+	} else {
+		if len(s.buckets) >= t.maxSize {
+			s.remove(s.lru.Back())
+		}
+		b = &bucket{key: k, balance: rate, last: now}
+		s.buckets[k] = s.lru.PushFront(b)
+	}
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.balance += elapsed * rate
+		if b.balance > rate {
+			b.balance = rate
+		}
+	}
+	b.last = now
+
+	b.balance--
+	if floor := -rate * t.window.Seconds(); b.balance < floor {
+		b.balance = floor
+	}
+
+	if b.balance >= 0 {
+		b.limited = false
+		b.slip = 0
+		return true, false, false
+	}
+	start = !b.limited
+	b.limited = true
+	if slipRatio > 0 {
+		b.slip++
+		if b.slip >= slipRatio {
+			b.slip = 0
+			slip = true
+		}
+	}
+	return false, start, slip
+}
+
+// idle returns true if b hasn't been used for so long that it is full again, and can be forgotten.
+func (t *table) idle(b *bucket, now time.Time) bool {
+	return now.Sub(b.last) > t.window+time.Second
+}
+
+// remove removes the bucket in e from s. The caller must hold the lock of s.
+func (s *shard) remove(e *list.Element) {
+	delete(s.buckets, e.Value.(*bucket).key)
+	s.lru.Remove(e)
+}
+
+// cleanup removes the idle buckets. As the buckets are ordered by their last use, only the idle ones at the
+// back of each shard are visited.
+func (t *table) cleanup() {
+	now := t.now()
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.Lock()
+		for e := s.lru.Back(); e != nil && t.idle(e.Value.(*bucket), now); e = s.lru.Back() {
+			s.remove(e)
+		}
+		s.Unlock()
+	}
+}
+
+// len returns the number of buckets in the table.
+func (t *table) len() int {
+	n := 0
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.Lock()
+		n += len(s.buckets)
+		s.Unlock()
+	}
+	return n
+}
+
+// hash returns a hash of k to select its shard.
+func (k key) hash() uint32 {
+	// FNV-1a
+	h := uint32(2166136261)
+	for _, c := range k.prefix.As16() {
+		h = (h ^ uint32(c)) * 16777619
+	}
+	h = (h ^ uint32(k.class)) * 16777619
+	h = (h ^ uint32(k.qtype)) * 16777619
+	for i := 0; i < len(k.name); i++ {
+		h = (h ^ uint32(k.name[i])) * 16777619
+	}
+	return h
+}
@@ -0,0 +1,126 @@
+package rrl
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestTableDebit(t *testing.T) {
+	now := time.Unix(0, 0)
+	tb := newTable(2*time.Second, 1000)
+	tb.now = func() time.Time { return now }
+	k := key{prefix: netip.MustParseAddr("192.0.2.0"), name: "example.org."}
+
+	// The first rate responses in a second are sent.
+	for i := 0; i < 5; i++ {
+		if ok, _, _ := tb.debit(k, 5, 0); !ok {
+			t.Fatalf("Expected response %d to be allowed", i)
+		}
+	}
+	ok, start, _ := tb.debit(k, 5, 0)
+	if ok || !start {
+		t.Fatalf("Expected response to start limiting, got %t, %t", ok, start)
+	}
+	if ok, start, _ := tb.debit(k, 5, 0); ok || start {
+		t.Fatalf("Expected response to continue limiting, got %t, %t", ok, start)
+	}
+
+	// Flood for a while, the balance goes down to -rate*window.
+	for i := 0; i < 100; i++ {
+		tb.debit(k, 5, 0)
+	}
+	now = now.Add(time.Second)
+	if ok, _, _ := tb.debit(k, 5, 0); ok {
+		t.Fatal("Expected response to be limited after one second")
+	}
+	now = now.Add(3 * time.Second)
+	if ok, _, _ := tb.debit(k, 5, 0); !ok {
+		t.Fatal("Expected response to be allowed after the window")
+	}
+
+	// Other keys have their own account.
+	other := key{prefix: netip.MustParseAddr("192.0.3.0"), name: "example.org."}
+	if ok, _, _ := tb.debit(other, 5, 0); !ok {
+		t.Fatal("Expected response for other key to be allowed")
+	}
+}
+
+func TestTableSlip(t *testing.T) {
+	now := time.Unix(0, 0)
+	tb := newTable(15*time.Second, 1000)
+	tb.now = func() time.Time { return now }
+	k := key{prefix: netip.MustParseAddr("192.0.2.0")}
+
+	tb.debit(k, 1, 3)
+	slipped := 0
+	for i := 0; i < 9; i++ {
+		ok, _, slip := tb.debit(k, 1, 3)
+		if ok {
+			t.Fatal("Expected response to be limited")
+		}
+		if slip {
+			slipped++
+		}
+	}
+	if slipped != 3 {
+		t.Errorf("Expected 3 slipped responses, got %d", slipped)
+	}
+}
+
+func TestTableCleanup(t *testing.T) {
+	now := time.Unix(0, 0)
+	tb := newTable(time.Second, numShards)
+	tb.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		tb.debit(key{prefix: netip.AddrFrom4([4]byte{192, 0, 2, byte(i)})}, 1, 0)
+	}
+	if n := tb.len(); n > 10 {
+		t.Fatalf("Expected at most 10 buckets, got %d", n)
+	}
+
+	now = now.Add(3 * time.Second)
+	tb.cleanup()
+	if n := tb.len(); n != 0 {
+		t.Errorf("Expected idle buckets to be removed, got %d", n)
+	}
+}
+
+func TestTableMaxSize(t *testing.T) {
+	tb := newTable(time.Minute, numShards)
+	for i := 0; i < 1000; i++ {
+		tb.debit(key{prefix: netip.AddrFrom4([4]byte{192, 0, byte(i >> 8), byte(i)})}, 1, 0)
+	}
+	if n := tb.len(); n > numShards {
+		t.Errorf("Expected at most %d buckets, got %d", numShards, n)
+	}
+}
+
+func TestTableLRU(t *testing.T) {
+	// Find three keys in the same shard, which holds two buckets.
+	var keys []key
+	for i := 0; len(keys) < 3; i++ {
+		if k := (key{prefix: netip.AddrFrom4([4]byte{192, 0, byte(i >> 8), byte(i)})}); k.hash()%numShards == 0 {
+			keys = append(keys, k)
+		}
+	}
+	tb := newTable(time.Minute, 2*numShards)
+	now := time.Unix(0, 0)
+	tb.now = func() time.Time { return now }
+
+	tb.debit(keys[0], 1, 0)
+	tb.debit(keys[1], 1, 0)
+	// keys[0] is limited, which makes keys[1] the least recently used bucket.
+	if ok, _, _ := tb.debit(keys[0], 1, 0); ok {
+		t.Fatal("Expected response to be limited")
+	}
+	tb.debit(keys[2], 1, 0)
+
+	if ok, _, _ := tb.debit(keys[1], 1, 0); !ok {
+		t.Error("Expected the least recently used bucket to be evicted")
+	}
+	if ok, _, _ := tb.debit(keys[2], 1, 0); ok {
+		t.Error("Expected the recently used bucket to be kept")
+	}
+}
@@ -28,6 +28,9 @@ func setup(c *caddy.Controller) error {
 		z := zones.Z[n]
 		if len(z.TransferFrom) > 0 {
 			c.OnStartup(func() error {
+				if err := z.SetTsigSecret(dnsserver.GetConfig(c).TsigSecret); err != nil {
+					return plugin.Error("secondary", err)
+				}
 				z.StartupOnce.Do(func() {
 					go func() {
 						dur := time.Millisecond * 250
@@ -73,12 +76,15 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 			}
 
 			for c.NextBlock() {
-				var f []string
+				var (
+					f   []string
+					key parse.Key
+				)
 
 				switch c.Val() {
 				case "transfer":
 					var err error
-					f, err = parse.TransferIn(c)
+					f, key, err = parse.TransferIn(c)
 					if err != nil {
 						return file.Zones{}, err
 					}
@@ -90,6 +96,14 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 					if f != nil {
 						z[origin].TransferFrom = append(z[origin].TransferFrom, f...)
 					}
+					if key.Name != "" {
+						if z[origin].TransferKeys == nil {
+							z[origin].TransferKeys = make(map[string]parse.Key)
+						}
+						for _, from := range f {
+							z[origin].TransferKeys[from] = key
+						}
+					}
 					z[origin].Upstream = upstream.New()
 				}
 			}
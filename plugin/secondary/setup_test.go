@@ -12,12 +12,16 @@ func TestSecondaryParse(t *testing.T) {
 		shouldErr      bool
 		transferFrom   string
 		zones          []string
+		transferKey    string
+		transferAlg    string
 	}{
 		{
 			`secondary`,
 			false, // TODO(miek): should actually be true, because without transfer lines this does not make sense
 			"",
 			nil,
+			"",
+			"",
 		},
 		{
 			`secondary {
@@ -26,6 +30,8 @@ func TestSecondaryParse(t *testing.T) {
 			false,
 			"127.0.0.1:53",
 			nil,
+			"",
+			"",
 		},
 		{
 			`secondary example.org {
@@ -34,6 +40,38 @@ func TestSecondaryParse(t *testing.T) {
 			false,
 			"127.0.0.1:53",
 			[]string{"example.org."},
+			"",
+			"",
+		},
+		{
+			`secondary example.org {
+				transfer from 127.0.0.1 key transfer.key
+			}`,
+			false,
+			"127.0.0.1:53",
+			[]string{"example.org."},
+			"transfer.key.",
+			"hmac-sha256.",
+		},
+		{
+			`secondary example.org {
+				transfer from 127.0.0.1 key transfer.key hmac-sha384
+			}`,
+			false,
+			"127.0.0.1:53",
+			[]string{"example.org."},
+			"transfer.key.",
+			"hmac-sha384.",
+		},
+		{
+			`secondary example.org {
+				transfer from 127.0.0.1 key transfer.key sha256
+			}`,
+			true,
+			"",
+			nil,
+			"",
+			"",
 		},
 	}
 
@@ -58,6 +96,9 @@ func TestSecondaryParse(t *testing.T) {
 			if x := v.TransferFrom[0]; x != test.transferFrom {
 				t.Fatalf("Test %d transform from names don't match expected %q, but got %q", i, test.transferFrom, x)
 			}
+			if x := v.TransferKeys[test.transferFrom]; x.Name != test.transferKey || x.Algorithm != test.transferAlg {
+				t.Fatalf("Test %d transfer key doesn't match expected %q %q, but got %q %q", i, test.transferKey, test.transferAlg, x.Name, x.Algorithm)
+			}
 		}
 	}
 }
@@ -0,0 +1,99 @@
+package sign
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/file/tree"
+
+	"github.com/miekg/dns"
+)
+
+// maxIterations is the maximum number of additional hash iterations we allow for NSEC3.
+const maxIterations = 150
+
+// NSEC3PARAM returns an NSEC3PARAM record for origin, with the hash parameters iterations and salt. The salt is
+// hex encoded and empty when no salt is used.
+func NSEC3PARAM(origin string, iterations uint16, salt string) *dns.NSEC3PARAM {
+	return &dns.NSEC3PARAM{
+		Hdr:        dns.RR_Header{Name: origin, Ttl: 0, Rrtype: dns.TypeNSEC3PARAM, Class: dns.ClassINET},
+		Hash:       dns.SHA1,
+		Iterations: iterations,
+		SaltLength: uint8(len(salt) / 2),
+		Salt:       strings.ToUpper(salt),
+	}
+}
+
+// nsec3s returns the NSEC3 chain (RFC 5155) for the signed zone z, using the hash parameters in param. With
+// optOut, delegations without DS records are left out of the chain, and the opt-out flag is set. The chain
+// must be created after signing, as the type bitmaps are taken from the names in the zone.
+func nsec3s(origin string, z *file.Zone, param *dns.NSEC3PARAM, optOut bool, ttl uint32) []*dns.NSEC3 {
+	bitmaps := map[string][]uint16{}
+	z.AuthWalk(func(e *tree.Elem, _ map[uint16][]dns.RR, auth bool) error {
+		if !auth {
+			return nil
+		}
+		name := e.Name()
+		if name == origin {
+			// The apex NS and SOA records are kept outside of the tree.
+			bitmaps[name] = append(e.Types(), dns.TypeNS, dns.TypeSOA)
+			return nil
+		}
+		if optOut && e.Type(dns.TypeNS) != nil && e.Type(dns.TypeDS) == nil {
+			return nil
+		}
+		bitmaps[name] = e.Types()
+		return nil
+	})
+	if _, ok := bitmaps[origin]; !ok {
+		// An apex without other records than the SOA and NS, and no keys.
+		bitmaps[origin] = []uint16{dns.TypeNS, dns.TypeSOA}
+	}
+
+	// Empty non-terminals get an NSEC3 record too (RFC 5155, section 7.1).
+	for name := range bitmaps {
+		for off, end := dns.NextLabel(name, 0); !end; off, end = dns.NextLabel(name, off) {
+			parent := name[off:]
+			if !dns.IsSubDomain(origin, parent) || parent == origin {
+				break
+			}
+			if _, ok := bitmaps[parent]; !ok {
+				bitmaps[parent] = []uint16{}
+			}
+		}
+	}
+
+	flags := uint8(0)
+	if optOut {
+		flags = 1
+	}
+	nsec3s := make([]*dns.NSEC3, 0, len(bitmaps))
+	for name, bitmap := range bitmaps {
+		sort.Slice(bitmap, func(i, j int) bool { return bitmap[i] < bitmap[j] })
+		hash := dns.HashName(name, param.Hash, param.Iterations, param.Salt)
+		nsec3s = append(nsec3s, &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: strings.ToLower(hash) + "." + origin, Ttl: ttl, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET},
+			Hash:       param.Hash,
+			Flags:      flags,
+			Iterations: param.Iterations,
+			SaltLength: param.SaltLength,
+			Salt:       param.Salt,
+			HashLength: 20, // SHA1
+			NextDomain: hash,
+			TypeBitMap: bitmap,
+		})
+	}
+
+	// The next hashed owner name of each record is the one of the record after it, in hash order.
+	sort.Slice(nsec3s, func(i, j int) bool { return nsec3s[i].NextDomain < nsec3s[j].NextDomain })
+	first := nsec3s[0].NextDomain
+	for i := range nsec3s {
+		if i < len(nsec3s)-1 {
+			nsec3s[i].NextDomain = nsec3s[i+1].NextDomain
+			continue
+		}
+		nsec3s[i].NextDomain = first
+	}
+	return nsec3s
+}
@@ -0,0 +1,281 @@
+package sign
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// nsec3Zone has empty non-terminals (c.example.org. and b.c.example.org., w.example.org.), a wildcard, and
+// an insecure and a secure delegation.
+const nsec3Zone = `$TTL    30M
+$ORIGIN example.org.
+@       IN      SOA     ns miek.miek.nl. ( 1282630060 4H 1H 7D 4H )
+        IN      NS      ns
+ns      IN      A       192.0.2.1
+www     IN      A       192.0.2.2
+a.b.c   IN      TXT     "ent"
+*.w     IN      TXT     "wildcard"
+insecure IN     NS      ns.insecure
+ns.insecure IN  A       192.0.2.3
+secure  IN      NS      ns.example.net.
+secure  IN      DS      34385 13 2 fc7397c77afbccb6742fcff19c7b1410d0044661e7085fc200ae1ab3d15a5842
+`
+
+// signNSEC3 signs nsec3Zone with the nsec3 option in opts, and returns the zone as the file plugin loads it.
+func signNSEC3(t *testing.T, opts string) (*Signer, *file.Zone) {
+	t.Helper()
+	if err := os.WriteFile("db.nsec3-test.example.org", []byte(nsec3Zone), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("db.nsec3-test.example.org")
+
+	c := caddy.NewTestController("dns", `sign db.nsec3-test.example.org example.org {
+		key file testdata/Kmiek.nl.+013+59725
+		nsec3 `+opts+`
+	}`)
+	sign, err := parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sign.signers[0]
+	z, err := s.Sign(time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := write(buf, z); err != nil {
+		t.Fatal(err)
+	}
+	z, err = file.Parse(buf, "example.org.", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Failed to load the signed zone: %s", err)
+	}
+	return s, z
+}
+
+func TestSignNSEC3(t *testing.T) {
+	s, z := signNSEC3(t, "5 CAFEBABE")
+
+	apex, _ := z.Search("example.org.")
+	params := apex.Type(dns.TypeNSEC3PARAM)
+	if len(params) != 1 {
+		t.Fatalf("Expected 1 NSEC3PARAM, got %d", len(params))
+	}
+	param := params[0].(*dns.NSEC3PARAM)
+	if param.Flags != 0 || param.Hash != dns.SHA1 || param.Iterations != 5 || param.Salt != "CAFEBABE" {
+		t.Errorf("Expected NSEC3PARAM 1 0 5 CAFEBABE, got %s", param)
+	}
+	if x := apex.Type(dns.TypeNSEC); len(x) != 0 {
+		t.Errorf("Expected no NSEC records, got %d", len(x))
+	}
+
+	// example.org. www, ns, a.b.c, b.c, c, *.w, w, insecure and secure.
+	elems := z.NSEC3.All()
+	if len(elems) != 10 {
+		t.Fatalf("Expected 10 NSEC3 records, got %d", len(elems))
+	}
+	owners := map[string]bool{}
+	for _, e := range elems {
+		owners[e.Name()] = true
+	}
+	key := s.keys[0].Public
+	for _, e := range elems {
+		nsec3 := e.Type(dns.TypeNSEC3)[0].(*dns.NSEC3)
+		if nsec3.Flags != 0 || nsec3.Iterations != 5 || nsec3.Salt != "CAFEBABE" {
+			t.Errorf("Expected the NSEC3PARAM parameters in %s", nsec3)
+		}
+		if !owners[strings.ToLower(nsec3.NextDomain)+".example.org."] {
+			t.Errorf("Expected the next hashed owner name of %s to be in the chain", nsec3)
+		}
+		sigs := e.Type(dns.TypeRRSIG)
+		if len(sigs) != 1 {
+			t.Fatalf("Expected 1 RRSIG for %s, got %d", e.Name(), len(sigs))
+		}
+		if err := sigs[0].(*dns.RRSIG).Verify(key, []dns.RR{nsec3}); err != nil {
+			t.Errorf("Expected a valid signature for %s: %s", nsec3, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		bitmap []uint16
+	}{
+		{"example.org.", []uint16{dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeDNSKEY, dns.TypeNSEC3PARAM, dns.TypeCDS, dns.TypeCDNSKEY}},
+		{"www.example.org.", []uint16{dns.TypeA, dns.TypeRRSIG}},
+		{"b.c.example.org.", nil},
+		{"c.example.org.", nil},
+		{"w.example.org.", nil},
+		{"insecure.example.org.", []uint16{dns.TypeNS}},
+		{"secure.example.org.", []uint16{dns.TypeNS, dns.TypeDS, dns.TypeRRSIG}},
+	}
+	for _, tc := range tests {
+		var match *dns.NSEC3
+		for _, e := range elems {
+			if nsec3 := e.Type(dns.TypeNSEC3)[0].(*dns.NSEC3); nsec3.Match(tc.name) {
+				match = nsec3
+			}
+		}
+		if match == nil {
+			t.Errorf("Expected an NSEC3 record for %s", tc.name)
+			continue
+		}
+		if !equalBitmap(match.TypeBitMap, tc.bitmap) {
+			t.Errorf("Expected bitmap %v for %s, got %v", tc.bitmap, tc.name, match.TypeBitMap)
+		}
+	}
+}
+
+func TestSignNSEC3OptOut(t *testing.T) {
+	_, z := signNSEC3(t, "optout")
+
+	elems := z.NSEC3.All()
+	if len(elems) != 9 {
+		t.Fatalf("Expected 9 NSEC3 records, got %d", len(elems))
+	}
+	for _, e := range elems {
+		nsec3 := e.Type(dns.TypeNSEC3)[0].(*dns.NSEC3)
+		if nsec3.Flags != 1 || nsec3.Iterations != 0 || nsec3.Salt != "" {
+			t.Errorf("Expected opt-out without iterations and salt, got %s", nsec3)
+		}
+		if nsec3.Match("insecure.example.org.") {
+			t.Errorf("Expected no NSEC3 record for the insecure delegation, got %s", nsec3)
+		}
+	}
+	apex, _ := z.Search("example.org.")
+	if param := apex.Type(dns.TypeNSEC3PARAM)[0].(*dns.NSEC3PARAM); param.Flags != 0 {
+		t.Errorf("Expected NSEC3PARAM flags to be 0, got %d", param.Flags)
+	}
+}
+
+// proof lists the names an NSEC3 record in the authority section must match or cover.
+type proof struct {
+	match []string
+	cover []string
+}
+
+func TestNSEC3Proofs(t *testing.T) {
+	tests := []struct {
+		opts  string
+		qname string
+		qtype uint16
+		rcode int
+		proof
+	}{
+		// Name error: closest encloser, next closer name and wildcard at the closest encloser.
+		{"", "nx.example.org.", dns.TypeA, dns.RcodeNameError,
+			proof{[]string{"example.org."}, []string{"nx.example.org.", "*.example.org."}}},
+		{"", "nx.a.b.c.example.org.", dns.TypeA, dns.RcodeNameError,
+			proof{[]string{"a.b.c.example.org."}, []string{"nx.a.b.c.example.org.", "*.a.b.c.example.org."}}},
+		// The closest encloser is an empty non-terminal.
+		{"", "nx.b.c.example.org.", dns.TypeA, dns.RcodeNameError,
+			proof{[]string{"b.c.example.org."}, []string{"nx.b.c.example.org.", "*.b.c.example.org."}}},
+		// No data.
+		{"", "www.example.org.", dns.TypeAAAA, dns.RcodeSuccess,
+			proof{[]string{"www.example.org."}, nil}},
+		// No data for an empty non-terminal.
+		{"", "c.example.org.", dns.TypeA, dns.RcodeSuccess,
+			proof{[]string{"c.example.org."}, nil}},
+		{"", "w.example.org.", dns.TypeTXT, dns.RcodeSuccess,
+			proof{[]string{"w.example.org."}, nil}},
+		// Wildcard answer: the next closer name doesn't exist.
+		{"", "x.w.example.org.", dns.TypeTXT, dns.RcodeSuccess,
+			proof{nil, []string{"x.w.example.org."}}},
+		{"", "y.x.w.example.org.", dns.TypeTXT, dns.RcodeSuccess,
+			proof{nil, []string{"x.w.example.org."}}},
+		// Wildcard no data: closest encloser, next closer name, and the wildcard without the type.
+		{"", "x.w.example.org.", dns.TypeA, dns.RcodeSuccess,
+			proof{[]string{"w.example.org.", "*.w.example.org."}, []string{"x.w.example.org."}}},
+		// Insecure delegation: no DS at the delegation.
+		{"", "www.insecure.example.org.", dns.TypeA, dns.RcodeSuccess,
+			proof{[]string{"insecure.example.org."}, nil}},
+		// Insecure delegation in an opt-out span: closest provable encloser and next closer name.
+		{"optout", "www.insecure.example.org.", dns.TypeA, dns.RcodeSuccess,
+			proof{[]string{"example.org."}, []string{"insecure.example.org."}}},
+		{"optout", "insecure.example.org.", dns.TypeDS, dns.RcodeSuccess,
+			proof{[]string{"example.org."}, []string{"insecure.example.org."}}},
+	}
+
+	zones := map[string]*file.Zone{}
+	for _, tc := range tests {
+		z, ok := zones[tc.opts]
+		if !ok {
+			_, z = signNSEC3(t, tc.opts)
+			zones[tc.opts] = z
+		}
+		f := file.File{Next: test.ErrorHandler(), Zones: file.Zones{Z: map[string]*file.Zone{"example.org.": z}, Names: []string{"example.org."}}}
+
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		m.SetEdns0(4096, true)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("%s %s: expected no error, got %s", tc.qname, dns.TypeToString[tc.qtype], err)
+		}
+		resp := rec.Msg
+		if resp.Rcode != tc.rcode {
+			t.Errorf("%s %s: expected rcode %s, got %s", tc.qname, dns.TypeToString[tc.qtype], dns.RcodeToString[tc.rcode], dns.RcodeToString[resp.Rcode])
+		}
+
+		var nsec3s []*dns.NSEC3
+		sigs := 0
+		for _, rr := range resp.Ns {
+			switch x := rr.(type) {
+			case *dns.NSEC3:
+				nsec3s = append(nsec3s, x)
+			case *dns.RRSIG:
+				if x.TypeCovered == dns.TypeNSEC3 {
+					sigs++
+				}
+			}
+		}
+		if sigs != len(nsec3s) {
+			t.Errorf("%s %s: expected a signature for each of the %d NSEC3 records, got %d", tc.qname, dns.TypeToString[tc.qtype], len(nsec3s), sigs)
+		}
+		for _, name := range tc.match {
+			if !anyNSEC3(nsec3s, func(n *dns.NSEC3) bool { return n.Match(name) }) {
+				t.Errorf("%s %s: expected an NSEC3 record matching %s, got %v", tc.qname, dns.TypeToString[tc.qtype], name, nsec3s)
+			}
+		}
+		for _, name := range tc.cover {
+			if !anyNSEC3(nsec3s, func(n *dns.NSEC3) bool { return n.Cover(name) }) {
+				t.Errorf("%s %s: expected an NSEC3 record covering %s, got %v", tc.qname, dns.TypeToString[tc.qtype], name, nsec3s)
+			}
+		}
+		if len(nsec3s) > len(tc.match)+len(tc.cover) {
+			t.Errorf("%s %s: expected at most %d NSEC3 records, got %d", tc.qname, dns.TypeToString[tc.qtype], len(tc.match)+len(tc.cover), len(nsec3s))
+		}
+	}
+}
+
+func anyNSEC3(nsec3s []*dns.NSEC3, fn func(*dns.NSEC3) bool) bool {
+	for _, n := range nsec3s {
+		if fn(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalBitmap(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,9 +1,11 @@
 package sign
 
 import (
+	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/coredns/caddy"
@@ -89,6 +91,15 @@ func parse(c *caddy.Controller) (*Sign, error) {
 					signers[i].directory = dir[0]
 					signers[i].signedfile = fmt.Sprintf("db.%ssigned", signers[i].origin)
 				}
+			case "nsec3":
+				iterations, salt, optOut, err := nsec3Parse(c)
+				if err != nil {
+					return sign, err
+				}
+				for i := range signers {
+					signers[i].nsec3 = NSEC3PARAM(signers[i].origin, iterations, salt)
+					signers[i].optOut = optOut
+				}
 			default:
 				return nil, c.Errf("unknown property '%s'", c.Val())
 			}
@@ -98,3 +109,31 @@ func parse(c *caddy.Controller) (*Sign, error) {
 
 	return sign, nil
 }
+
+// nsec3Parse parses the arguments of nsec3: [ITERATIONS [SALT]] [optout].
+func nsec3Parse(c *caddy.Controller) (iterations uint16, salt string, optOut bool, err error) {
+	args := c.RemainingArgs()
+	if len(args) > 0 && args[len(args)-1] == "optout" {
+		optOut = true
+		args = args[:len(args)-1]
+	}
+	if len(args) > 2 {
+		return 0, "", false, c.ArgErr()
+	}
+
+	if len(args) > 0 {
+		i, err := strconv.Atoi(args[0])
+		if err != nil || i < 0 || i > maxIterations {
+			return 0, "", false, c.Errf("NSEC3 iterations must be between 0 and %d, got %q", maxIterations, args[0])
+		}
+		iterations = uint16(i)
+	}
+	if len(args) > 1 && args[1] != "-" {
+		b, err := hex.DecodeString(args[1])
+		if err != nil || len(b) > 255 {
+			return 0, "", false, c.Errf("NSEC3 salt must be at most 255 hex encoded bytes, got %q", args[1])
+		}
+		salt = args[1]
+	}
+	return iterations, salt, optOut, nil
+}
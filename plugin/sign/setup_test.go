@@ -73,3 +73,54 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestParseNSEC3(t *testing.T) {
+	tests := []struct {
+		nsec3      string
+		shouldErr  bool
+		iterations uint16
+		salt       string
+		optOut     bool
+	}{
+		{"", false, 0, "", false},
+		{"optout", false, 0, "", true},
+		{"10", false, 10, "", false},
+		{"10 aabbccdd", false, 10, "AABBCCDD", false},
+		{"0 - optout", false, 0, "", true},
+		// errors
+		{"-1", true, 0, "", false},
+		{"151", true, 0, "", false},
+		{"ten", true, 0, "", false},
+		{"0 xyz", true, 0, "", false},
+		{"0 aa optout extra", true, 0, "", false},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", `sign testdata/db.miek.nl miek.nl {
+			key file testdata/Kmiek.nl.+013+59725
+			nsec3 `+tc.nsec3+`
+		}`)
+		sign, err := parse(c)
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d expected errors, but got no error", i)
+		}
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d expected no errors, but got '%v'", i, err)
+		}
+		if tc.shouldErr {
+			continue
+		}
+		signer := sign.signers[0]
+		if signer.nsec3 == nil {
+			t.Fatalf("Test %d expected NSEC3 to be enabled", i)
+		}
+		if x := signer.nsec3.Iterations; x != tc.iterations {
+			t.Errorf("Test %d expected %d iterations, got %d", i, tc.iterations, x)
+		}
+		if x := signer.nsec3.Salt; x != tc.salt {
+			t.Errorf("Test %d expected salt %q, got %q", i, tc.salt, x)
+		}
+		if x := signer.optOut; x != tc.optOut {
+			t.Errorf("Test %d expected opt-out %t, got %t", i, tc.optOut, x)
+		}
+	}
+}
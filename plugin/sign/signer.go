@@ -25,6 +25,9 @@ type Signer struct {
 	jitterIncep time.Duration
 	jitterExpir time.Duration
 
+	nsec3  *dns.NSEC3PARAM // if set, the zone is signed with NSEC3 instead of NSEC
+	optOut bool            // leave delegations without DS records out of the NSEC3 chain
+
 	signedfile string
 	stop       chan struct{}
 }
@@ -53,6 +56,9 @@ func (s *Signer) Sign(now time.Time) (*file.Zone, error) {
 		z.Insert(pair.Public.ToDS(dns.SHA256).ToCDS())
 		z.Insert(pair.Public.ToCDNSKEY())
 	}
+	if s.nsec3 != nil {
+		z.Insert(dns.Copy(s.nsec3))
+	}
 
 	names := names(s.origin, z)
 	ln := len(names)
@@ -80,10 +86,13 @@ func (s *Signer) Sign(now time.Time) (*file.Zone, error) {
 			return nil
 		}
 
-		if e.Name() == s.origin {
+		switch {
+		case s.nsec3 != nil:
+			// The NSEC3 chain is created after signing, see below.
+		case e.Name() == s.origin:
 			nsec := NSEC(e.Name(), names[(ln+i)%ln], mttl, append(e.Types(), dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeNSEC))
 			z.Insert(nsec)
-		} else {
+		default:
 			nsec := NSEC(e.Name(), names[(ln+i)%ln], mttl, append(e.Types(), dns.TypeRRSIG, dns.TypeNSEC))
 			z.Insert(nsec)
 		}
@@ -105,7 +114,21 @@ func (s *Signer) Sign(now time.Time) (*file.Zone, error) {
 		i++
 		return nil
 	})
-	return z, err
+	if err != nil || s.nsec3 == nil {
+		return z, err
+	}
+
+	for _, nsec3 := range nsec3s(s.origin, z, s.nsec3, s.optOut, mttl) {
+		z.Insert(nsec3)
+		for _, pair := range s.keys {
+			rrsig, err := pair.signRRs([]dns.RR{nsec3}, s.origin, mttl, inception, expiration)
+			if err != nil {
+				return nil, err
+			}
+			z.Insert(rrsig)
+		}
+	}
+	return z, nil
 }
 
 // resign checks if the signed zone exists, or needs resigning.
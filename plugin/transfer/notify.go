@@ -2,6 +2,7 @@ package transfer
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/coredns/coredns/plugin/pkg/rcode"
 
@@ -25,11 +26,17 @@ func (t *Transfer) Notify(zone string) error {
 	}
 
 	var err1 error
-	for _, t := range x.to {
-		if t == "*" {
+	for i, to := range x.to {
+		if to == "*" {
 			continue
 		}
-		if err := sendNotify(c, m, t); err != nil {
+		c, m := c, m
+		if key := x.key(i); key.Name != "" {
+			c = &dns.Client{TsigSecret: t.tsigSecret}
+			m = m.Copy()
+			m.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+		}
+		if err := sendNotify(c, m, to); err != nil {
 			err1 = err
 		}
 	}
@@ -0,0 +1,46 @@
+package transfer
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/parse"
+
+	"github.com/miekg/dns"
+)
+
+func TestNotifyTSIG(t *testing.T) {
+	const secret = "NoTCJU+DMqFWywaPyxSijrDEA/eC3nK0xi3AMEZuPVk="
+
+	var keys, algorithms []string
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		key, algorithm := "", ""
+		if tsig := r.IsTsig(); tsig != nil {
+			key, algorithm = tsig.Hdr.Name, tsig.Algorithm
+		}
+		keys = append(keys, key)
+		algorithms = append(algorithms, algorithm)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})
+	defer s.Close()
+
+	tr := &Transfer{
+		xfrs: []*xfr{{
+			Zones: []string{"example.org."},
+			to:    []string{s.Addr, s.Addr},
+			keys:  []parse.Key{{}, {Name: "notify.key.", Algorithm: dns.HmacSHA512}},
+		}},
+		tsigSecret: map[string]string{"notify.key.": secret},
+	}
+	if err := tr.Notify("example.org."); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(keys) != 2 || keys[0] != "" || keys[1] != "notify.key." {
+		t.Errorf("Expected an unsigned and a signed notify, got keys %q", keys)
+	}
+	if len(algorithms) != 2 || algorithms[1] != dns.HmacSHA512 {
+		t.Errorf("Expected the notify to be signed with %s, got %q", dns.HmacSHA512, algorithms)
+	}
+}
@@ -1,6 +1,8 @@
 package transfer
 
 import (
+	"fmt"
+
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
@@ -30,6 +32,13 @@ func setup(c *caddy.Controller) error {
 	c.OnStartup(func() error {
 		config := dnsserver.GetConfig(c)
 		t.tsigSecret = config.TsigSecret
+		for _, x := range t.xfrs {
+			for _, key := range x.keys {
+				if _, ok := t.tsigSecret[key.Name]; key.Name != "" && !ok {
+					return plugin.Error("transfer", fmt.Errorf("no secret for TSIG key %q", key.Name))
+				}
+			}
+		}
 		// find all plugins that implement Transferer and add them to Transferers
 		plugins := config.Handlers()
 		for _, pl := range plugins {
@@ -53,11 +62,15 @@ func parseTransfer(c *caddy.Controller) (*Transfer, error) {
 		for c.NextBlock() {
 			switch c.Val() {
 			case "to":
-				args := c.RemainingArgs()
+				args, key, err := parse.TransferKey(c.RemainingArgs())
+				if err != nil {
+					return nil, err
+				}
 				if len(args) == 0 {
 					return nil, c.ArgErr()
 				}
 				for _, host := range args {
+					x.keys = append(x.keys, key)
 					if host == "*" {
 						x.to = append(x.to, host)
 						continue
@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/parse"
+
+	"github.com/miekg/dns"
 )
 
 func TestParse(t *testing.T) {
@@ -31,6 +34,26 @@ func TestParse(t *testing.T) {
 				}},
 			},
 		},
+		{`transfer example.net {
+			to 1.2.3.4 * key Transfer.Key
+			to 5.6.7.8
+			to 9.10.11.12 key notify.key hmac-sha512
+		 }`,
+			nil,
+			false,
+			&Transfer{
+				xfrs: []*xfr{{
+					Zones: []string{"example.net."},
+					to:    []string{"1.2.3.4:53", "*", "5.6.7.8:53", "9.10.11.12:53"},
+					keys: []parse.Key{
+						{Name: "transfer.key.", Algorithm: dns.HmacSHA256},
+						{Name: "transfer.key.", Algorithm: dns.HmacSHA256},
+						{},
+						{Name: "notify.key.", Algorithm: dns.HmacSHA512},
+					},
+				}},
+			},
+		},
 		// errors
 		{`transfer example.net example.org {
 		 }`,
@@ -45,6 +68,13 @@ func TestParse(t *testing.T) {
 			true,
 			nil,
 		},
+		{`transfer example.net {
+			to 1.2.3.4 key transfer.key hmac-md4
+		 }`,
+			nil,
+			true,
+			nil,
+		},
 		{
 			`
          transfer example.com example.edu {
@@ -108,6 +138,9 @@ func TestParse(t *testing.T) {
 				if tc.exp.xfrs[j].to[k] != to {
 					t.Errorf("Test %d expected %v in 'to', got %v", i, tc.exp.xfrs[j].to[k], to)
 				}
+				if tc.exp.xfrs[j].key(k) != x.key(k) {
+					t.Errorf("Test %d expected key %q for %v, got %q", i, tc.exp.xfrs[j].key(k), to, x.key(k))
+				}
 			}
 		}
 	}
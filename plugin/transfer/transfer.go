@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/coredns/coredns/plugin"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/parse"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -25,6 +28,7 @@ type Transfer struct {
 type xfr struct {
 	Zones []string
 	to    []string
+	keys  []parse.Key // TSIG keys of the hosts in to, without a name if a host doesn't use TSIG
 }
 
 // Transferer may be implemented by plugins to enable zone transfers
@@ -42,8 +46,10 @@ type Transferer interface {
 	//
 	// If serial is not 0, it will be handled as an IXFR request. If the serial is equal to or greater (newer) than
 	// the current serial for the zone, send a single SOA record to the channel and then close it.
-	// If the serial is less (older) than the current serial for the zone, perform an AXFR fallback
-	// by proceeding as if an AXFR was requested (as above).
+	// If the serial is less (older) than the current serial for the zone, the plugin may send the differences
+	// between that version and the current one as described in RFC 1995: the current SOA, followed by one or
+	// more sequences of the old SOA, the deleted records, the new SOA and the added records, and the current
+	// SOA again. Otherwise, perform an AXFR fallback by proceeding as if an AXFR was requested (as above).
 	Transfer(zone string, serial uint32) (<-chan []dns.RR, error)
 }
 
@@ -72,6 +78,7 @@ func (t *Transfer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Ms
 		// write msg here, so logging will pick it up
 		m := new(dns.Msg)
 		m.SetRcode(r, dns.RcodeRefused)
+		signReply(w, r, m)
 		w.WriteMsg(m)
 		return 0, nil
 	}
@@ -154,6 +161,7 @@ func (t *Transfer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Ms
 		m := new(dns.Msg)
 		m.SetReply(r)
 		m.Answer = []dns.RR{soa}
+		signReply(w, r, m)
 		w.WriteMsg(m)
 
 		log.Infof("Outgoing noop, incremental transfer for up to date zone %q to %s for %d SOA serial", state.QName(), state.IP(), soa.Serial)
@@ -184,22 +192,48 @@ func (t *Transfer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Ms
 }
 
 func (x xfr) allowed(state request.Request) bool {
-	for _, h := range x.to {
-		if h == "*" {
-			return true
-		}
-		to, _, err := net.SplitHostPort(h)
-		if err != nil {
-			return false
+	for i, h := range x.to {
+		if h != "*" {
+			to, _, err := net.SplitHostPort(h)
+			if err != nil {
+				return false
+			}
+			// If remote IP matches we accept. TODO(): make this works with ranges
+			if to != state.IP() {
+				continue
+			}
 		}
-		// If remote IP matches we accept. TODO(): make this works with ranges
-		if to == state.IP() {
+		if key := x.key(i); key.Name == "" || signedWith(state, key.Name) {
 			return true
 		}
 	}
 	return false
 }
 
+// key returns the TSIG key of the i-th host in x.to, its name is empty if the host doesn't use TSIG.
+func (x xfr) key(i int) parse.Key {
+	if i < len(x.keys) {
+		return x.keys[i]
+	}
+	return parse.Key{}
+}
+
+// signedWith returns true if the request is signed with the TSIG key and the signature is valid.
+func signedWith(state request.Request, key string) bool {
+	t := state.Req.IsTsig()
+	if t == nil || state.W.TsigStatus() != nil {
+		return false
+	}
+	return strings.ToLower(t.Hdr.Name) == key
+}
+
+// signReply signs m with the TSIG key of the request r, if r is signed with a valid signature.
+func signReply(w dns.ResponseWriter, r, m *dns.Msg) {
+	if t := r.IsTsig(); t != nil && w.TsigStatus() == nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, 300, time.Now().Unix())
+	}
+}
+
 // Find the first transfer instance for which the queried zone is the longest match. When nothing
 // is found nil is returned.
 func longestMatch(xfrs []*xfr, name string) *xfr {
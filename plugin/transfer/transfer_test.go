@@ -7,7 +7,9 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/parse"
 	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 )
@@ -276,3 +278,33 @@ func TestTransferNotAllowed(t *testing.T) {
 		t.Errorf("Expected REFUSED response code, got %s", dns.RcodeToString[w.Msg.Rcode])
 	}
 }
+
+func TestTransferAllowedKey(t *testing.T) {
+	x := xfr{
+		Zones: []string{"example.org."},
+		to:    []string{"10.240.0.1:53", "*"},
+		keys:  []parse.Key{{}, {Name: "transfer.key.", Algorithm: dns.HmacSHA256}},
+	}
+
+	tests := []struct {
+		ip      string
+		key     string
+		allowed bool
+	}{
+		{"10.240.0.1", "", true},
+		{"10.240.0.2", "", false},
+		{"10.240.0.2", "Transfer.Key.", true},
+		{"10.240.0.2", "other.key.", false},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetAxfr("example.org.")
+		if tc.key != "" {
+			m.SetTsig(tc.key, dns.HmacSHA256, 300, 0)
+		}
+		w := &test.ResponseWriter{TCP: true, RemoteIP: tc.ip}
+		if got := x.allowed(request.Request{W: w, Req: m}); got != tc.allowed {
+			t.Errorf("Test %d: expected allowed %t, got %t", i, tc.allowed, got)
+		}
+	}
+}
@@ -66,12 +66,15 @@ func (t *TSIGServer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.
 		return dns.RcodeSuccess, nil
 	}
 
-	// strip the TSIG RR. Next, and subsequent plugins will not see the TSIG RRs.
+	// strip the TSIG RR. Next, and subsequent plugins will not see the TSIG RRs, except for zone transfers
+	// and dynamic updates: the plugins handling those check the key themselves.
 	// This violates forwarding cases (RFC 8945 5.5). See README.md Bugs
-	if len(r.Extra) > 1 {
-		r.Extra = r.Extra[0 : len(r.Extra)-1]
-	} else {
-		r.Extra = []dns.RR{}
+	if tsigRR != nil && !keepTsig(state) {
+		if len(r.Extra) > 1 {
+			r.Extra = r.Extra[0 : len(r.Extra)-1]
+		} else {
+			r.Extra = []dns.RR{}
+		}
 	}
 
 	if rcode == dns.RcodeSuccess {
@@ -88,6 +91,15 @@ func (t *TSIGServer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.
 	return dns.RcodeSuccess, nil
 }
 
+// keepTsig returns true if the TSIG RR of the request in state must be passed on to the next plugins.
+func keepTsig(state request.Request) bool {
+	if state.Req.Opcode == dns.OpcodeUpdate {
+		return true
+	}
+	qtype := state.QType()
+	return qtype == dns.TypeAXFR || qtype == dns.TypeIXFR
+}
+
 func (t *TSIGServer) tsigRequired(qtype uint16) bool {
 	if t.all {
 		return true
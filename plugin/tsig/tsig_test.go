@@ -226,6 +226,52 @@ func TestServeDNSTsigErrors(t *testing.T) {
 	}
 }
 
+func TestServeDNSKeepTsig(t *testing.T) {
+	cases := []struct {
+		desc   string
+		opcode int
+		qType  uint16
+		keep   bool
+	}{
+		{"query", dns.OpcodeQuery, dns.TypeA, false},
+		{"zone transfer", dns.OpcodeQuery, dns.TypeAXFR, true},
+		{"incremental zone transfer", dns.OpcodeQuery, dns.TypeIXFR, true},
+		{"dynamic update", dns.OpcodeUpdate, dns.TypeSOA, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			seen := false
+			tsig := TSIGServer{
+				Zones: []string{"."},
+				Next: test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+					seen = r.IsTsig() != nil
+					m := new(dns.Msg)
+					m.SetReply(r)
+					w.WriteMsg(m)
+					return dns.RcodeSuccess, nil
+				}),
+			}
+
+			r := new(dns.Msg)
+			r.SetQuestion("example.org.", tc.qType)
+			r.Opcode = tc.opcode
+			r.SetTsig("test.key.", dns.HmacSHA256, 300, time.Now().Unix())
+
+			w := dnstest.NewRecorder(&test.ResponseWriter{})
+			if _, err := tsig.ServeDNS(context.TODO(), w, r); err != nil {
+				t.Fatal(err)
+			}
+			if seen != tc.keep {
+				t.Errorf("expected the next plugin to see the TSIG RR: %t, got %t", tc.keep, seen)
+			}
+			if w.Msg.IsTsig() == nil {
+				t.Error("expected TSIG in response")
+			}
+		})
+	}
+}
+
 func testHandler() test.HandlerFunc {
 	return func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
 		state := request.Request{W: w, Req: r}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestFileUpdate(t *testing.T) {
+	name, rm, err := test.TempFile(".", exampleOrg)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+
+	corefile := `example.org:0 {
+		tsig {
+			secret ` + tsigKey + ` ` + tsigSecret + `
+		}
+		file ` + name + ` {
+			update ` + tsigKey + `
+		}
+	}`
+
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	rr, _ := dns.NewRR("new.example.org. 300 IN A 127.0.0.53")
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{rr})
+
+	// Unsigned updates are refused.
+	r, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Could not send update: %s", err)
+	}
+	if r.Rcode != dns.RcodeRefused {
+		t.Fatalf("Expected rcode REFUSED for an unsigned update, got %s", dns.RcodeToString[r.Rcode])
+	}
+
+	m.SetTsig(tsigKey, dns.HmacSHA256, 300, time.Now().Unix())
+	client := dns.Client{Net: "udp", TsigSecret: map[string]string{tsigKey: tsigSecret}}
+	r, _, err = client.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Could not send update: %s", err)
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected rcode NOERROR, got %s", dns.RcodeToString[r.Rcode])
+	}
+	if r.IsTsig() == nil {
+		t.Errorf("Expected a signed reply")
+	}
+
+	m = new(dns.Msg)
+	m.SetQuestion("new.example.org.", dns.TypeA)
+	r, err = dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Could not send query: %s", err)
+	}
+	if len(r.Answer) != 1 || r.Answer[0].(*dns.A).A.String() != "127.0.0.53" {
+		t.Errorf("Expected the added record in the answer, got %v", r.Answer)
+	}
+}
+
+func TestFileUpdateNotImplemented(t *testing.T) {
+	name, rm, err := test.TempFile(".", exampleOrg)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+
+	i, udp, _, err := CoreDNSServerAndPorts(`example.org:0 {
+		file ` + name + `
+	}`)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	rr, _ := dns.NewRR("new.example.org. 300 IN A 127.0.0.53")
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{rr})
+
+	r, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Could not send update: %s", err)
+	}
+	if r.Rcode != dns.RcodeNotImplemented {
+		t.Errorf("Expected rcode NOTIMP, got %s", dns.RcodeToString[r.Rcode])
+	}
+}
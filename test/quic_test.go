@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+var quicCorefile = `quic://.:0 {
+		tls ../plugin/tls/test_cert.pem ../plugin/tls/test_key.pem ../plugin/tls/test_ca.pem
+		whoami
+	}`
+
+func TestQUIC(t *testing.T) {
+	q, udp, _, err := CoreDNSServerAndPorts(quicCorefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer q.Stop()
+
+	conn, err := quic.DialAddr(context.TODO(), udp, generateTLSConfig(), nil)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+
+	m := createTestMsg()
+
+	streamSync, err := conn.OpenStreamSync(context.TODO())
+	if err != nil {
+		t.Errorf("Expected no error but got: %s", err)
+	}
+
+	_, err = streamSync.Write(m)
+	if err != nil {
+		t.Errorf("Expected no error but got: %s", err)
+	}
+	_ = streamSync.Close()
+
+	sizeBuf := make([]byte, 2)
+	_, err = io.ReadFull(streamSync, sizeBuf)
+	if err != nil {
+		t.Errorf("Expected no error but got: %s", err)
+	}
+
+	size := binary.BigEndian.Uint16(sizeBuf)
+	buf := make([]byte, size)
+	_, err = io.ReadFull(streamSync, buf)
+	if err != nil {
+		t.Errorf("Expected no error but got: %s", err)
+	}
+
+	d := new(dns.Msg)
+	err = d.Unpack(buf)
+	if err != nil {
+		t.Errorf("Expected no error but got: %s", err)
+	}
+
+	if d.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected success but got %d", d.Rcode)
+	}
+
+	if len(d.Extra) != 2 {
+		t.Errorf("Expected 2 RRs in additional section, but got %d", len(d.Extra))
+	}
+}
+
+func TestQUICProtocolError(t *testing.T) {
+	q, udp, _, err := CoreDNSServerAndPorts(quicCorefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer q.Stop()
+
+	conn, err := quic.DialAddr(context.TODO(), udp, generateTLSConfig(), nil)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+
+	m := createInvalidDOQMsg()
+
+	streamSync, err := conn.OpenStreamSync(context.TODO())
+	if err != nil {
+		t.Errorf("Expected no error but got: %s", err)
+	}
+
+	_, err = streamSync.Write(m)
+	if err != nil {
+		t.Errorf("Expected no error but got: %s", err)
+	}
+	_ = streamSync.Close()
+
+	errorBuf := make([]byte, 2)
+	_, err = io.ReadFull(streamSync, errorBuf)
+	if err == nil {
+		t.Errorf("Expected protocol error but got: %s", errorBuf)
+	}
+
+	if !isProtocolErr(err) {
+		t.Errorf("Expected \"Application Error 0x2\" but got: %s", err)
+	}
+}
+
+func isProtocolErr(err error) bool {
+	var qAppErr *quic.ApplicationError
+	return errors.As(err, &qAppErr) && qAppErr.ErrorCode == dnsserver.DoQCodeProtocolError
+}
+
+func createTestMsg() []byte {
+	m := new(dns.Msg)
+	m.SetQuestion("whoami.example.org.", dns.TypeA)
+	m.Id = 0
+	msg, _ := m.Pack()
+	return dnsserver.AddPrefix(msg)
+}
+
+// createInvalidDOQMsg returns a query with a non-zero message ID, which RFC 9250 forbids.
+func createInvalidDOQMsg() []byte {
+	m := new(dns.Msg)
+	m.SetQuestion("whoami.example.org.", dns.TypeA)
+	m.Id = 1
+	msg, _ := m.Pack()
+	return dnsserver.AddPrefix(msg)
+}
+
+func generateTLSConfig() *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"doq"},
+	}
+}